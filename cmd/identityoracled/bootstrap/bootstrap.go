@@ -6,11 +6,19 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/tokenized/identity-oracle/cmd/identityoracled/handlers"
 	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
 	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/identity-oracle/internal/platform/encryption"
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/identity-oracle/internal/platform/migrate"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/identity-oracle/internal/platform/sanctions"
+	"github.com/tokenized/identity-oracle/internal/platform/sms"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
@@ -25,6 +33,9 @@ type Oracle struct {
 	server   *http.Server
 	spyNode  client.Client
 	db       *db.DB
+	store    oracle.Store
+	mailer   mail.Mailer
+	screener *sanctions.Screener
 }
 
 func Setup(ctx context.Context, logConfig logger.Config, cfg *Config, spyNode client.Client,
@@ -51,20 +62,27 @@ func Setup(ctx context.Context, logConfig logger.Config, cfg *Config, spyNode cl
 
 	logger.Info(ctx, "main : Started : Initialize Database")
 
-	masterDB, err := db.New(
-		&db.DBConfig{
-			Driver: cfg.Db.Driver,
-			URL:    cfg.Db.URL,
-		},
-		&db.StorageConfig{
-			Bucket: cfg.Storage.Bucket,
-			Root:   cfg.Storage.Root,
-		},
-	)
+	masterDB, err := openDatabase(cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "database")
 	}
 
+	if cfg.Db.AutoMigrate {
+		count, err := migrate.Up(ctx, masterDB)
+		if err != nil {
+			return nil, errors.Wrapf(err, "migrate : applied %d before failure", count)
+		}
+
+		logger.Info(ctx, "main : Applied %d migrations", count)
+	}
+
+	// Don't serve requests against a schema the code doesn't match.
+	if err := migrate.Check(ctx, masterDB); err != nil {
+		return nil, errors.Wrap(err, "run \"identityoracled migrate up\" or set DB_AUTO_MIGRATE")
+	}
+
+	store := oracle.NewDBStore(masterDB)
+
 	// ---------------------------------------------------------------------------------------------
 	// Web Config
 
@@ -81,16 +99,162 @@ func Setup(ctx context.Context, logConfig logger.Config, cfg *Config, spyNode cl
 
 	spyNode.RegisterHandler(listener)
 
+	// ---------------------------------------------------------------------------------------------
+	// Entity Normalization
+
+	normalizer, err := oracle.NewNormalizer(cfg.Oracle.EntityFieldStrictness)
+	if err != nil {
+		return nil, errors.Wrap(err, "entity normalizer")
+	}
+
+	// ---------------------------------------------------------------------------------------------
+	// Field Verification
+
+	var mailer mail.Mailer
+	if cfg.Email.Mock {
+		mailer = mail.NewMockMailer()
+	} else if len(cfg.Email.SMTPHost) != 0 {
+		mailer = mail.NewSMTPMailer(cfg.Email.SMTPHost, cfg.Email.SMTPPort, cfg.Email.SMTPUsername,
+			cfg.Email.SMTPPassword, cfg.Email.From)
+	}
+
+	var smsSender sms.SMSSender
+	if cfg.SMS.Mock {
+		smsSender = sms.NewMockSender()
+	} else if len(cfg.SMS.WebhookURL) != 0 {
+		smsSender = sms.NewWebhookSender(cfg.SMS.WebhookURL, cfg.SMS.WebhookToken,
+			cfg.SMS.WebhookTimeout)
+	}
+
+	domainResolver := domain.NewNetResolver(cfg.Verification.DomainTimeout)
+	paymailResolver := paymail.NewHTTPResolver(cfg.Verification.PaymailTimeout)
+
+	requirements, err := oracle.NewRequirements(cfg.Verification.RequiredFields,
+		cfg.Verification.TransferRequiredFields)
+	if err != nil {
+		return nil, errors.Wrap(err, "requirements")
+	}
+
+	// Required fields could never be verified if their codes aren't delivered.
+	if requirements.Requires("EmailAddress") && (mailer == nil || cfg.Email.Mock) {
+		return nil, errors.New("EmailAddress verification required without an SMTP mailer")
+	}
+	if requirements.Requires("PhoneNumber") && (smsSender == nil || cfg.SMS.Mock) {
+		return nil, errors.New("PhoneNumber verification required without an SMS webhook")
+	}
+
+	validity, err := oracle.NewValidity(cfg.Verification.IdentityValidity,
+		cfg.Verification.FieldValidity)
+	if err != nil {
+		return nil, errors.Wrap(err, "validity")
+	}
+
+	if err := oracle.ValidateDuplicateAction(cfg.Duplicates.Action); err != nil {
+		return nil, errors.Wrap(err, "duplicate action")
+	}
+
+	// ---------------------------------------------------------------------------------------------
+	// Sanctions Screening
+
+	var screener *sanctions.Screener
+	if len(cfg.Sanctions.Path) != 0 {
+		screener, err = sanctions.NewScreener(cfg.Sanctions.Path, cfg.Sanctions.Threshold)
+		if err != nil {
+			return nil, errors.Wrap(err, "sanctions screener")
+		}
+
+		logger.Info(ctx, "main : Loaded %d sanctioned names", screener.Size())
+
+		approver = oracle.NewScreeningApprover(store, screener, approver)
+	}
+
+	if approver != nil {
+		approver = oracle.NewInstrumentedApprover(approver)
+	}
+
+	// ---------------------------------------------------------------------------------------------
+	// User Lookup Probing
+
+	trustedProxies, err := mid.ParseTrustedProxies(cfg.Web.TrustedProxies)
+	if err != nil {
+		return nil, errors.Wrap(err, "trusted proxies")
+	}
+
+	var userLookupLimiter *mid.FailureLimiter
+	if cfg.Web.UserLookupFailureLimit > 0 {
+		userLookupLimiter = mid.NewFailureLimiter(cfg.Web.UserLookupFailureLimit,
+			cfg.Web.UserLookupFailureWindow, trustedProxies)
+	}
+
+	// ---------------------------------------------------------------------------------------------
+	// Rate Limiting
+
+	var rateLimiter *mid.RateLimiter
+	if len(cfg.RateLimit.Routes) != 0 {
+		var limitStore mid.RateLimitStore
+		switch cfg.RateLimit.Store {
+		case "memory":
+			limitStore = mid.NewMemoryRateLimitStore()
+		case "database", "postgres":
+			limitStore = mid.NewDBRateLimitStore(masterDB)
+		default:
+			return nil, errors.Errorf("unsupported rate limit store %s", cfg.RateLimit.Store)
+		}
+
+		rateLimiter, err = mid.NewRateLimiter(limitStore, cfg.RateLimit.Routes, trustedProxies)
+		if err != nil {
+			return nil, errors.Wrap(err, "rate limiter")
+		}
+	}
+
 	// ---------------------------------------------------------------------------------------------
 	// Start API Service
 
 	ra := bitcoin.NewRawAddressFromAddress(contractAddress)
 
-	webHandler := handlers.API(ctx, webConfig, masterDB, key, ra, listener, listener,
-		cfg.Oracle.TransferExpirationDurationSeconds, cfg.Oracle.IdentityExpirationDurationSeconds,
-		approver)
+	webHandler := handlers.API(ctx, &handlers.APIConfig{
+		Config:          webConfig,
+		MasterDB:        masterDB,
+		Store:           store,
+		Key:             key,
+		ContractAddress: ra,
+
+		// The listener tracks block headers and contract formations from the spynode.
+		Headers:      listener,
+		Contracts:    listener,
+		Chain:        listener,
+		MaxHeaderAge: cfg.Web.MaxHeaderAge,
+
+		TransferExpirationDurationSeconds: cfg.Oracle.TransferExpirationDurationSeconds,
+		IdentityExpirationDurationSeconds: cfg.Oracle.IdentityExpirationDurationSeconds,
+
+		Approver:     approver,
+		Normalizer:   normalizer,
+		Requirements: requirements,
+		Validity:     validity,
+
+		Mailer:                   mailer,
+		SMSSender:                smsSender,
+		VerificationCodeDuration: cfg.Verification.CodeDuration,
+
+		DomainResolver:          domainResolver,
+		DomainChallengeDuration: cfg.Verification.DomainChallengeDuration,
+
+		PaymailResolver: paymailResolver,
+
+		MaxDocumentSize: cfg.Documents.MaxSize,
 
-	requestLogger := mid.NewRequestLoggingMiddleware(logConfig)
+		DuplicateAction:    cfg.Duplicates.Action,
+		DuplicateThreshold: cfg.Duplicates.Threshold,
+
+		UserLookupLimiter: userLookupLimiter,
+		RateLimiter:       rateLimiter,
+		APIKeysRequired:   cfg.Web.APIKeysRequired,
+
+		AdminToken: cfg.Oracle.AdminToken,
+	})
+
+	requestLogger := mid.NewRequestLoggingMiddleware(logConfig, trustedProxies)
 	webHandler = requestLogger.Handler(webHandler)
 
 	api := &http.Server{
@@ -107,6 +271,9 @@ func Setup(ctx context.Context, logConfig logger.Config, cfg *Config, spyNode cl
 		server:   api,
 		spyNode:  spyNode,
 		db:       masterDB,
+		store:    store,
+		mailer:   mailer,
+		screener: screener,
 	}, nil
 }
 
@@ -129,6 +296,27 @@ func (o *Oracle) Run(ctx context.Context, spyNodeErrors *chan error) error {
 		serverErrors <- result
 	}()
 
+	// Notify users ahead of their identities expiring.
+	stopExpiry := make(chan struct{})
+	defer close(stopExpiry)
+	if o.cfg.Verification.IdentityValidity != 0 {
+		go o.notifyExpiringIdentities(ctx, stopExpiry)
+	}
+
+	// Screen all users again as sanctions lists are updated.
+	stopScreening := make(chan struct{})
+	defer close(stopScreening)
+	if o.screener != nil {
+		go o.screenUsers(ctx, stopScreening)
+	}
+
+	// Save spynode checkpoints in case the process doesn't shut down cleanly.
+	stopCheckpoints := make(chan struct{})
+	defer close(stopCheckpoints)
+	if o.cfg.SpyNode.CheckpointInterval != 0 {
+		go o.checkpoint(ctx, stopCheckpoints)
+	}
+
 	// ---------------------------------------------------------------------------------------------
 	// Shutdown
 
@@ -146,6 +334,7 @@ func (o *Oracle) Run(ctx context.Context, spyNodeErrors *chan error) error {
 		if err != nil {
 			logger.Error(ctx, "main : Spynode failed : %s", err)
 		}
+		o.listener.HandleDisconnect(ctx)
 
 		// Asking listener to shutdown and load shed.
 		if err := o.server.Shutdown(ctx); err != nil {
@@ -181,9 +370,106 @@ func (o *Oracle) Run(ctx context.Context, spyNodeErrors *chan error) error {
 	return nil
 }
 
+// notifyExpiringIdentities periodically notifies users whose identities are about to expire until
+// stop is closed.
+func (o *Oracle) notifyExpiringIdentities(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(o.cfg.Verification.ExpiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		count, err := oracle.NotifyExpiringIdentities(ctx, o.store, o.mailer,
+			o.cfg.Verification.ExpiryNotice)
+		if err != nil {
+			logger.Error(ctx, "main : Failed to notify expiring identities : %s", err)
+		} else if count > 0 {
+			logger.Info(ctx, "main : Notified %d expiring identities", count)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// screenUsers screens all users against the sanctions lists, then periodically reloads the lists
+// and screens them again until stop is closed.
+func (o *Oracle) screenUsers(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(o.cfg.Sanctions.ScreenInterval)
+	defer ticker.Stop()
+
+	for {
+		count, err := oracle.ScreenAllUsers(ctx, o.store, o.screener)
+		if err != nil {
+			logger.Error(ctx, "main : Failed to screen users : %s", err)
+		} else if count > 0 {
+			logger.Warn(ctx, "main : Sanctions screening found %d new matches", count)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := o.screener.Reload(); err != nil {
+			logger.Error(ctx, "main : Failed to reload sanctions lists : %s", err)
+		}
+	}
+}
+
+// checkpoint periodically saves the next spynode message id until stop is closed.
+func (o *Oracle) checkpoint(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(o.cfg.SpyNode.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := o.listener.Checkpoint(ctx); err != nil {
+			logger.Error(ctx, "main : Failed to checkpoint spynode : %s", err)
+		}
+	}
+}
+
+// openDatabase connects to the database and storage and configures encryption at rest.
+func openDatabase(cfg *Config) (*db.DB, error) {
+	masterDB, err := db.New(
+		&db.DBConfig{
+			Driver: cfg.Db.Driver,
+			URL:    cfg.Db.URL,
+		},
+		&db.StorageConfig{
+			Bucket: cfg.Storage.Bucket,
+			Root:   cfg.Storage.Root,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Oracle.EncryptionKey) > 0 {
+		masterKeys, err := encryption.NewMasterKeys(cfg.Oracle.EncryptionKey,
+			cfg.Oracle.PreviousEncryptionKeys)
+		if err != nil {
+			masterDB.Close()
+			return nil, errors.Wrap(err, "encryption keys")
+		}
+
+		masterDB.SetMasterKeys(masterKeys)
+	}
+
+	return masterDB, nil
+}
+
 func (o *Oracle) Save(ctx context.Context) error {
-	if err := o.listener.SaveNextMessageID(ctx, o.spyNode.NextMessageID()); err != nil {
-		return errors.Wrap(err, "save next message id")
+	if err := o.listener.Checkpoint(ctx); err != nil {
+		return errors.Wrap(err, "checkpoint")
 	}
 
 	return nil
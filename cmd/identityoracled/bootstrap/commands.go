@@ -0,0 +1,139 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/migrate"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+// EncryptEntities encrypts stored entity data with the current encryption key. Data that is in
+// plaintext, or was encrypted with one of the previous encryption keys, is re-encrypted. Run it,
+// followed by FingerprintUsers, after enabling encryption or rotating the encryption key.
+func EncryptEntities(ctx context.Context, cfg *Config) error {
+	if len(cfg.Oracle.EncryptionKey) == 0 {
+		return errors.New("No encryption key configured")
+	}
+
+	masterDB, err := openDatabase(cfg)
+	if err != nil {
+		return errors.Wrap(err, "database")
+	}
+	defer masterDB.Close()
+
+	count, err := oracle.NewDBStore(masterDB).EncryptEntities(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "encrypted %d before failure", count)
+	}
+
+	logger.Info(ctx, "Encrypted %d entities", count)
+	return nil
+}
+
+// FingerprintUsers stores the fingerprints used for duplicate detection of all existing users and
+// rehashes field verification values that were hashed with a previous key. Run it once after
+// upgrading so that users registered before duplicate detection are compared, and after enabling
+// encryption or rotating the encryption key since the hashes are keyed by it.
+func FingerprintUsers(ctx context.Context, cfg *Config) error {
+	masterDB, err := openDatabase(cfg)
+	if err != nil {
+		return errors.Wrap(err, "database")
+	}
+	defer masterDB.Close()
+
+	store := oracle.NewDBStore(masterDB)
+
+	count, err := oracle.FingerprintAllUsers(ctx, store)
+	if err != nil {
+		return errors.Wrapf(err, "fingerprinted %d before failure", count)
+	}
+
+	logger.Info(ctx, "Fingerprinted %d users", count)
+
+	count, err = oracle.RehashFieldVerifications(ctx, store)
+	if err != nil {
+		return errors.Wrapf(err, "rehashed %d field verifications before failure", count)
+	}
+
+	logger.Info(ctx, "Rehashed %d field verifications", count)
+	return nil
+}
+
+// Migrate runs a migration command. "up" applies the migrations that haven't been applied,
+// "down" rolls back the latest migration, or the migrations after a version when one is given,
+// and "status" lists the migrations and when they were applied.
+func Migrate(ctx context.Context, cfg *Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Missing migrate command : up, down, or status")
+	}
+
+	masterDB, err := openDatabase(cfg)
+	if err != nil {
+		return errors.Wrap(err, "database")
+	}
+	defer masterDB.Close()
+
+	switch args[0] {
+	case "up":
+		count, err := migrate.Up(ctx, masterDB)
+		if err != nil {
+			return errors.Wrapf(err, "applied %d before failure", count)
+		}
+
+		logger.Info(ctx, "Applied %d migrations", count)
+
+	case "down":
+		if len(args) > 1 {
+			version, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return errors.Wrap(err, "version")
+			}
+
+			count, err := migrate.DownTo(ctx, masterDB, version)
+			if err != nil {
+				return errors.Wrapf(err, "rolled back %d before failure", count)
+			}
+
+			logger.Info(ctx, "Rolled back %d migrations", count)
+			return nil
+		}
+
+		migration, err := migrate.Down(ctx, masterDB)
+		if err != nil {
+			return err
+		}
+
+		if migration == nil {
+			logger.Info(ctx, "No migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := migrate.Statuses(ctx, masterDB)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "Applied\tMigration")
+		for _, status := range statuses {
+			applied := "Pending"
+			if !status.Applied.IsZero() {
+				applied = status.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\n", applied, status.Migration.Name)
+		}
+		return w.Flush()
+
+	default:
+		return errors.Errorf("Unknown migrate command : %s", args[0])
+	}
+
+	return nil
+}
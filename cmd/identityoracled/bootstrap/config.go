@@ -13,6 +13,88 @@ type Config struct {
 		ContractAddress                   string `envconfig:"CONTRACT_ADDRESS" json:"CONTRACT_ADDRESS"`
 		TransferExpirationDurationSeconds int    `default:"21600" envconfig:"TRANSFER_EXPIRATION_DURATION_SECONDS" json:"TRANSFER_EXPIRATION_DURATION_SECONDS"`
 		IdentityExpirationDurationSeconds int    `default:"21600" envconfig:"IDENTITY_EXPIRATION_DURATION_SECONDS" json:"IDENTITY_EXPIRATION_DURATION_SECONDS"`
+		AdminToken                        string `envconfig:"ADMIN_TOKEN" json:"ADMIN_TOKEN" masked:"true"`
+
+		// EntityFieldStrictness overrides how closely each entity field must match when verifying
+		// identities. Format is "Field:strictness,..." where strictness is "exact", "canonical",
+		// or "loose".
+		EntityFieldStrictness map[string]string `envconfig:"ENTITY_FIELD_STRICTNESS" json:"ENTITY_FIELD_STRICTNESS"`
+
+		// EncryptionKey is the hex master key used to encrypt entity data at rest.
+		// PreviousEncryptionKeys are only used to decrypt data during master key rotation.
+		EncryptionKey          string   `envconfig:"ENCRYPTION_KEY" json:"ENCRYPTION_KEY" masked:"true"`
+		PreviousEncryptionKeys []string `envconfig:"PREVIOUS_ENCRYPTION_KEYS" json:"PREVIOUS_ENCRYPTION_KEYS" masked:"true"`
+	}
+	Verification struct {
+		CodeDuration time.Duration `default:"24h" envconfig:"VERIFICATION_CODE_DURATION" json:"VERIFICATION_CODE_DURATION"`
+
+		// DomainChallengeDuration is how long a user has to publish a domain challenge token.
+		DomainChallengeDuration time.Duration `default:"168h" envconfig:"DOMAIN_CHALLENGE_DURATION" json:"DOMAIN_CHALLENGE_DURATION"`
+		DomainTimeout           time.Duration `default:"10s" envconfig:"DOMAIN_TIMEOUT" json:"DOMAIN_TIMEOUT"`
+		PaymailTimeout          time.Duration `default:"10s" envconfig:"PAYMAIL_TIMEOUT" json:"PAYMAIL_TIMEOUT"`
+
+		// RequiredFields are the entity fields that must be verified before an entity including
+		// them is approved. For example "EmailAddress".
+		RequiredFields []string `envconfig:"VERIFICATION_REQUIRED_FIELDS" json:"VERIFICATION_REQUIRED_FIELDS"`
+
+		// TransferRequiredFields are the entity fields that must be verified before transfers to
+		// a user are approved. For example "PhoneNumber".
+		TransferRequiredFields []string `envconfig:"VERIFICATION_TRANSFER_REQUIRED_FIELDS" json:"VERIFICATION_TRANSFER_REQUIRED_FIELDS"`
+
+		// IdentityValidity is how long an approved identity lasts before the user must be
+		// verified again. Zero never expires.
+		IdentityValidity time.Duration `default:"0s" envconfig:"IDENTITY_VALIDITY" json:"IDENTITY_VALIDITY"`
+
+		// FieldValidity is how long each field verification lasts. Format is
+		// "Field:duration,...", for example "EmailAddress:8760h".
+		FieldValidity map[string]string `envconfig:"VERIFICATION_FIELD_VALIDITY" json:"VERIFICATION_FIELD_VALIDITY"`
+
+		// ExpiryNotice is how long before an identity expires that the user is notified.
+		// ExpiryCheckInterval is how often expiring identities are checked for.
+		ExpiryNotice        time.Duration `default:"720h" envconfig:"IDENTITY_EXPIRY_NOTICE" json:"IDENTITY_EXPIRY_NOTICE"`
+		ExpiryCheckInterval time.Duration `default:"1h" envconfig:"IDENTITY_EXPIRY_CHECK_INTERVAL" json:"IDENTITY_EXPIRY_CHECK_INTERVAL"`
+	}
+	Email struct {
+		// Mock keeps emails in memory instead of sending them. Only for development.
+		Mock         bool   `default:"false" envconfig:"EMAIL_MOCK" json:"EMAIL_MOCK"`
+		SMTPHost     string `envconfig:"SMTP_HOST" json:"SMTP_HOST"`
+		SMTPPort     int    `default:"587" envconfig:"SMTP_PORT" json:"SMTP_PORT"`
+		SMTPUsername string `envconfig:"SMTP_USERNAME" json:"SMTP_USERNAME"`
+		SMTPPassword string `envconfig:"SMTP_PASSWORD" json:"SMTP_PASSWORD" masked:"true"`
+		From         string `envconfig:"EMAIL_FROM" json:"EMAIL_FROM"`
+	}
+	SMS struct {
+		// Mock keeps text messages in memory instead of sending them. Only for development.
+		Mock bool `default:"false" envconfig:"SMS_MOCK" json:"SMS_MOCK"`
+
+		// WebhookURL is where text messages are posted to be sent, with WebhookToken as a bearer
+		// token when it is set.
+		WebhookURL     string        `envconfig:"SMS_WEBHOOK_URL" json:"SMS_WEBHOOK_URL"`
+		WebhookToken   string        `envconfig:"SMS_WEBHOOK_TOKEN" json:"SMS_WEBHOOK_TOKEN" masked:"true"`
+		WebhookTimeout time.Duration `default:"10s" envconfig:"SMS_WEBHOOK_TIMEOUT" json:"SMS_WEBHOOK_TIMEOUT"`
+	}
+	Documents struct {
+		// MaxSize is the largest identity document, in bytes, that a user can upload.
+		MaxSize int64 `default:"10485760" envconfig:"DOCUMENT_MAX_SIZE" json:"DOCUMENT_MAX_SIZE"`
+	}
+	Duplicates struct {
+		// Action is what happens when a registration shares an LEI, email address, phone number,
+		// or name and address with an existing user. One of "reject", "flag", or "allow".
+		Action string `default:"flag" envconfig:"DUPLICATE_ACTION" json:"DUPLICATE_ACTION"`
+
+		// Threshold is the minimum similarity, from 0 to 1, of the names and street addresses of
+		// users at the same location for them to be duplicates.
+		Threshold float64 `default:"0.92" envconfig:"DUPLICATE_THRESHOLD" json:"DUPLICATE_THRESHOLD"`
+	}
+	Sanctions struct {
+		// Path is a sanctions list file or a directory of them. Empty disables screening.
+		Path string `envconfig:"SANCTIONS_PATH" json:"SANCTIONS_PATH"`
+
+		// Threshold is the minimum name similarity, from 0 to 1, that is reported as a match.
+		Threshold float64 `default:"0.92" envconfig:"SANCTIONS_THRESHOLD" json:"SANCTIONS_THRESHOLD"`
+
+		// ScreenInterval is how often the lists are reloaded and all users are screened again.
+		ScreenInterval time.Duration `default:"24h" envconfig:"SANCTIONS_SCREEN_INTERVAL" json:"SANCTIONS_SCREEN_INTERVAL"`
 	}
 	Web struct {
 		RootURL         string        `envconfig:"ROOT_URL" json:"ROOT_URL"`
@@ -20,14 +102,78 @@ type Config struct {
 		ReadTimeout     time.Duration `default:"5s" envconfig:"READ_TIMEOUT" json:"READ_TIMEOUT"`
 		WriteTimeout    time.Duration `default:"5s" envconfig:"WRITE_TIMEOUT" json:"WRITE_TIMEOUT"`
 		ShutdownTimeout time.Duration `default:"5s" envconfig:"SHUTDOWN_TIMEOUT" json:"SHUTDOWN_TIMEOUT"`
+
+		// UserLookupFailureLimit is how many failed user lookups a client can make within
+		// UserLookupFailureWindow before it is blocked until the window ends. Zero disables.
+		UserLookupFailureLimit  int           `default:"10" envconfig:"USER_LOOKUP_FAILURE_LIMIT" json:"USER_LOOKUP_FAILURE_LIMIT"`
+		UserLookupFailureWindow time.Duration `default:"15m" envconfig:"USER_LOOKUP_FAILURE_WINDOW" json:"USER_LOOKUP_FAILURE_WINDOW"`
+
+		// TrustedProxies are the addresses or CIDR ranges of proxies in front of the service.
+		// Client addresses used by rate limits and user lookup blocking are only taken from
+		// X-Forwarded-For headers added by them.
+		TrustedProxies []string `envconfig:"TRUSTED_PROXIES" json:"TRUSTED_PROXIES"`
+
+		// APIKeysRequired rejects requests to integrator routes that don't provide an API key.
+		// When false requests without a key are still accepted.
+		APIKeysRequired bool `default:"false" envconfig:"API_KEYS_REQUIRED" json:"API_KEYS_REQUIRED"`
+
+		// MaxHeaderAge is the longest since the latest block header was received that
+		// /health/ready reports the service as ready. Zero doesn't check the age.
+		MaxHeaderAge time.Duration `default:"2h" envconfig:"MAX_HEADER_AGE" json:"MAX_HEADER_AGE"`
+	}
+	RateLimit struct {
+		// Routes are the rate limits of each route. Format is "path:requests/period,...", for
+		// example "/transfer/approve:60/1m". Each API key, client address, and user has their
+		// own limit. Empty disables rate limiting.
+		Routes map[string]string `default:"/transfer/approve:60/1m,/identity/verifyPubKey:60/1m,/identity/verifyXPub:60/1m,/identity/verifyAdmin:60/1m" envconfig:"RATE_LIMIT_ROUTES" json:"RATE_LIMIT_ROUTES"`
+
+		// Store is where limits are kept. "memory" limits each instance separately and "database",
+		// or "postgres" as it was previously named, shares limits between instances through the
+		// database.
+		Store string `default:"memory" envconfig:"RATE_LIMIT_STORE" json:"RATE_LIMIT_STORE"`
+	}
+	Tracing struct {
+		// Exporter is where spans are sent. "zipkin" posts Zipkin v2 JSON, which Jaeger and the
+		// OpenTelemetry collector also accept, "otlp" posts OTLP JSON over HTTP, and "stdout" or
+		// "file" write a JSON line per span. Empty disables tracing.
+		Exporter string `envconfig:"TRACE_EXPORTER" json:"TRACE_EXPORTER"`
+
+		// Endpoint is the URL spans are posted to, for example
+		// "http://localhost:9411/api/v2/spans" or "http://localhost:4318/v1/traces".
+		Endpoint string `envconfig:"TRACE_ENDPOINT" json:"TRACE_ENDPOINT"`
+
+		// File is the file the "file" exporter appends spans to.
+		File string `envconfig:"TRACE_FILE" json:"TRACE_FILE"`
+
+		// SampleRate is the fraction of traces that are sampled, from 0 to 1.
+		SampleRate float64 `default:"0.01" envconfig:"TRACE_SAMPLE_RATE" json:"TRACE_SAMPLE_RATE"`
+
+		// TrustSampled samples every request from a caller that sampled its trace. Only set it
+		// when callers are trusted, since otherwise any caller can have all of its requests
+		// traced.
+		TrustSampled bool `default:"false" envconfig:"TRACE_TRUST_SAMPLED" json:"TRACE_TRUST_SAMPLED"`
+
+		ServiceName string        `default:"identity-oracle" envconfig:"TRACE_SERVICE_NAME" json:"TRACE_SERVICE_NAME"`
+		Timeout     time.Duration `default:"10s" envconfig:"TRACE_TIMEOUT" json:"TRACE_TIMEOUT"`
+	}
+	SpyNode struct {
+		// CheckpointInterval is how often the next spynode message id is saved, in addition to
+		// after each processed message and on shutdown. Zero only saves after messages and on
+		// shutdown.
+		CheckpointInterval time.Duration `default:"1m" envconfig:"SPYNODE_CHECKPOINT_INTERVAL" json:"SPYNODE_CHECKPOINT_INTERVAL"`
 	}
 	Bitcoin struct {
 		Network string `default:"mainnet" envconfig:"BITCOIN_CHAIN" json:"BITCOIN_CHAIN"`
 		IsTest  bool   `default:"true" envconfig:"IS_TEST" json:"IS_TEST"`
 	}
 	Db struct {
+		// Driver is "postgres", or "sqlite3" to keep the database in a local file at URL.
 		Driver string `default:"postgres" envconfig:"DB_DRIVER" json:"DB_DRIVER"`
 		URL    string `default:"user=foo dbname=bar sslmode=disable" envconfig:"DB_URL" json:"DB_URL" masked:"true"`
+
+		// AutoMigrate applies the migrations that haven't been applied on start. The service
+		// doesn't start when migrations are pending.
+		AutoMigrate bool `default:"false" envconfig:"DB_AUTO_MIGRATE" json:"DB_AUTO_MIGRATE"`
 	}
 	Storage struct {
 		Region    string `default:"ap-southeast-2" envconfig:"STORAGE_REGION" json:"STORAGE_REGION"`
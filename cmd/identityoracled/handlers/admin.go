@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// Admin provides operator functions. All routes are protected by the admin token.
+type Admin struct {
+	Config   *web.Config
+	Store    oracle.Store
+	Validity *oracle.Validity
+}
+
+// RecoverKey replaces a user's authentication public key without a signature from the current
+// key. It is used when a user has lost their key and has been identified by other means.
+func (a *Admin) RecoverKey(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.RecoverKey")
+	defer span.End()
+
+	var requestData struct {
+		UserID    string            `json:"user_id" validate:"required"`
+		PublicKey bitcoin.PublicKey `json:"public_key" validate:"required"`
+		Reason    string            `json:"reason" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.Stringer("public_key", requestData.PublicKey),
+		logger.String("reason", requestData.Reason),
+	}, "Recovering user key")
+
+	user, err := oracle.FetchUser(ctx, a.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	if err := oracle.RotatePublicKey(ctx, a.Store, user, requestData.PublicKey,
+		requestData.Reason); err != nil {
+		return translate(errors.Wrap(err, "rotate public key"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// PublicKeys returns the authentication key history of a user. When "at" is specified only the
+// key that was active at that time is returned, for example to check an old signature.
+func (a *Admin) PublicKeys(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.PublicKeys")
+	defer span.End()
+
+	var requestData struct {
+		UserID string     `json:"user_id" validate:"required"`
+		At     *time.Time `json:"at"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if _, err := oracle.FetchUser(ctx, a.Store, requestData.UserID); err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	var keys []*oracle.PublicKey
+	if requestData.At != nil {
+		key, err := oracle.FetchPublicKeyAt(ctx, a.Store, requestData.UserID, *requestData.At)
+		if err != nil {
+			return translate(errors.Wrap(err, "fetch public key"))
+		}
+		keys = append(keys, key)
+	} else {
+		var err error
+		keys, err = oracle.FetchPublicKeys(ctx, a.Store, requestData.UserID)
+		if err != nil {
+			return translate(errors.Wrap(err, "fetch public keys"))
+		}
+	}
+
+	response := struct {
+		PublicKeys []*oracle.PublicKey `json:"public_keys"`
+	}{
+		PublicKeys: keys,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// IdentityHistory returns the identities a user has submitted along with the approval decision
+// for each. When "at" is specified only the identity the oracle held at that time is returned.
+func (a *Admin) IdentityHistory(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.IdentityHistory")
+	defer span.End()
+
+	var requestData struct {
+		UserID string     `json:"user_id" validate:"required"`
+		At     *time.Time `json:"at"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	var userEntities []*oracle.UserEntity
+	if requestData.At != nil {
+		userEntity, err := oracle.FetchUserEntityAt(ctx, a.Store, requestData.UserID,
+			*requestData.At)
+		if err != nil {
+			return translate(errors.Wrap(err, "fetch user entity"))
+		}
+		userEntities = append(userEntities, userEntity)
+	} else {
+		var err error
+		userEntities, err = oracle.FetchUserEntities(ctx, a.Store, requestData.UserID)
+		if err != nil {
+			return translate(errors.Wrap(err, "fetch user entities"))
+		}
+	}
+
+	type historyItem struct {
+		Entity      actions.EntityField `json:"entity"`
+		Signature   string              `json:"signature"`
+		Approved    bool                `json:"approved"`
+		Description string              `json:"description"`
+		DateCreated time.Time           `json:"date_created"`
+	}
+
+	history := make([]historyItem, 0, len(userEntities))
+	for _, userEntity := range userEntities {
+		item := historyItem{
+			Signature:   hex.EncodeToString(userEntity.Signature),
+			Approved:    userEntity.Approved,
+			Description: userEntity.Description,
+			DateCreated: userEntity.DateCreated,
+		}
+
+		if err := proto.Unmarshal(userEntity.Entity, &item.Entity); err != nil {
+			return translate(errors.Wrap(err, "unmarshal entity"))
+		}
+
+		history = append(history, item)
+	}
+
+	response := struct {
+		UserID  string        `json:"user_id"`
+		History []historyItem `json:"history"`
+	}{
+		UserID:  requestData.UserID,
+		History: history,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// Verifications returns the verification state of the fields in a user's current identity and
+// the history of their field verifications.
+func (a *Admin) Verifications(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.Verifications")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	user, err := oracle.FetchUser(ctx, a.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	verified, err := oracle.FetchVerifiedFields(ctx, a.Store, user.ID, entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch verified fields"))
+	}
+
+	verifications, err := oracle.FetchFieldVerifications(ctx, a.Store, user.ID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch verifications"))
+	}
+
+	response := struct {
+		UserID        string                      `json:"user_id"`
+		Verified      map[string]bool             `json:"verified"`
+		Verifications []*oracle.FieldVerification `json:"verifications"`
+	}{
+		UserID:        user.ID,
+		Verified:      verified,
+		Verifications: verifications,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// SetVerificationLevel sets the KYC tier of a user.
+func (a *Admin) SetVerificationLevel(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.SetVerificationLevel")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+		Level  int    `json:"level" validate:"min=0"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.Int("level", requestData.Level),
+	}, "Setting verification level")
+
+	if err := oracle.SetVerificationLevel(ctx, a.Store, requestData.UserID,
+		requestData.Level); err != nil {
+		return translate(errors.Wrap(err, "set verification level"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// SetAttribute sets an attribute flag on a user. The attribute never expires when date_expires
+// isn't specified.
+func (a *Admin) SetAttribute(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.SetAttribute")
+	defer span.End()
+
+	var requestData struct {
+		UserID      string     `json:"user_id" validate:"required"`
+		Attribute   string     `json:"attribute" validate:"required"`
+		DateExpires *time.Time `json:"date_expires"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("attribute", requestData.Attribute),
+	}, "Setting user attribute")
+
+	if err := oracle.SetUserAttribute(ctx, a.Store, &oracle.UserAttribute{
+		UserID:      requestData.UserID,
+		Attribute:   requestData.Attribute,
+		DateExpires: requestData.DateExpires,
+		SetBy:       oracle.SetByAdmin,
+	}); err != nil {
+		return translate(errors.Wrap(err, "set attribute"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// RemoveAttribute removes an attribute flag from a user.
+func (a *Admin) RemoveAttribute(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.RemoveAttribute")
+	defer span.End()
+
+	var requestData struct {
+		UserID    string `json:"user_id" validate:"required"`
+		Attribute string `json:"attribute" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("attribute", requestData.Attribute),
+	}, "Removing user attribute")
+
+	if err := oracle.RemoveUserAttribute(ctx, a.Store, requestData.UserID,
+		requestData.Attribute); err != nil {
+		return translate(errors.Wrap(err, "remove attribute"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// SetInstrumentRequirement sets the verification level and attributes a user needs before
+// transfers of an instrument to them are approved.
+func (a *Admin) SetInstrumentRequirement(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.SetInstrumentRequirement")
+	defer span.End()
+
+	var requestData struct {
+		Contract             string   `json:"contract" validate:"required"`
+		InstrumentID         string   `json:"instrument_id" validate:"required"`
+		MinVerificationLevel int      `json:"min_verification_level" validate:"min=0"`
+		RequiredAttributes   []string `json:"required_attributes"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("contract", requestData.Contract),
+		logger.String("instrument_id", requestData.InstrumentID),
+		logger.Int("min_verification_level", requestData.MinVerificationLevel),
+		logger.Strings("required_attributes", requestData.RequiredAttributes),
+	}, "Setting instrument requirement")
+
+	if err := oracle.SetInstrumentRequirement(ctx, a.Store, &oracle.InstrumentRequirement{
+		Contract:             requestData.Contract,
+		InstrumentID:         requestData.InstrumentID,
+		MinVerificationLevel: requestData.MinVerificationLevel,
+		RequiredAttributes:   requestData.RequiredAttributes,
+	}); err != nil {
+		return translate(errors.Wrap(err, "set instrument requirement"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// Documents returns the metadata of the identity documents a user has uploaded.
+func (a *Admin) Documents(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.Documents")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if _, err := oracle.FetchUser(ctx, a.Store, requestData.UserID); err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	documents, err := oracle.FetchDocuments(ctx, a.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch documents"))
+	}
+
+	response := struct {
+		UserID    string             `json:"user_id"`
+		Documents []*oracle.Document `json:"documents"`
+	}{
+		UserID:    requestData.UserID,
+		Documents: documents,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// Document returns an identity document with its content.
+func (a *Admin) Document(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.Document")
+	defer span.End()
+
+	var requestData struct {
+		DocumentID string `json:"document_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("document_id", requestData.DocumentID),
+	}, "Retrieving document")
+
+	document, err := oracle.FetchDocument(ctx, a.Store, requestData.DocumentID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch document"))
+	}
+
+	content, err := oracle.FetchDocumentContent(ctx, a.Store, document)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch document content"))
+	}
+
+	response := struct {
+		*oracle.Document
+		Content []byte `json:"content"`
+	}{
+		Document: document,
+		Content:  content,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// RenewIdentity restarts the validity period of a user's identity after the operator has verified
+// them again.
+func (a *Admin) RenewIdentity(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.RenewIdentity")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+	}, "Renewing identity")
+
+	expires, err := oracle.RenewIdentity(ctx, a.Store, a.Validity, requestData.UserID, time.Now())
+	if err != nil {
+		return translate(errors.Wrap(err, "renew identity"))
+	}
+
+	response := struct {
+		UserID              string     `json:"user_id"`
+		DateIdentityExpires *time.Time `json:"date_identity_expires,omitempty"`
+	}{
+		UserID:              requestData.UserID,
+		DateIdentityExpires: expires,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// ScreeningHits returns sanctions screening hits, optionally only those of a user or with a status.
+func (a *Admin) ScreeningHits(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.ScreeningHits")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	hits, err := oracle.FetchScreeningHits(ctx, a.Store, requestData.UserID, requestData.Status)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch screening hits"))
+	}
+
+	response := struct {
+		Hits []*oracle.ScreeningHit `json:"hits"`
+	}{
+		Hits: hits,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// ReviewScreeningHit records the operator's review of a sanctions screening hit. A confirmed hit
+// denies the user's identity and transfers to them. A dismissed hit no longer affects the user.
+func (a *Admin) ReviewScreeningHit(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.ReviewScreeningHit")
+	defer span.End()
+
+	var requestData struct {
+		ID     string `json:"id" validate:"required"`
+		Status string `json:"status" validate:"required"`
+		Note   string `json:"note"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("id", requestData.ID),
+		logger.String("status", requestData.Status),
+	}, "Reviewing screening hit")
+
+	hit, err := oracle.ReviewScreeningHit(ctx, a.Store, requestData.ID, requestData.Status,
+		requestData.Note)
+	if err != nil {
+		return translate(errors.Wrap(err, "review screening hit"))
+	}
+
+	web.RespondData(ctx, w, hit, http.StatusOK)
+	return nil
+}
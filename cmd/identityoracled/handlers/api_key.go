@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tokenized/identity-oracle/internal/mid"
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// apiKeyLookup returns a function that finds the integrator with an API key in the store.
+func apiKeyLookup(store oracle.Store) mid.APIKeyLookup {
+	return func(ctx context.Context, key string) (*mid.Integrator, error) {
+		apiKey, err := oracle.FetchAPIKeyByKey(ctx, store, key)
+		if err != nil {
+			if errors.Cause(err) == oracle.ErrAPIKeyNotFound {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		return &mid.Integrator{
+			KeyID:  apiKey.ID,
+			Name:   apiKey.Name,
+			Scopes: apiKey.Scopes,
+		}, nil
+	}
+}
+
+// CreateAPIKey creates an API key for an integrator. The key is only returned in this response.
+func (a *Admin) CreateAPIKey(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.CreateAPIKey")
+	defer span.End()
+
+	var requestData struct {
+		Name   string   `json:"name" validate:"required"`
+		Scopes []string `json:"scopes" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("name", requestData.Name),
+		logger.Strings("scopes", requestData.Scopes),
+	}, "Creating API key")
+
+	apiKey, key, err := oracle.CreateAPIKey(ctx, a.Store, requestData.Name, requestData.Scopes)
+	if err != nil {
+		return translate(errors.Wrap(err, "create api key"))
+	}
+
+	response := struct {
+		*oracle.APIKey
+		Key string `json:"key"`
+	}{
+		APIKey: apiKey,
+		Key:    key,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// APIKeys returns all integrator API keys, without the keys themselves.
+func (a *Admin) APIKeys(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.APIKeys")
+	defer span.End()
+
+	apiKeys, err := oracle.FetchAPIKeys(ctx, a.Store)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch api keys"))
+	}
+
+	response := struct {
+		APIKeys []*oracle.APIKey `json:"api_keys"`
+	}{
+		APIKeys: apiKeys,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// RevokeAPIKey stops an integrator's API key from being accepted.
+func (a *Admin) RevokeAPIKey(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.RevokeAPIKey")
+	defer span.End()
+
+	var requestData struct {
+		ID string `json:"id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("id", requestData.ID),
+	}, "Revoking API key")
+
+	if err := oracle.RevokeAPIKey(ctx, a.Store, requestData.ID); err != nil {
+		return translate(errors.Wrap(err, "revoke api key"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// UploadDocument stores an identity document, like a passport scan, for review by the operator.
+// The user signs the SHA256 of the content so the document is tied to their key.
+func (o *Oracle) UploadDocument(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.UploadDocument")
+	defer span.End()
+
+	// The content is base64 encoded in the request so allow for the expansion plus the other
+	// fields.
+	maxRequestSize := (o.MaxDocumentSize/3+1)*4 + 64*1024
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return translate(errors.Wrap(err, "read request"))
+	}
+
+	if int64(len(body)) > maxRequestSize {
+		return translate(errors.Wrapf(oracle.ErrDocumentTooLarge, "max %d bytes",
+			o.MaxDocumentSize))
+	}
+
+	var requestData struct {
+		UserID       string            `json:"user_id" validate:"required"`
+		DocumentType string            `json:"document_type" validate:"required"`
+		FileName     string            `json:"file_name"`
+		ContentType  string            `json:"content_type"`
+		Content      []byte            `json:"content" validate:"required"`
+		Signature    bitcoin.Signature `json:"signature" validate:"required"`
+	}
+
+	if err := web.Unmarshal(bytes.NewReader(body), &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if int64(len(requestData.Content)) > o.MaxDocumentSize {
+		return translate(errors.Wrapf(oracle.ErrDocumentTooLarge, "max %d bytes",
+			o.MaxDocumentSize))
+	}
+
+	if err := oracle.ValidateDocumentType(requestData.DocumentType); err != nil {
+		return translate(errors.Wrap(err, "document type"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("document_type", requestData.DocumentType),
+		logger.Int("size", len(requestData.Content)),
+	}, "Uploading document")
+
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	userID, err := uuid.Parse(requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "parse user id"))
+	}
+
+	document := &oracle.Document{
+		UserID:       user.ID,
+		DocumentType: requestData.DocumentType,
+		FileName:     requestData.FileName,
+		ContentType:  requestData.ContentType,
+		Signature:    requestData.Signature.Bytes(),
+	}
+
+	contentHash := sha256.Sum256(requestData.Content)
+	hash := oracle.DocumentSigHash(userID, requestData.DocumentType, contentHash[:])
+	if !requestData.Signature.Verify(hash, user.PublicKey) {
+		return translate(oracle.ErrInvalidSignature)
+	}
+
+	if err := oracle.CreateDocument(ctx, o.Store, document, requestData.Content); err != nil {
+		return translate(errors.Wrap(err, "create document"))
+	}
+
+	logger.Info(ctx, "Created document : %s", document.ID)
+
+	response := struct {
+		DocumentID  string `json:"document_id"`
+		ContentHash string `json:"content_hash"`
+	}{
+		DocumentID:  document.ID,
+		ContentHash: hex.EncodeToString(document.ContentHash),
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
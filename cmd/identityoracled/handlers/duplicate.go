@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// checkDuplicates returns the existing users that share identifying information with the entity.
+// Returns ErrDuplicateIdentity when duplicates are rejected.
+func (o *Oracle) checkDuplicates(ctx context.Context, userID string,
+	entity *actions.EntityField) ([]*oracle.DuplicateIdentity, error) {
+
+	if len(o.DuplicateAction) == 0 || o.DuplicateAction == oracle.DuplicateActionAllow {
+		return nil, nil
+	}
+
+	duplicates, err := oracle.FindDuplicates(ctx, o.Store, userID, entity,
+		o.DuplicateThreshold)
+	if err != nil {
+		return nil, errors.Wrap(err, "find duplicates")
+	}
+
+	if len(duplicates) == 0 {
+		return nil, nil
+	}
+
+	kinds := oracle.DuplicateKinds(duplicates)
+	logger.WarnWithFields(ctx, []logger.Field{
+		logger.String("user_id", userID),
+		logger.String("kinds", kinds),
+		logger.Int("count", len(duplicates)),
+	}, "Duplicate identity")
+
+	if o.DuplicateAction == oracle.DuplicateActionReject {
+		return nil, errors.Wrap(oracle.ErrDuplicateIdentity, kinds)
+	}
+
+	return duplicates, nil
+}
+
+// recordDuplicates stores the fingerprints of the user's identity and flags the duplicates for
+// review. Failures are logged because the identity has already been saved and fingerprints are
+// replaced when the identity is updated.
+func (o *Oracle) recordDuplicates(ctx context.Context, userID string,
+	entity *actions.EntityField, duplicates []*oracle.DuplicateIdentity) {
+
+	if err := oracle.SetFingerprints(ctx, o.Store, userID, entity); err != nil {
+		logger.Error(ctx, "Failed to set identity fingerprints : %s", err)
+	}
+
+	if len(duplicates) == 0 {
+		return
+	}
+
+	if err := oracle.FlagDuplicates(ctx, o.Store, duplicates); err != nil {
+		logger.Error(ctx, "Failed to flag duplicate identities : %s", err)
+	}
+}
+
+// Duplicates returns users flagged as sharing identifying information, optionally only those
+// involving a user or with a status.
+func (a *Admin) Duplicates(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.Duplicates")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	duplicates, err := oracle.FetchDuplicates(ctx, a.Store, requestData.UserID,
+		requestData.Status)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch duplicates"))
+	}
+
+	response := struct {
+		Duplicates []*oracle.DuplicateIdentity `json:"duplicates"`
+	}{
+		Duplicates: duplicates,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// ReviewDuplicate records that flagged users are linked accounts of the same party, or that the
+// duplicate is dismissed because they are different parties.
+func (a *Admin) ReviewDuplicate(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.ReviewDuplicate")
+	defer span.End()
+
+	var requestData struct {
+		ID     string `json:"id" validate:"required"`
+		Status string `json:"status" validate:"required"`
+		Note   string `json:"note"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("id", requestData.ID),
+		logger.String("status", requestData.Status),
+	}, "Reviewing duplicate")
+
+	duplicate, err := oracle.ReviewDuplicate(ctx, a.Store, requestData.ID, requestData.Status,
+		requestData.Note)
+	if err != nil {
+		return translate(errors.Wrap(err, "review duplicate"))
+	}
+
+	web.RespondData(ctx, w, duplicate, http.StatusOK)
+	return nil
+}
+
+// MergeDuplicate merges flagged users into one. The other user's xpubs are moved to the kept user
+// and the other user is deleted.
+func (a *Admin) MergeDuplicate(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Admin.MergeDuplicate")
+	defer span.End()
+
+	var requestData struct {
+		ID         string `json:"id" validate:"required"`
+		KeepUserID string `json:"keep_user_id" validate:"required"`
+		Note       string `json:"note"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("id", requestData.ID),
+		logger.String("keep_user_id", requestData.KeepUserID),
+	}, "Merging duplicate")
+
+	duplicate, err := oracle.MergeDuplicate(ctx, a.Store, requestData.ID, requestData.KeepUserID,
+		requestData.Note)
+	if err != nil {
+		return translate(errors.Wrap(err, "merge duplicate"))
+	}
+
+	web.RespondData(ctx, w, duplicate, http.StatusOK)
+	return nil
+}
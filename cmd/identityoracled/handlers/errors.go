@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 
 	"github.com/pkg/errors"
@@ -11,6 +12,14 @@ import (
 // them into web errors. We are losing the trace when this
 // error is converted. But we don't log traces for these.
 func translate(err error) error {
+	if fields, ok := errors.Cause(err).(oracle.InvalidFields); ok {
+		invalid := make(web.InvalidError, 0, len(fields))
+		for _, field := range fields {
+			invalid = append(invalid, web.Invalid{Fld: field.Field, Err: field.Reason})
+		}
+		return invalid
+	}
+
 	switch errors.Cause(err) {
 	case oracle.ErrXPubNotFound:
 		return errors.Wrap(web.ErrNotFound, err.Error())
@@ -18,6 +27,52 @@ func translate(err error) error {
 		return errors.Wrap(web.ErrNotFound, err.Error())
 	case oracle.ErrInvalidSignature:
 		return errors.Wrap(web.ErrUnauthorized, err.Error())
+	case oracle.ErrPublicKeyNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrPublicKeyUnchanged:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrPublicKeyReused:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrUserEntityNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrVerificationNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrVerificationExpired:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrInvalidVerificationCode:
+		return errors.Wrap(web.ErrUnauthorized, err.Error())
+	case oracle.ErrVerificationAttempts:
+		return errors.Wrap(web.ErrForbidden, err.Error())
+	case oracle.ErrVerificationTokenNotPublished:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrPaymailKeyMismatch:
+		return errors.Wrap(web.ErrUnauthorized, err.Error())
+	case oracle.ErrInvalidAttribute:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrDocumentNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrInvalidDocumentType:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrDocumentTooLarge:
+		return errors.Wrap(web.ErrRequestTooLarge, err.Error())
+	case oracle.ErrScreeningHitNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrInvalidReviewStatus:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrDuplicateIdentity:
+		return errors.Wrap(web.ErrForbidden, err.Error())
+	case oracle.ErrDuplicateNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrInvalidMergeUser:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case oracle.ErrAPIKeyNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case oracle.ErrInvalidScope:
+		return errors.Wrap(web.ErrValidation, err.Error())
+	case paymail.ErrPaymailNotFound:
+		return errors.Wrap(web.ErrNotFound, err.Error())
+	case paymail.ErrInvalidHandle, paymail.ErrCapabilityNotFound, paymail.ErrInvalidPaymailResult:
+		return errors.Wrap(web.ErrValidation, err.Error())
 	}
 	return err
 }
@@ -2,16 +2,28 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/identity-oracle/internal/platform/sanctions"
+	"github.com/tokenized/identity-oracle/internal/platform/sms"
 	"github.com/tokenized/identity-oracle/internal/platform/tests"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
@@ -20,20 +32,22 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 func TestRegister(t *testing.T) {
 	ctx := tests.Context()
 	test := tests.New()
+	store := oracle.NewMemoryStore()
 
 	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
 		t.Fatalf("Failed to generate oracle key : %s", err)
 	}
 	handler := &Oracle{
-		Config:   test.WebConfig,
-		MasterDB: test.MasterDB,
-		Key:      oracleKey,
+		Config: test.WebConfig,
+		Store:  store,
+		Key:    oracleKey,
 	}
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
@@ -105,7 +119,7 @@ func TestRegister(t *testing.T) {
 	t.Logf("Status  : %s", responseData.Data.Status)
 	t.Logf("User ID : %s", responseData.Data.UserID)
 
-	ruser, err := oracle.FetchUser(ctx, test.MasterDB, responseData.Data.UserID.String())
+	ruser, err := oracle.FetchUser(ctx, store, responseData.Data.UserID.String())
 	if err != nil {
 		t.Fatalf("Failed to fetch user : %s", err)
 	}
@@ -123,15 +137,16 @@ func TestRegister(t *testing.T) {
 func TestAddXPub(t *testing.T) {
 	ctx := tests.Context()
 	test := tests.New()
+	store := oracle.NewMemoryStore()
 
 	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
 		t.Fatalf("Failed to generate oracle key : %s", err)
 	}
 	handler := &Oracle{
-		Config:   test.WebConfig,
-		MasterDB: test.MasterDB,
-		Key:      oracleKey,
+		Config: test.WebConfig,
+		Store:  store,
+		Key:    oracleKey,
 	}
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
@@ -160,7 +175,7 @@ func TestAddXPub(t *testing.T) {
 		IsDeleted:    false,
 	}
 
-	if err := oracle.CreateUser(ctx, test.MasterDB, user); err != nil {
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
 		t.Fatalf("Failed to create user : %s", err)
 	}
 
@@ -221,7 +236,7 @@ func TestAddXPub(t *testing.T) {
 		t.Fatalf("Response is not success : %d", response.StatusCode)
 	}
 
-	ruserID, err := oracle.FetchUserIDByXPub(ctx, test.MasterDB, xkeys.ExtendedPublicKeys())
+	ruserID, err := oracle.FetchUserIDByXPub(ctx, store, xkeys.ExtendedPublicKeys())
 	if err != nil {
 		t.Fatalf("Failed to fetch xpub user : %s", err)
 	}
@@ -233,15 +248,16 @@ func TestAddXPub(t *testing.T) {
 
 func TestAddXPubNoUser(t *testing.T) {
 	test := tests.New()
+	store := oracle.NewMemoryStore()
 
 	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
 		t.Fatalf("Failed to generate oracle key : %s", err)
 	}
 	handler := &Oracle{
-		Config:   test.WebConfig,
-		MasterDB: test.MasterDB,
-		Key:      oracleKey,
+		Config: test.WebConfig,
+		Store:  store,
+		Key:    oracleKey,
 	}
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
@@ -295,7 +311,7 @@ func TestAddXPubNoUser(t *testing.T) {
 	app := web.New(test.WebConfig, mid.ErrorHandler, mid.CORS)
 	app.Handle("POST", "/oracle/addXPub", handler.AddXPub)
 
-	requestLogger := mid.NewRequestLoggingMiddleware(logger.NewConfig(false, false, ""))
+	requestLogger := mid.NewRequestLoggingMiddleware(logger.NewConfig(false, false, ""), nil)
 	webHandler := requestLogger.Handler(app)
 
 	// create a ResponseRecorder to record the response.
@@ -311,15 +327,16 @@ func TestAddXPubNoUser(t *testing.T) {
 func TestAddXPubBadSignature(t *testing.T) {
 	ctx := tests.Context()
 	test := tests.New()
+	store := oracle.NewMemoryStore()
 
 	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
 		t.Fatalf("Failed to generate oracle key : %s", err)
 	}
 	handler := &Oracle{
-		Config:   test.WebConfig,
-		MasterDB: test.MasterDB,
-		Key:      oracleKey,
+		Config: test.WebConfig,
+		Store:  store,
+		Key:    oracleKey,
 	}
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
@@ -348,7 +365,7 @@ func TestAddXPubBadSignature(t *testing.T) {
 		IsDeleted:    false,
 	}
 
-	if err := oracle.CreateUser(ctx, test.MasterDB, user); err != nil {
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
 		t.Fatalf("Failed to create user : %s", err)
 	}
 
@@ -402,7 +419,7 @@ func TestAddXPubBadSignature(t *testing.T) {
 	app := web.New(test.WebConfig, mid.ErrorHandler, mid.CORS)
 	app.Handle("POST", "/oracle/addXPub", handler.AddXPub)
 
-	requestLogger := mid.NewRequestLoggingMiddleware(logger.NewConfig(false, false, ""))
+	requestLogger := mid.NewRequestLoggingMiddleware(logger.NewConfig(false, false, ""), nil)
 	webHandler := requestLogger.Handler(app)
 
 	// create a ResponseRecorder to record the response.
@@ -418,15 +435,21 @@ func TestAddXPubBadSignature(t *testing.T) {
 func TestUpdateEntity(t *testing.T) {
 	ctx := tests.Context()
 	test := tests.New()
+	store := oracle.NewMemoryStore()
 
 	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
 		t.Fatalf("Failed to generate oracle key : %s", err)
 	}
+	validity, err := oracle.NewValidity(time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Failed to create validity : %s", err)
+	}
 	handler := &Oracle{
 		Config:   test.WebConfig,
-		MasterDB: test.MasterDB,
+		Store:    store,
 		Key:      oracleKey,
+		Validity: validity,
 	}
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
@@ -445,17 +468,19 @@ func TestUpdateEntity(t *testing.T) {
 	}
 
 	userID := uuid.New()
+	expires := time.Now().Add(time.Minute).Truncate(time.Second)
 
 	user := &oracle.User{
-		ID:           userID.String(),
-		Entity:       entityBytes,
-		PublicKey:    key.PublicKey(),
-		DateCreated:  time.Now(),
-		DateModified: time.Now(),
-		IsDeleted:    false,
+		ID:                  userID.String(),
+		Entity:              entityBytes,
+		PublicKey:           key.PublicKey(),
+		DateCreated:         time.Now(),
+		DateModified:        time.Now(),
+		DateIdentityExpires: &expires,
+		IsDeleted:           false,
 	}
 
-	if err := oracle.CreateUser(ctx, test.MasterDB, user); err != nil {
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
 		t.Fatalf("Failed to create user : %s", err)
 	}
 
@@ -511,7 +536,7 @@ func TestUpdateEntity(t *testing.T) {
 		t.Fatalf("Response is not success : %d", response.StatusCode)
 	}
 
-	ruser, err := oracle.FetchUser(ctx, test.MasterDB, requestData.UserID)
+	ruser, err := oracle.FetchUser(ctx, store, requestData.UserID)
 	if err != nil {
 		t.Fatalf("Failed to fetch user : %s", err)
 	}
@@ -528,4 +553,2018 @@ func TestUpdateEntity(t *testing.T) {
 	if rentity.CountryCode != "USA" {
 		t.Errorf("Wrong country code : got %s, want %s", rentity.CountryCode, "USA")
 	}
+
+	// Updates the approver doesn't report as re-verifications don't restart the validity period.
+	if ruser.DateIdentityExpires == nil || !ruser.DateIdentityExpires.Equal(expires) {
+		t.Errorf("Identity expiry changed : got %v, want %s", ruser.DateIdentityExpires, expires)
+	}
+
+	history, err := oracle.FetchUserEntities(ctx, store, requestData.UserID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user entities : %s", err)
+	}
+
+	if len(history) != 1 {
+		t.Fatalf("Wrong identity history count : got %d, want %d", len(history), 1)
+	}
+
+	if !history[0].Approved {
+		t.Errorf("Identity history entry not approved")
+	}
+
+	if !bytes.Equal(history[0].Entity, ruser.Entity) {
+		t.Errorf("Wrong identity history entity")
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	handler := &Oracle{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	newKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate new user key : %s", err)
+	}
+
+	userID := uuid.New()
+	user := &oracle.User{
+		ID:           userID.String(),
+		Entity:       []byte{},
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	rotate := func(publicKey bitcoin.PublicKey, signer bitcoin.Key, signed time.Time) error {
+		timestamp := uint64(signed.Unix())
+
+		s := sha256.New()
+		s.Write(userID[:])
+		s.Write(publicKey.Bytes())
+		if err := binary.Write(s, binary.LittleEndian, timestamp); err != nil {
+			t.Fatalf("Failed to hash timestamp : %s", err)
+		}
+
+		signature, err := signer.Sign(sha256.Sum256(s.Sum(nil)))
+		if err != nil {
+			t.Fatalf("Failed to sign rotation : %s", err)
+		}
+
+		b, err := json.Marshal(struct {
+			UserID    string            `json:"user_id"`
+			PublicKey bitcoin.PublicKey `json:"public_key"`
+			Timestamp uint64            `json:"timestamp"`
+			Signature bitcoin.Signature `json:"signature"`
+		}{
+			UserID:    user.ID,
+			PublicKey: publicKey,
+			Timestamp: timestamp,
+			Signature: signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/oracle/rotateKey",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler.RotateKey(ctx, response, request, map[string]string{})
+	}
+
+	// Old signatures can't be replayed.
+	if err := rotate(newKey.PublicKey(), key, time.Now().Add(-time.Hour)); errors.Cause(err) !=
+		web.ErrUnauthorized {
+		t.Fatalf("Rotation with old timestamp should be unauthorized : %v", err)
+	}
+
+	beforeRotate := time.Now()
+	time.Sleep(time.Millisecond)
+
+	if err := rotate(newKey.PublicKey(), key, time.Now()); err != nil {
+		t.Fatalf("Failed to rotate key : %s", err)
+	}
+
+	// The previous key was revoked so it can't be rotated back to.
+	if err := rotate(key.PublicKey(), newKey, time.Now()); errors.Cause(err) !=
+		web.ErrValidation {
+		t.Fatalf("Rotation to previous key should be invalid : %v", err)
+	}
+
+	ruser, err := oracle.FetchUser(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user : %s", err)
+	}
+
+	if !bytes.Equal(ruser.PublicKey.Bytes(), newKey.PublicKey().Bytes()) {
+		t.Fatalf("User public key not rotated")
+	}
+
+	// The key active before the rotation can be found to check old signatures.
+	admin := &Admin{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	b, err := json.Marshal(struct {
+		UserID string    `json:"user_id"`
+		At     time.Time `json:"at"`
+	}{
+		UserID: user.ID,
+		At:     beforeRotate,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/admin/publicKeys",
+		bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := admin.PublicKeys(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to fetch public keys : %s", err)
+	}
+
+	var responseData struct {
+		Data struct {
+			PublicKeys []struct {
+				PublicKey string `json:"public_key"`
+			} `json:"public_keys"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	if len(responseData.Data.PublicKeys) != 1 ||
+		responseData.Data.PublicKeys[0].PublicKey != key.PublicKey().String() {
+		t.Fatalf("Wrong public key at time : %+v", responseData.Data.PublicKeys)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate oracle key : %s", err)
+	}
+
+	mailer := mail.NewMockMailer()
+	handler := &Oracle{
+		Config:                   test.WebConfig,
+		Store:                    store,
+		Key:                      oracleKey,
+		Mailer:                   mailer,
+		VerificationCodeDuration: time.Hour,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entity := &actions.EntityField{
+		Name:         "Test Entity Name",
+		CountryCode:  "AUS",
+		EmailAddress: "test@tokenized.com",
+	}
+
+	entityBytes, err := proto.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	handler.sendVerifications(ctx, user.ID, entity)
+
+	message := mailer.LastMessage(entity.EmailAddress)
+	if message == nil {
+		t.Fatalf("Verification email not sent")
+	}
+
+	requirements, err := oracle.NewRequirements([]string{"EmailAddress"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create requirements : %s", err)
+	}
+
+	unmet, err := requirements.Check(ctx, store, user.ID, entity, entity)
+	if err != nil {
+		t.Fatalf("Failed to check requirements : %s", err)
+	}
+
+	if len(unmet) != 1 || unmet[0].Code != oracle.MismatchNotVerified {
+		t.Fatalf("Unverified email should not meet requirements : %+v", unmet)
+	}
+
+	// The code is the last word of the first line.
+	words := strings.Fields(strings.Split(message.Body, "\n")[0])
+	code := words[len(words)-1]
+
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate other key : %s", err)
+	}
+
+	verify := func(code string, signer bitcoin.Key) error {
+		signature, err := signer.Sign(oracle.VerificationSigHash(uuid.MustParse(user.ID),
+			"EmailAddress", code))
+		if err != nil {
+			t.Fatalf("Failed to sign code : %s", err)
+		}
+
+		b, err := json.Marshal(struct {
+			UserID    string            `json:"user_id"`
+			Code      string            `json:"code"`
+			Signature bitcoin.Signature `json:"signature"`
+		}{
+			UserID:    user.ID,
+			Code:      code,
+			Signature: signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/verifyEmail",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler.VerifyEmail(ctx, response, request, map[string]string{})
+	}
+
+	// Codes that aren't signed by the user don't use up the verification's attempts.
+	for i := 0; i <= oracle.MaxVerificationAttempts; i++ {
+		if err := verify(code, otherKey); errors.Cause(err) != web.ErrUnauthorized {
+			t.Fatalf("Code signed by another key should be unauthorized : %v", err)
+		}
+	}
+
+	if err := verify("not a code", key); err == nil {
+		t.Fatalf("Invalid code should not verify email")
+	}
+
+	if err := verify(code, key); err != nil {
+		t.Fatalf("Failed to verify email : %s", err)
+	}
+
+	unmet, err = requirements.Check(ctx, store, user.ID, entity, entity)
+	if err != nil {
+		t.Fatalf("Failed to check requirements : %s", err)
+	}
+
+	if len(unmet) != 0 {
+		t.Fatalf("Verified email should meet requirements : %+v", unmet)
+	}
+}
+
+func TestVerifyPhone(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate oracle key : %s", err)
+	}
+
+	sender := sms.NewMockSender()
+	handler := &Oracle{
+		Config:                   test.WebConfig,
+		Store:                    store,
+		Key:                      oracleKey,
+		SMSSender:                sender,
+		VerificationCodeDuration: time.Hour,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entity := &actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+		PhoneNumber: "+61299990000",
+	}
+
+	entityBytes, err := proto.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	handler.sendVerifications(ctx, user.ID, entity)
+
+	message := sender.LastMessage(entity.PhoneNumber)
+	if message == nil {
+		t.Fatalf("Verification text not sent")
+	}
+
+	requirements, err := oracle.NewRequirements(nil, []string{"PhoneNumber"})
+	if err != nil {
+		t.Fatalf("Failed to create requirements : %s", err)
+	}
+
+	unmet, err := requirements.CheckTransfer(ctx, store, user.ID, entity)
+	if err != nil {
+		t.Fatalf("Failed to check requirements : %s", err)
+	}
+
+	if len(unmet) == 0 {
+		t.Fatalf("Unverified phone should not meet transfer requirements")
+	}
+
+	words := strings.Fields(message.Text)
+	code := words[len(words)-1]
+
+	signature, err := key.Sign(oracle.VerificationSigHash(uuid.MustParse(user.ID), "PhoneNumber",
+		code))
+	if err != nil {
+		t.Fatalf("Failed to sign code : %s", err)
+	}
+
+	b, err := json.Marshal(struct {
+		UserID    string            `json:"user_id"`
+		Code      string            `json:"code"`
+		Signature bitcoin.Signature `json:"signature"`
+	}{
+		UserID:    user.ID,
+		Code:      code,
+		Signature: signature,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/verifyPhone", bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := handler.VerifyPhone(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to verify phone : %s", err)
+	}
+
+	verified, err := oracle.FetchVerifiedFields(ctx, store, user.ID, entity)
+	if err != nil {
+		t.Fatalf("Failed to fetch verified fields : %s", err)
+	}
+
+	if !verified["PhoneNumber"] {
+		t.Fatalf("Phone number not verified")
+	}
+
+	unmet, err = requirements.CheckTransfer(ctx, store, user.ID, entity)
+	if err != nil {
+		t.Fatalf("Failed to check requirements : %s", err)
+	}
+
+	if len(unmet) != 0 {
+		t.Fatalf("Verified phone should meet transfer requirements : %s", unmet)
+	}
+}
+
+func TestVerifyDomain(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate oracle key : %s", err)
+	}
+
+	resolver := domain.NewMockResolver()
+	handler := &Oracle{
+		Config:                  test.WebConfig,
+		Store:                   store,
+		Key:                     oracleKey,
+		DomainResolver:          resolver,
+		DomainChallengeDuration: time.Hour,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entity := &actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+		DomainName:  "tokenized.com",
+	}
+
+	entityBytes, err := proto.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	b, err := json.Marshal(struct {
+		UserID string `json:"user_id"`
+	}{
+		UserID: user.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	challenge := func(signKey bitcoin.Key) (*MockResponseWriter, error) {
+		userID := uuid.MustParse(user.ID)
+		timestamp := uint64(time.Now().Unix())
+
+		s := sha256.New()
+		s.Write(userID[:])
+		s.Write([]byte(entity.DomainName))
+		if err := binary.Write(s, binary.LittleEndian, timestamp); err != nil {
+			t.Fatalf("Failed to hash timestamp : %s", err)
+		}
+		hash := sha256.Sum256(s.Sum(nil))
+
+		signature, err := signKey.Sign(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign challenge request : %s", err)
+		}
+
+		b, err := json.Marshal(struct {
+			UserID    string            `json:"user_id"`
+			Timestamp uint64            `json:"timestamp"`
+			Signature bitcoin.Signature `json:"signature"`
+		}{
+			UserID:    user.ID,
+			Timestamp: timestamp,
+			Signature: signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/domainChallenge",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return response, handler.DomainChallenge(ctx, response, request, map[string]string{})
+	}
+
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate other key : %s", err)
+	}
+
+	if _, err := challenge(otherKey); errors.Cause(err) != web.ErrUnauthorized {
+		t.Fatalf("Challenge signed by another key should be unauthorized : %v", err)
+	}
+
+	response, err := challenge(key)
+	if err != nil {
+		t.Fatalf("Failed to create domain challenge : %s", err)
+	}
+
+	var responseData struct {
+		Data struct {
+			Token string `json:"token"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	verify := func() error {
+		request, err := http.NewRequest("POST", "http://test.com/verifyDomain",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler.VerifyDomain(ctx, response, request, map[string]string{})
+	}
+
+	// Token not published yet.
+	if err := verify(); err == nil {
+		t.Fatalf("Domain should not verify before token is published")
+	}
+
+	resolver.SetTXT("tokenized.com", "v=spf1 -all", responseData.Data.Token)
+
+	if err := verify(); err != nil {
+		t.Fatalf("Failed to verify domain : %s", err)
+	}
+
+	verified, err := oracle.IsFieldVerified(ctx, store, user.ID, "DomainName",
+		entity.DomainName)
+	if err != nil {
+		t.Fatalf("Failed to check domain verified : %s", err)
+	}
+
+	if !verified {
+		t.Fatalf("Domain not verified")
+	}
+}
+
+func TestVerifyPaymail(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	oracleKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate oracle key : %s", err)
+	}
+
+	server := paymail.NewFakeServer()
+	defer server.Close()
+
+	handler := &Oracle{
+		Config:          test.WebConfig,
+		Store:           store,
+		Key:             oracleKey,
+		PaymailResolver: server.Resolver(),
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entity := &actions.EntityField{
+		Name:          "Test Entity Name",
+		CountryCode:   "AUS",
+		PaymailHandle: "Test@tokenized.com",
+	}
+
+	entityBytes, err := proto.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	b, err := json.Marshal(struct {
+		UserID string `json:"user_id"`
+	}{
+		UserID: user.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	verify := func() error {
+		request, err := http.NewRequest("POST", "http://test.com/verifyPaymail",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler.VerifyPaymail(ctx, response, request, map[string]string{})
+	}
+
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate other key : %s", err)
+	}
+
+	// Paymail belongs to somebody else.
+	server.SetPublicKey("test@tokenized.com", otherKey.PublicKey())
+	if err := verify(); err == nil {
+		t.Fatalf("Paymail with different key should not verify")
+	}
+
+	server.SetPublicKey("test@tokenized.com", key.PublicKey())
+	if err := verify(); err != nil {
+		t.Fatalf("Failed to verify paymail : %s", err)
+	}
+
+	// The counterparty that looks up the paymail is another registered user.
+	caller := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    otherKey.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, caller); err != nil {
+		t.Fatalf("Failed to create caller : %s", err)
+	}
+
+	unregisteredKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate unregistered key : %s", err)
+	}
+
+	lookup := func(handle, callerID string, signer *bitcoin.Key) (*MockResponseWriter, error) {
+		timestamp := uint64(time.Now().Unix())
+
+		var signature *bitcoin.Signature
+		if signer != nil {
+			s := sha256.New()
+			s.Write([]byte(handle))
+			if err := binary.Write(s, binary.LittleEndian, timestamp); err != nil {
+				t.Fatalf("Failed to hash timestamp : %s", err)
+			}
+
+			sig, err := signer.Sign(sha256.Sum256(s.Sum(nil)))
+			if err != nil {
+				t.Fatalf("Failed to sign lookup : %s", err)
+			}
+			signature = &sig
+		}
+
+		query := url.Values{}
+		query.Set("paymail", handle)
+		if len(callerID) != 0 {
+			query.Set("user_id", callerID)
+		}
+		query.Set("timestamp", strconv.FormatUint(timestamp, 10))
+		if signature != nil {
+			query.Set("signature", signature.String())
+		}
+
+		request, err := http.NewRequest("GET",
+			"http://test.com/oracle/userByPaymail?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return response, handler.UserByPaymail(ctx, response, request, map[string]string{})
+	}
+
+	// Unauthorized lookups get the same error whether or not the paymail is verified.
+	_, unsignedErr := lookup("test@TOKENIZED.com", caller.ID, nil)
+	_, wrongKeyErr := lookup("test@TOKENIZED.com", caller.ID, &unregisteredKey)
+	_, unknownCallerErr := lookup("test@TOKENIZED.com", uuid.New().String(), &otherKey)
+	_, unknownErr := lookup("unknown@tokenized.com", caller.ID, &unregisteredKey)
+
+	for _, err := range []error{unsignedErr, wrongKeyErr, unknownCallerErr, unknownErr} {
+		if errors.Cause(err) != web.ErrUnauthorized {
+			t.Fatalf("Paymail lookup should be unauthorized : %v", err)
+		}
+		if err.Error() != unsignedErr.Error() {
+			t.Fatalf("Unauthorized lookups should match : %s, %s", err, unsignedErr)
+		}
+	}
+
+	if _, err := lookup("unknown@tokenized.com", caller.ID, &otherKey); errors.Cause(err) !=
+		web.ErrNotFound {
+		t.Fatalf("Lookup of unknown paymail should not be found : %v", err)
+	}
+
+	// A counterparty doesn't have the user's key, so the lookup is signed by the caller's key.
+	response, err := lookup("test@TOKENIZED.com", caller.ID, &otherKey)
+	if err != nil {
+		t.Fatalf("Failed to find user by paymail : %s", err)
+	}
+
+	var responseData struct {
+		Data struct {
+			UserID   string          `json:"user_id"`
+			Verified map[string]bool `json:"verified"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	if responseData.Data.UserID != user.ID {
+		t.Fatalf("Wrong user id : got %s, want %s", responseData.Data.UserID, user.ID)
+	}
+
+	if !responseData.Data.Verified["PaymailHandle"] {
+		t.Fatalf("Paymail handle not verified")
+	}
+}
+
+func TestInstrumentRequirement(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	admin := &Admin{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	post := func(handler func(context.Context, http.ResponseWriter, *http.Request,
+		map[string]string) error, requestData interface{}) error {
+
+		b, err := json.Marshal(requestData)
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/admin", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler(ctx, response, request, map[string]string{})
+	}
+
+	contract := "1AJPTwXpkQwkwSmbPu6yJY9WUKmPGAizjE"
+	instrumentID := "SHC4fUi2mCHxmsx7iT2GCgXxeijHvbZsRUeV"
+
+	if err := post(admin.SetInstrumentRequirement, map[string]interface{}{
+		"contract":               contract,
+		"instrument_id":          instrumentID,
+		"min_verification_level": 2,
+		"required_attributes":    []string{"accredited_investor"},
+	}); err != nil {
+		t.Fatalf("Failed to set instrument requirement : %s", err)
+	}
+
+	check := func() string {
+		user, err := oracle.FetchUser(ctx, store, user.ID)
+		if err != nil {
+			t.Fatalf("Failed to fetch user : %s", err)
+		}
+
+		unmet, err := oracle.CheckInstrumentRequirement(ctx, store, user, contract,
+			instrumentID)
+		if err != nil {
+			t.Fatalf("Failed to check instrument requirement : %s", err)
+		}
+
+		return unmet
+	}
+
+	if unmet := check(); len(unmet) == 0 {
+		t.Fatalf("Level 0 user should not meet instrument requirement")
+	}
+
+	if err := post(admin.SetVerificationLevel, map[string]interface{}{
+		"user_id": user.ID,
+		"level":   2,
+	}); err != nil {
+		t.Fatalf("Failed to set verification level : %s", err)
+	}
+
+	if unmet := check(); len(unmet) == 0 {
+		t.Fatalf("User without attribute should not meet instrument requirement")
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	if err := post(admin.SetAttribute, map[string]interface{}{
+		"user_id":      user.ID,
+		"attribute":    "accredited_investor",
+		"date_expires": expired,
+	}); err != nil {
+		t.Fatalf("Failed to set attribute : %s", err)
+	}
+
+	if unmet := check(); len(unmet) == 0 {
+		t.Fatalf("User with expired attribute should not meet instrument requirement")
+	}
+
+	if err := post(admin.SetAttribute, map[string]interface{}{
+		"user_id":   user.ID,
+		"attribute": "accredited_investor",
+	}); err != nil {
+		t.Fatalf("Failed to set attribute : %s", err)
+	}
+
+	if unmet := check(); len(unmet) != 0 {
+		t.Fatalf("User should meet instrument requirement : %s", unmet)
+	}
+
+	if err := post(admin.SetAttribute, map[string]interface{}{
+		"user_id":   user.ID,
+		"attribute": "Not Valid",
+	}); errors.Cause(err) != web.ErrValidation {
+		t.Fatalf("Invalid attribute should fail validation : %v", err)
+	}
+}
+
+func TestUploadDocument(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	handler := &Oracle{
+		Config:          test.WebConfig,
+		Store:           store,
+		MaxDocumentSize: 1024,
+	}
+
+	admin := &Admin{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	userID := uuid.New()
+
+	user := &oracle.User{
+		ID:           userID.String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	content := []byte("passport scan")
+	contentHash := sha256.Sum256(content)
+
+	upload := func(content []byte, signature bitcoin.Signature) (*MockResponseWriter, error) {
+		b, err := json.Marshal(struct {
+			UserID       string            `json:"user_id"`
+			DocumentType string            `json:"document_type"`
+			FileName     string            `json:"file_name"`
+			ContentType  string            `json:"content_type"`
+			Content      []byte            `json:"content"`
+			Signature    bitcoin.Signature `json:"signature"`
+		}{
+			UserID:       user.ID,
+			DocumentType: "passport",
+			FileName:     "passport.txt",
+			ContentType:  "text/plain",
+			Content:      content,
+			Signature:    signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/uploadDocument",
+			bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return response, handler.UploadDocument(ctx, response, request, map[string]string{})
+	}
+
+	signature, err := key.Sign(oracle.DocumentSigHash(userID, "passport", contentHash[:]))
+	if err != nil {
+		t.Fatalf("Failed to generate signature : %s", err)
+	}
+
+	if _, err := upload([]byte("different content"), signature); errors.Cause(err) !=
+		web.ErrUnauthorized {
+		t.Fatalf("Signature of other content should be unauthorized : %v", err)
+	}
+
+	if _, err := upload(make([]byte, 2048), signature); errors.Cause(err) !=
+		web.ErrRequestTooLarge {
+		t.Fatalf("Large document should be rejected : %v", err)
+	}
+
+	response, err := upload(content, signature)
+	if err != nil {
+		t.Fatalf("Failed to upload document : %s", err)
+	}
+
+	var responseData struct {
+		Data struct {
+			DocumentID string `json:"document_id"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	b, err := json.Marshal(struct {
+		DocumentID string `json:"document_id"`
+	}{
+		DocumentID: responseData.Data.DocumentID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/admin/document", bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response = &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := admin.Document(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to fetch document : %s", err)
+	}
+
+	var documentData struct {
+		Data struct {
+			UserID  string `json:"user_id"`
+			Content string `json:"content"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &documentData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	if documentData.Data.UserID != user.ID {
+		t.Errorf("Wrong document user : got %s, want %s", documentData.Data.UserID, user.ID)
+	}
+
+	// Responses encode binary as hex.
+	documentContent, err := hex.DecodeString(documentData.Data.Content)
+	if err != nil {
+		t.Fatalf("Failed to decode document content : %s", err)
+	}
+
+	if !bytes.Equal(documentContent, content) {
+		t.Errorf("Wrong document content : got %q, want %q", documentContent, content)
+	}
+}
+
+func TestIdentityExpiry(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	validity, err := oracle.NewValidity(time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Failed to create validity : %s", err)
+	}
+
+	admin := &Admin{
+		Config:   test.WebConfig,
+		Store:    store,
+		Validity: validity,
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:         "Test Entity Name",
+		CountryCode:  "AUS",
+		EmailAddress: "expiry@tokenized.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	user := &oracle.User{
+		ID:                  uuid.New().String(),
+		Entity:              entityBytes,
+		PublicKey:           key.PublicKey(),
+		DateCreated:         time.Now(),
+		DateModified:        time.Now(),
+		IsDeleted:           false,
+		DateIdentityExpires: &expired,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	mismatches := oracle.CheckIdentityExpiry(user, time.Now())
+	if len(mismatches) == 0 || mismatches[0].Code != oracle.MismatchExpired {
+		t.Fatalf("Expired identity should not be approved : %+v", mismatches)
+	}
+	t.Logf("Expired : %s", mismatches.Error())
+
+	b, err := json.Marshal(struct {
+		UserID string `json:"user_id"`
+	}{
+		UserID: user.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/admin/renewIdentity",
+		bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := admin.RenewIdentity(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to renew identity : %s", err)
+	}
+
+	user, err = oracle.FetchUser(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user : %s", err)
+	}
+
+	if mismatches := oracle.CheckIdentityExpiry(user, time.Now()); len(mismatches) != 0 {
+		t.Fatalf("Renewed identity should be approved : %s", mismatches.Error())
+	}
+
+	mailer := mail.NewMockMailer()
+	count, err := oracle.NotifyExpiringIdentities(ctx, store, mailer, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to notify expiring identities : %s", err)
+	}
+
+	if count == 0 || mailer.LastMessage("expiry@tokenized.com") == nil {
+		t.Fatalf("Expiry notification not sent")
+	}
+
+	count, err = oracle.NotifyExpiringIdentities(ctx, store, mailer, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to notify expiring identities : %s", err)
+	}
+
+	if count != 0 {
+		t.Fatalf("Expiry notification sent twice")
+	}
+}
+
+func TestSanctionsScreening(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	screener := sanctions.NewScreenerFromEntries([]*sanctions.Entry{
+		&sanctions.Entry{
+			List:    "sdn",
+			ID:      "2674",
+			Name:    "ABU MARZOOK, Mousa Mohammed",
+			Type:    "individual",
+			Aliases: []string{"MARZOUK, Musa Abu"},
+		},
+	}, sanctions.DefaultThreshold)
+
+	approver := oracle.NewScreeningApprover(store, screener, nil)
+
+	admin := &Admin{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	userID := uuid.New().String()
+	entity := actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+		Administration: []*actions.AdministratorField{
+			&actions.AdministratorField{
+				Type: 1,
+				Name: "Musa Abu Marzouk",
+			},
+		},
+	}
+
+	approved, description, err := approver.ApproveRegistration(ctx, userID, entity,
+		bitcoin.PublicKey{})
+	if err != nil {
+		t.Fatalf("Failed to approve registration : %s", err)
+	}
+	if !approved {
+		t.Fatalf("Registration should be accepted for review : %s", description)
+	}
+
+	hits, err := oracle.FetchScreeningHits(ctx, store, userID, oracle.ScreeningPending)
+	if err != nil {
+		t.Fatalf("Failed to fetch screening hits : %s", err)
+	}
+	if len(hits) != 1 || hits[0].Field != "Administration[0]" || hits[0].EntryID != "2674" {
+		t.Fatalf("Wrong screening hits : %+v", hits)
+	}
+	t.Logf("Hit : %s matched %s (%.3f)", hits[0].ScreenedName, hits[0].MatchedName, hits[0].Score)
+
+	// Screening again doesn't duplicate the hit.
+	if _, _, err := approver.UpdateIdentity(ctx, userID, entity); err != nil {
+		t.Fatalf("Failed to update identity : %s", err)
+	}
+
+	approved, description, err = approver.ApproveIdentity(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to approve identity : %s", err)
+	}
+	if approved {
+		t.Fatalf("Identity with pending hit should not be approved")
+	}
+	t.Logf("Identity denied : %s", description)
+
+	approved, _, err = approver.ApproveTransfer(ctx, "contract", "instrument", userID)
+	if err != nil {
+		t.Fatalf("Failed to approve transfer : %s", err)
+	}
+	if !approved {
+		t.Fatalf("Transfer should be approved while hit is pending")
+	}
+
+	b, err := json.Marshal(struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}{
+		ID:     hits[0].ID,
+		Status: oracle.ScreeningConfirmed,
+		Note:   "Confirmed by compliance",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/admin/reviewScreeningHit",
+		bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := admin.ReviewScreeningHit(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to review screening hit : %s", err)
+	}
+
+	hits, err = oracle.FetchScreeningHits(ctx, store, userID, "")
+	if err != nil {
+		t.Fatalf("Failed to fetch screening hits : %s", err)
+	}
+	if len(hits) != 1 || hits[0].Status != oracle.ScreeningConfirmed {
+		t.Fatalf("Wrong screening hits after review : %+v", hits)
+	}
+
+	approved, description, err = approver.ApproveTransfer(ctx, "contract", "instrument", userID)
+	if err != nil {
+		t.Fatalf("Failed to approve transfer : %s", err)
+	}
+	if approved {
+		t.Fatalf("Transfer should be denied for confirmed match")
+	}
+	t.Logf("Transfer denied : %s", description)
+
+	// Screening again reports the reviewed status instead of a new pending hit.
+	rescreened, err := oracle.ScreenEntity(ctx, store, screener, userID, &entity)
+	if err != nil {
+		t.Fatalf("Failed to screen entity : %s", err)
+	}
+	if len(rescreened) != 1 || rescreened[0].Status != oracle.ScreeningConfirmed {
+		t.Fatalf("Wrong screening hits after screening again : %+v", rescreened)
+	}
+
+	approved, description, err = approver.UpdateIdentity(ctx, userID, entity)
+	if err != nil {
+		t.Fatalf("Failed to update identity : %s", err)
+	}
+	if approved {
+		t.Fatalf("Identity update should be denied for confirmed match")
+	}
+	t.Logf("Update denied : %s", description)
+}
+
+func TestDuplicateIdentity(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	handler := &Oracle{
+		Config:             test.WebConfig,
+		Store:              store,
+		DuplicateAction:    oracle.DuplicateActionFlag,
+		DuplicateThreshold: 0.92,
+	}
+
+	admin := &Admin{
+		Config: test.WebConfig,
+		Store:  store,
+	}
+
+	register := func(entity actions.EntityField) (string, error) {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate user key : %s", err)
+		}
+
+		s := sha256.New()
+		if err := entity.WriteDeterministic(s); err != nil {
+			t.Fatalf("Failed to write entity : %s", err)
+		}
+		hash := sha256.Sum256(s.Sum(nil))
+
+		signature, err := key.Sign(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign entity : %s", err)
+		}
+
+		b, err := json.Marshal(struct {
+			Entity    actions.EntityField `json:"entity"`
+			PublicKey bitcoin.PublicKey   `json:"public_key"`
+			Signature bitcoin.Signature   `json:"signature"`
+		}{
+			Entity:    entity,
+			PublicKey: key.PublicKey(),
+			Signature: signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/register", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		if err := handler.Register(ctx, response, request, map[string]string{}); err != nil {
+			return "", err
+		}
+
+		var responseData struct {
+			Data struct {
+				UserID string `json:"user_id"`
+			}
+		}
+
+		if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+			t.Fatalf("Failed to unmarshal response : %s", err)
+		}
+
+		return responseData.Data.UserID, nil
+	}
+
+	firstUserID, err := register(actions.EntityField{
+		Name:          "Duplicate Test Pty Ltd",
+		Street:        "1 Test Street",
+		SuburbCity:    "Sydney",
+		PostalZIPCode: "2000",
+		CountryCode:   "AUS",
+		EmailAddress:  "duplicate@tokenized.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to register first user : %s", err)
+	}
+
+	// Different email, but a similar name and the same address in a different format.
+	secondUserID, err := register(actions.EntityField{
+		Name:          "DUPLICATE TESTS PTY. LTD.",
+		Street:        "1 Test  Street",
+		SuburbCity:    "SYDNEY",
+		PostalZIPCode: "2000",
+		CountryCode:   "AUS",
+		EmailAddress:  "other@tokenized.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to register flagged user : %s", err)
+	}
+
+	duplicates, err := oracle.FetchDuplicates(ctx, store, secondUserID,
+		oracle.DuplicatePending)
+	if err != nil {
+		t.Fatalf("Failed to fetch duplicates : %s", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].DuplicateUserID != firstUserID ||
+		duplicates[0].Kind != oracle.FingerprintNameAddress {
+		t.Fatalf("Wrong duplicates : %+v", duplicates)
+	}
+
+	// The same name at a different street isn't a duplicate.
+	thirdUserID, err := register(actions.EntityField{
+		Name:          "Duplicate Test Pty Ltd",
+		Street:        "250 Other Road",
+		SuburbCity:    "Sydney",
+		PostalZIPCode: "2000",
+		CountryCode:   "AUS",
+		EmailAddress:  "third@tokenized.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to register third user : %s", err)
+	}
+
+	thirdDuplicates, err := oracle.FetchDuplicates(ctx, store, thirdUserID, "")
+	if err != nil {
+		t.Fatalf("Failed to fetch duplicates : %s", err)
+	}
+	if len(thirdDuplicates) != 0 {
+		t.Fatalf("Different street should not be a duplicate : %+v", thirdDuplicates)
+	}
+
+	handler.DuplicateAction = oracle.DuplicateActionReject
+	if _, err := register(actions.EntityField{
+		Name:         "Someone Else",
+		EmailAddress: "Duplicate@Tokenized.com",
+	}); errors.Cause(err) != web.ErrForbidden {
+		t.Fatalf("Duplicate email should be rejected : %v", err)
+	}
+
+	_, err = register(actions.EntityField{
+		Name:         "Someone Else",
+		EmailAddress: "not an email address",
+	})
+	if invalid, ok := errors.Cause(err).(web.InvalidError); !ok || len(invalid) != 1 ||
+		invalid[0].Fld != "entity.EmailAddress" {
+		t.Fatalf("Invalid email should fail validation : %v", err)
+	}
+
+	b, err := json.Marshal(struct {
+		ID         string `json:"id"`
+		KeepUserID string `json:"keep_user_id"`
+	}{
+		ID:         duplicates[0].ID,
+		KeepUserID: firstUserID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize request data : %s", err)
+	}
+
+	request, err := http.NewRequest("POST", "http://test.com/admin/mergeDuplicate",
+		bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := admin.MergeDuplicate(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to merge duplicate : %s", err)
+	}
+
+	if _, err := oracle.FetchUser(ctx, store, secondUserID); errors.Cause(err) !=
+		oracle.ErrUserNotFound {
+		t.Fatalf("Merged user should be deleted : %v", err)
+	}
+
+	if _, err := oracle.FetchUser(ctx, store, firstUserID); err != nil {
+		t.Fatalf("Kept user should remain : %s", err)
+	}
+}
+
+func TestUserLookup(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+	store := oracle.NewMemoryStore()
+
+	handler := &Oracle{
+		Config:     test.WebConfig,
+		Store:      store,
+		AdminToken: "test-admin-token",
+	}
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &oracle.User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := oracle.CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	xkey, err := bitcoin.GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate xkey : %s", err)
+	}
+	xpubs := bitcoin.ExtendedKeys{xkey}.ExtendedPublicKeys()
+
+	if err := oracle.CreateXPub(ctx, store, &oracle.XPub{
+		UserID:          user.ID,
+		XPub:            xpubs,
+		RequiredSigners: 1,
+		DateCreated:     time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to create xpub : %s", err)
+	}
+
+	otherKey, err := bitcoin.GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate xkey : %s", err)
+	}
+	unknownXPubs := bitcoin.ExtendedKeys{otherKey}.ExtendedPublicKeys()
+
+	sign := func(xpubs bitcoin.ExtendedKeys, timestamp uint64) *bitcoin.Signature {
+		s := sha256.New()
+		s.Write(xpubs.Bytes())
+		if err := binary.Write(s, binary.LittleEndian, timestamp); err != nil {
+			t.Fatalf("Failed to hash timestamp : %s", err)
+		}
+		hash := sha256.Sum256(s.Sum(nil))
+
+		signature, err := key.Sign(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign lookup : %s", err)
+		}
+		return &signature
+	}
+
+	limited := mid.LimitFailures(mid.NewFailureLimiter(4, time.Minute, nil))(handler.User)
+
+	remoteAddress := "203.0.113.5:1234"
+	forwardedFor := ""
+
+	lookup := func(xpubs bitcoin.ExtendedKeys, timestamp uint64, signature *bitcoin.Signature,
+		adminToken string) (*MockResponseWriter, error) {
+
+		b, err := json.Marshal(struct {
+			XPubs     bitcoin.ExtendedKeys `json:"xpubs"`
+			Timestamp uint64               `json:"timestamp"`
+			Signature *bitcoin.Signature   `json:"signature,omitempty"`
+		}{
+			XPubs:     xpubs,
+			Timestamp: timestamp,
+			Signature: signature,
+		})
+		if err != nil {
+			t.Fatalf("Failed to serialize request data : %s", err)
+		}
+
+		request, err := http.NewRequest("POST", "http://test.com/oracle/user", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+		if len(adminToken) != 0 {
+			request.Header.Set("Authorization", "Bearer "+adminToken)
+		}
+		request.RemoteAddr = remoteAddress
+		if len(forwardedFor) != 0 {
+			request.Header.Set(mid.HeaderXForwardedFor, forwardedFor)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return response, limited(ctx, response, request, map[string]string{})
+	}
+
+	now := uint64(time.Now().Unix())
+
+	response, err := lookup(xpubs, now, sign(xpubs, now), "")
+	if err != nil {
+		t.Fatalf("Signed lookup failed : %s", err)
+	}
+
+	var responseData struct {
+		Data struct {
+			UserID string `json:"user_id"`
+		}
+	}
+
+	if err := web.Unmarshal(&response.buffer, &responseData); err != nil {
+		t.Fatalf("Failed to unmarshal response : %s", err)
+	}
+
+	if responseData.Data.UserID != user.ID {
+		t.Fatalf("Wrong user id : got %s, want %s", responseData.Data.UserID, user.ID)
+	}
+
+	if _, err := lookup(unknownXPubs, now, nil, "test-admin-token"); errors.Cause(err) !=
+		web.ErrNotFound {
+		t.Fatalf("Admin lookup of unknown xpub should be not found : %v", err)
+	}
+
+	// Unauthorized lookups get the same error whether or not the xpub is registered.
+	_, unsignedErr := lookup(xpubs, now, nil, "")
+	_, unknownErr := lookup(unknownXPubs, now, sign(unknownXPubs, now), "")
+	_, staleErr := lookup(xpubs, now-3600, sign(xpubs, now-3600), "")
+
+	for _, err := range []error{unsignedErr, unknownErr, staleErr} {
+		if errors.Cause(err) != web.ErrUnauthorized {
+			t.Fatalf("Lookup should be unauthorized : %v", err)
+		}
+		if err.Error() != unsignedErr.Error() {
+			t.Fatalf("Unauthorized lookups should match : %s, %s", err, unsignedErr)
+		}
+	}
+
+	if _, err := lookup(xpubs, now, sign(xpubs, now), ""); errors.Cause(err) !=
+		web.ErrTooManyRequests {
+		t.Fatalf("Lookup should be blocked after failures : %v", err)
+	}
+
+	// The client is blocked from any port, and forwarded addresses are ignored without trusted
+	// proxies.
+	remoteAddress = "203.0.113.5:4321"
+	forwardedFor = "198.51.100.7"
+	if _, err := lookup(xpubs, now, sign(xpubs, now), ""); errors.Cause(err) !=
+		web.ErrTooManyRequests {
+		t.Fatalf("Lookup from another port should be blocked : %v", err)
+	}
+
+	remoteAddress = "198.51.100.7:1234"
+	forwardedFor = ""
+	if _, err := lookup(xpubs, now, sign(xpubs, now), ""); err != nil {
+		t.Fatalf("Lookup from another client should not be blocked : %s", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := tests.Context()
+	store := oracle.NewMemoryStore()
+
+	apiKey, key, err := oracle.CreateAPIKey(ctx, store, "Test Integrator",
+		[]string{oracle.ScopeRegister})
+	if err != nil {
+		t.Fatalf("Failed to create api key : %s", err)
+	}
+
+	if _, _, err := oracle.CreateAPIKey(ctx, store, "Test Integrator",
+		[]string{"bad"}); errors.Cause(err) != oracle.ErrInvalidScope {
+		t.Fatalf("Invalid scope should be rejected : %v", err)
+	}
+
+	var integrator *mid.Integrator
+	next := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+		params map[string]string) error {
+		integrator = mid.IntegratorFromContext(ctx)
+		return nil
+	}
+
+	apiKeys := mid.APIKeys(apiKeyLookup(store))
+	register := apiKeys(mid.RequireScope(oracle.ScopeRegister, true)(next))
+	approve := apiKeys(mid.RequireScope(oracle.ScopeTransferApprove, false)(next))
+
+	call := func(handler web.Handler, key string) error {
+		integrator = nil
+		request, err := http.NewRequest("POST", "http://test.com/", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+		if len(key) != 0 {
+			request.Header.Set(mid.HeaderXAPIKey, key)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return handler(ctx, response, request, map[string]string{})
+	}
+
+	if err := call(register, key); err != nil {
+		t.Fatalf("Request with scope failed : %s", err)
+	}
+	if integrator == nil || integrator.KeyID != apiKey.ID {
+		t.Fatalf("Integrator should be in context : %+v", integrator)
+	}
+
+	if err := call(approve, key); errors.Cause(err) != web.ErrForbidden {
+		t.Fatalf("Request without scope should be forbidden : %v", err)
+	}
+
+	if err := call(approve, ""); err != nil {
+		t.Fatalf("Anonymous request should be allowed when keys aren't required : %s", err)
+	}
+
+	if err := call(register, ""); errors.Cause(err) != web.ErrUnauthorized {
+		t.Fatalf("Anonymous request should be unauthorized when keys are required : %v", err)
+	}
+
+	if err := call(register, "iok_invalid"); errors.Cause(err) != web.ErrUnauthorized {
+		t.Fatalf("Invalid key should be unauthorized : %v", err)
+	}
+
+	if err := oracle.RevokeAPIKey(ctx, store, apiKey.ID); err != nil {
+		t.Fatalf("Failed to revoke api key : %s", err)
+	}
+
+	if err := call(register, key); errors.Cause(err) != web.ErrUnauthorized {
+		t.Fatalf("Revoked key should be unauthorized : %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := tests.Context()
+
+	trustedProxies, err := mid.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies : %s", err)
+	}
+
+	if _, err := mid.NewRateLimiter(mid.NewMemoryRateLimitStore(),
+		map[string]string{"/transfer/approve": "60"}, trustedProxies); errors.Cause(err) !=
+		mid.ErrInvalidRateLimit {
+		t.Fatalf("Invalid rate limit should be rejected : %v", err)
+	}
+
+	limiter, err := mid.NewRateLimiter(mid.NewMemoryRateLimitStore(),
+		map[string]string{"/transfer/approve": "2/1m"}, trustedProxies)
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter : %s", err)
+	}
+
+	next := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+		params map[string]string) error {
+		return mid.LimitUser(ctx, params["user_id"])
+	}
+
+	handler := mid.RateLimit(limiter)(next)
+
+	call := func(path, remote, forwarded, userID string) (*MockResponseWriter, error) {
+		request, err := http.NewRequest("POST", "http://test.com"+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+		request.RemoteAddr = remote
+		if len(forwarded) != 0 {
+			request.Header.Set(mid.HeaderXForwardedFor, forwarded)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		return response, handler(ctx, response, request, map[string]string{"user_id": userID})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := call("/transfer/approve", "1.2.3.4:1000", "", uuid.New().String()); err != nil {
+			t.Fatalf("Request %d should be allowed : %s", i, err)
+		}
+	}
+
+	response, err := call("/transfer/approve", "1.2.3.4:1000", "", uuid.New().String())
+	if errors.Cause(err) != web.ErrTooManyRequests {
+		t.Fatalf("Request should be rate limited : %v", err)
+	}
+	if retryAfter := response.Header().Get(mid.HeaderRetryAfter); retryAfter != "30" {
+		t.Fatalf("Wrong Retry-After : got %s, want 30", retryAfter)
+	}
+
+	// X-Forwarded-For is ignored when the request isn't from a trusted proxy.
+	if _, err := call("/transfer/approve", "1.2.3.4:1000", "5.6.7.8",
+		uuid.New().String()); errors.Cause(err) != web.ErrTooManyRequests {
+		t.Fatalf("Forwarded request from untrusted address should be rate limited : %v", err)
+	}
+
+	if _, err := call("/transfer/approve", "10.0.0.1:1000", "1.2.3.4, 5.6.7.8",
+		uuid.New().String()); err != nil {
+		t.Fatalf("Request forwarded by trusted proxy should be allowed : %s", err)
+	}
+
+	if _, err := call("/oracle/id", "1.2.3.4:1000", "", uuid.New().String()); err != nil {
+		t.Fatalf("Route without a limit should be allowed : %s", err)
+	}
+
+	// Users are limited across client addresses.
+	userID := uuid.New().String()
+	for i := 0; i < 2; i++ {
+		if _, err := call("/transfer/approve", fmt.Sprintf("2.2.2.%d:1000", i), "",
+			userID); err != nil {
+			t.Fatalf("User request %d should be allowed : %s", i, err)
+		}
+	}
+
+	if _, err := call("/transfer/approve", "2.2.2.9:1000", "", userID); errors.Cause(err) !=
+		web.ErrTooManyRequests {
+		t.Fatalf("User request should be rate limited : %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := tests.Context()
+
+	countSignature("transfer", true)
+	countSignature("transfer", false)
+
+	handler := &Metrics{
+		Registry: metrics.Default,
+	}
+
+	request, err := http.NewRequest("GET", "http://test.com/metrics", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := handler.Metrics(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to get metrics : %s", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status : got %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	if contentType := response.Header().Get("Content-Type"); contentType != metrics.ContentType {
+		t.Fatalf("Wrong content type : %s", contentType)
+	}
+
+	body := response.buffer.String()
+	for _, line := range []string{
+		"# TYPE identity_oracle_signatures_total counter",
+		`identity_oracle_signatures_total{type="transfer",result="approved"}`,
+		`identity_oracle_signatures_total{type="transfer",result="denied"}`,
+		"# TYPE identity_oracle_db_query_duration_seconds histogram",
+		"# TYPE identity_oracle_header_age_seconds gauge",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("Metrics missing %s :\n%s", line, body)
+		}
+	}
+}
+
+type mockChainState struct {
+	status oracle.ChainStatus
+}
+
+func (c *mockChainState) ChainStatus() oracle.ChainStatus {
+	return c.status
+}
+
+// unreachableApprover approves everything but fails its health check.
+type unreachableApprover struct{}
+
+func (a *unreachableApprover) ApproveRegistration(ctx context.Context, userID string,
+	entity actions.EntityField, publicKey bitcoin.PublicKey) (bool, string, error) {
+	return true, "", nil
+}
+
+func (a *unreachableApprover) UpdateIdentity(ctx context.Context, userID string,
+	entity actions.EntityField) (bool, string, error) {
+	return true, "", nil
+}
+
+func (a *unreachableApprover) ApproveIdentity(ctx context.Context,
+	userID string) (bool, string, error) {
+	return true, "", nil
+}
+
+func (a *unreachableApprover) ApproveTransfer(ctx context.Context, contract, instrumentID string,
+	userID string) (bool, string, error) {
+	return true, "", nil
+}
+
+func (a *unreachableApprover) HealthCheck(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthReady(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+
+	chain := &mockChainState{
+		status: oracle.ChainStatus{
+			SpyNodeReady:    true,
+			InSync:          true,
+			HeaderCount:     5,
+			HeadersRequired: 5,
+			Height:          674000,
+			HeadersReceived: time.Now().Add(-10 * time.Minute),
+		},
+	}
+
+	handler := &Health{
+		MasterDB:     test.MasterDB,
+		Chain:        chain,
+		MaxHeaderAge: time.Hour,
+	}
+
+	ready := func(wantStatus int) *Readiness {
+		request, err := http.NewRequest("GET", "http://test.com/health/ready", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request : %s", err)
+		}
+
+		response := &MockResponseWriter{
+			header: http.Header{},
+		}
+
+		if err := handler.Ready(ctx, response, request, map[string]string{}); err != nil {
+			t.Fatalf("Failed to check readiness : %s", err)
+		}
+
+		if response.StatusCode != wantStatus {
+			t.Fatalf("Wrong status : got %d, want %d : %s", response.StatusCode, wantStatus,
+				response.buffer.String())
+		}
+
+		result := &Readiness{}
+		if err := json.Unmarshal(response.buffer.Bytes(), result); err != nil {
+			t.Fatalf("Failed to unmarshal readiness : %s", err)
+		}
+
+		return result
+	}
+
+	result := ready(http.StatusOK)
+	if result.Approver.Status != statusNotConfigured {
+		t.Fatalf("Wrong approver status : %s", result.Approver.Status)
+	}
+	if result.Headers.Height != 674000 || result.Headers.AgeSeconds < 600 {
+		t.Fatalf("Wrong headers : %+v", result.Headers)
+	}
+
+	// Missing headers can't be signed with.
+	chain.status.HeaderCount = 3
+	if result := ready(http.StatusServiceUnavailable); result.Headers.Status != statusNotReady {
+		t.Fatalf("Missing headers should not be ready : %+v", result.Headers)
+	}
+	chain.status.HeaderCount = 5
+
+	chain.status.HeadersReceived = time.Now().Add(-2 * time.Hour)
+	if result := ready(http.StatusServiceUnavailable); result.Headers.Status != statusNotReady {
+		t.Fatalf("Old headers should not be ready : %+v", result.Headers)
+	}
+	chain.status.HeadersReceived = time.Now()
+
+	chain.status.SpyNodeReady = false
+	if result := ready(http.StatusServiceUnavailable); result.SpyNode.Status != statusNotReady {
+		t.Fatalf("Unregistered spynode should not be ready : %+v", result.SpyNode)
+	}
+	chain.status.SpyNodeReady = true
+
+	handler.Approver = oracle.NewInstrumentedApprover(&unreachableApprover{})
+	if result := ready(http.StatusServiceUnavailable); result.Approver.Status != statusNotReady ||
+		result.Approver.Error != "connection refused" {
+		t.Fatalf("Unreachable approver should not be ready : %+v", result.Approver)
+	}
+
+	// Liveness doesn't depend on readiness.
+	request, err := http.NewRequest("GET", "http://test.com/health/live", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	response := &MockResponseWriter{
+		header: http.Header{},
+	}
+
+	if err := handler.Live(ctx, response, request, map[string]string{}); err != nil {
+		t.Fatalf("Failed to check liveness : %s", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status : got %d, want %d", response.StatusCode, http.StatusOK)
+	}
 }
@@ -3,17 +3,66 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"time"
 
+	"github.com/tokenized/identity-oracle/internal/oracle"
 	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
+
+	"go.opencensus.io/trace"
+)
+
+const (
+	statusOK            = "ok"
+	statusReady         = "ready"
+	statusNotReady      = "not ready"
+	statusNotConfigured = "not configured"
 )
 
 // Health provides health checks.
 type Health struct {
 	MasterDB *db.DB
+	Chain    oracle.ChainState
+	Approver oracle.ApproverInterface
+
+	// MaxHeaderAge is the longest since the latest block header was received that the service is
+	// ready. Zero doesn't check the age.
+	MaxHeaderAge time.Duration
 }
 
-// Health just returns a 200 okay status.
+// ReadyCheck is the result of one readiness check.
+type ReadyCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SpyNodeCheck is the readiness of the spynode connection.
+type SpyNodeCheck struct {
+	ReadyCheck
+	Registered bool `json:"registered"`
+	InSync     bool `json:"in_sync"`
+}
+
+// HeadersCheck is the readiness of the block headers that signatures are made with.
+type HeadersCheck struct {
+	ReadyCheck
+	Count      int     `json:"count"`
+	Required   int     `json:"required"`
+	Height     uint32  `json:"height"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+// Readiness is the readiness of the service and each of its dependencies.
+type Readiness struct {
+	Status   string       `json:"status"`
+	Database ReadyCheck   `json:"database"`
+	Storage  ReadyCheck   `json:"storage"`
+	SpyNode  SpyNodeCheck `json:"spynode"`
+	Headers  HeadersCheck `json:"headers"`
+	Approver ReadyCheck   `json:"approver"`
+}
+
+// Health checks the database and storage.
 func (h *Health) Health(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, params map[string]string) error {
 	var status struct {
@@ -23,12 +72,112 @@ func (h *Health) Health(ctx context.Context, w http.ResponseWriter,
 	if err := checkDB(ctx, h.MasterDB); err != nil {
 		status.Status = err.Error()
 		web.Respond(ctx, w, status, http.StatusInternalServerError)
+		return nil
 	}
 
 	web.Respond(ctx, w, nil, http.StatusOK)
 	return nil
 }
 
+// Live returns a 200 okay status while the process is able to serve requests. It doesn't check
+// dependencies so that the process isn't restarted when they are unavailable.
+func (h *Health) Live(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	web.Respond(ctx, w, ReadyCheck{Status: statusOK}, http.StatusOK)
+	return nil
+}
+
+// Ready returns a 200 okay status when the service can sign, otherwise 503 service unavailable.
+// The body contains the status of each dependency.
+func (h *Health) Ready(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.Ready")
+	defer span.End()
+
+	result := h.readiness(ctx, time.Now())
+
+	if result.Status != statusReady {
+		web.Respond(ctx, w, result, http.StatusServiceUnavailable)
+		return nil
+	}
+
+	web.Respond(ctx, w, result, http.StatusOK)
+	return nil
+}
+
+// readiness checks each dependency.
+func (h *Health) readiness(ctx context.Context, now time.Time) *Readiness {
+	dbConn := h.MasterDB.Copy()
+	defer dbConn.Close()
+
+	result := &Readiness{
+		Database: newReadyCheck(dbConn.DatabaseStatusCheck(ctx)),
+		Storage:  newReadyCheck(dbConn.StorageStatusCheck(ctx)),
+		Approver: newReadyCheck(oracle.CheckApproverHealth(ctx, h.Approver)),
+	}
+
+	if h.Approver == nil {
+		result.Approver.Status = statusNotConfigured
+	}
+
+	var chain oracle.ChainStatus
+	if h.Chain != nil {
+		chain = h.Chain.ChainStatus()
+	}
+
+	result.SpyNode = SpyNodeCheck{
+		ReadyCheck: ReadyCheck{Status: statusOK},
+		Registered: chain.SpyNodeReady,
+		InSync:     chain.InSync,
+	}
+	if !chain.SpyNodeReady {
+		result.SpyNode.Status = statusNotReady
+		result.SpyNode.Error = "Not registered with spynode"
+	}
+
+	result.Headers = HeadersCheck{
+		ReadyCheck: ReadyCheck{Status: statusOK},
+		Count:      chain.HeaderCount,
+		Required:   chain.HeadersRequired,
+		Height:     chain.Height,
+	}
+	if !chain.HeadersReceived.IsZero() {
+		result.Headers.AgeSeconds = now.Sub(chain.HeadersReceived).Seconds()
+	}
+
+	switch {
+	case chain.HeadersRequired == 0 || chain.HeaderCount < chain.HeadersRequired:
+		result.Headers.Status = statusNotReady
+		result.Headers.Error = "Not enough headers"
+	case h.MaxHeaderAge > 0 && now.Sub(chain.HeadersReceived) > h.MaxHeaderAge:
+		result.Headers.Status = statusNotReady
+		result.Headers.Error = "Latest header too old"
+	}
+
+	result.Status = statusReady
+	for _, status := range []string{result.Database.Status, result.Storage.Status,
+		result.SpyNode.Status, result.Headers.Status, result.Approver.Status} {
+		if status != statusOK && status != statusNotConfigured {
+			result.Status = statusNotReady
+		}
+	}
+
+	return result
+}
+
+func newReadyCheck(err error) ReadyCheck {
+	if err != nil {
+		return ReadyCheck{
+			Status: statusNotReady,
+			Error:  err.Error(),
+		}
+	}
+
+	return ReadyCheck{Status: statusOK}
+}
+
 // checkDB performs a status check on a DB.
 func checkDB(ctx context.Context, db *db.DB) error {
 	dbConn := db.Copy()
@@ -5,8 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
-	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
@@ -19,11 +19,13 @@ import (
 // Verify provides support for providing signatures to prove identity.
 type Verify struct {
 	Config                            *web.Config
-	MasterDB                          *db.DB
+	Store                             oracle.Store
 	Key                               bitcoin.Key
 	Headers                           oracle.Headers
 	Contracts                         oracle.Contracts
 	Approver                          oracle.ApproverInterface
+	Normalizer                        *oracle.Normalizer
+	Requirements                      *oracle.Requirements
 	IdentityExpirationDurationSeconds int
 }
 
@@ -55,14 +57,15 @@ func (v *Verify) PubKeySignature(ctx context.Context, w http.ResponseWriter,
 		logger.Uint32("index", requestData.Index),
 	}, "Signing public key")
 
-	dbConn := v.MasterDB.Copy()
-	defer dbConn.Close()
-
-	user, err := oracle.FetchUserByXPub(ctx, dbConn, bitcoin.ExtendedKeys{requestData.XPub})
+	user, err := oracle.FetchUserByXPub(ctx, v.Store, bitcoin.ExtendedKeys{requestData.XPub})
 	if err != nil {
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
+	if err := mid.LimitUser(ctx, user.ID); err != nil {
+		return errors.Wrap(err, "rate limit user")
+	}
+
 	if v.Approver != nil {
 		if approved, description, err := v.Approver.ApproveIdentity(ctx, user.ID); err != nil {
 			return translate(errors.Wrap(err, "approve identity"))
@@ -78,8 +81,8 @@ func (v *Verify) PubKeySignature(ctx context.Context, w http.ResponseWriter,
 	}
 
 	// Verify that the public key is associated with the entity.
-	sigHash, err := oracle.VerifyPubKey(ctx, user, v.Headers, &requestData.Entity,
-		requestData.XPub, requestData.Index)
+	sigHash, err := oracle.VerifyPubKey(ctx, v.Store, user, v.Headers, v.Normalizer,
+		v.Requirements, &requestData.Entity, requestData.XPub, requestData.Index)
 	if err != nil {
 		return translate(errors.Wrap(err, "verify pub key"))
 	}
@@ -89,15 +92,19 @@ func (v *Verify) PubKeySignature(ctx context.Context, w http.ResponseWriter,
 		return translate(errors.Wrap(err, "sign"))
 	}
 
+	countSignature("pubkey", sigHash.Approved)
+
 	response := struct {
-		Approved     bool              `json:"approved"`
-		Description  string            `json:"description"`
-		SigAlgorithm uint32            `json:"algorithm"`
-		Signature    bitcoin.Signature `json:"signature"`
-		BlockHeight  uint32            `json:"block_height"`
+		Approved     bool                    `json:"approved"`
+		Description  string                  `json:"description"`
+		Mismatches   oracle.EntityMismatches `json:"mismatches,omitempty"`
+		SigAlgorithm uint32                  `json:"algorithm"`
+		Signature    bitcoin.Signature       `json:"signature"`
+		BlockHeight  uint32                  `json:"block_height"`
 	}{
 		Approved:     sigHash.Approved,
 		Description:  sigHash.Description,
+		Mismatches:   sigHash.Mismatches,
 		SigAlgorithm: 1,
 		Signature:    sig,
 		BlockHeight:  sigHash.BlockHeight,
@@ -135,14 +142,15 @@ func (v *Verify) XPubSignature(ctx context.Context, w http.ResponseWriter,
 		logger.Stringer("xpubs", requestData.XPubs),
 	}, "Signing extended public key")
 
-	dbConn := v.MasterDB.Copy()
-	defer dbConn.Close()
-
-	user, err := oracle.FetchUserByXPub(ctx, dbConn, requestData.XPubs)
+	user, err := oracle.FetchUserByXPub(ctx, v.Store, requestData.XPubs)
 	if err != nil {
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
+	if err := mid.LimitUser(ctx, user.ID); err != nil {
+		return errors.Wrap(err, "rate limit user")
+	}
+
 	if v.Approver != nil {
 		if approved, description, err := v.Approver.ApproveIdentity(ctx, user.ID); err != nil {
 			return translate(errors.Wrap(err, "approve identity"))
@@ -158,8 +166,8 @@ func (v *Verify) XPubSignature(ctx context.Context, w http.ResponseWriter,
 	}
 
 	// Verify that the public key is associated with the entity.
-	sigHash, err := oracle.VerifyXPub(ctx, user, v.Headers, &requestData.Entity,
-		requestData.XPubs)
+	sigHash, err := oracle.VerifyXPub(ctx, v.Store, user, v.Headers, v.Normalizer,
+		v.Requirements, &requestData.Entity, requestData.XPubs)
 	if err != nil {
 		return translate(errors.Wrap(err, "verify xpub"))
 	}
@@ -169,15 +177,19 @@ func (v *Verify) XPubSignature(ctx context.Context, w http.ResponseWriter,
 		return translate(errors.Wrap(err, "sign"))
 	}
 
+	countSignature("xpub", sigHash.Approved)
+
 	response := struct {
-		Approved     bool              `json:"approved"`
-		Description  string            `json:"description"`
-		SigAlgorithm uint32            `json:"algorithm"`
-		Signature    bitcoin.Signature `json:"signature"`
-		BlockHeight  uint32            `json:"block_height"`
+		Approved     bool                    `json:"approved"`
+		Description  string                  `json:"description"`
+		Mismatches   oracle.EntityMismatches `json:"mismatches,omitempty"`
+		SigAlgorithm uint32                  `json:"algorithm"`
+		Signature    bitcoin.Signature       `json:"signature"`
+		BlockHeight  uint32                  `json:"block_height"`
 	}{
 		Approved:     sigHash.Approved,
 		Description:  sigHash.Description,
+		Mismatches:   sigHash.Mismatches,
 		SigAlgorithm: 1,
 		Signature:    sig,
 		BlockHeight:  sigHash.BlockHeight,
@@ -218,14 +230,15 @@ func (v *Verify) AdminCertificate(ctx context.Context, w http.ResponseWriter,
 		logger.Uint32("index", requestData.Index),
 	}, "Creating admin certificate")
 
-	dbConn := v.MasterDB.Copy()
-	defer dbConn.Close()
-
-	user, err := oracle.FetchUserByXPub(ctx, dbConn, requestData.XPubs)
+	user, err := oracle.FetchUserByXPub(ctx, v.Store, requestData.XPubs)
 	if err != nil {
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
+	if err := mid.LimitUser(ctx, user.ID); err != nil {
+		return errors.Wrap(err, "rate limit user")
+	}
+
 	if v.Approver != nil {
 		if approved, description, err := v.Approver.ApproveIdentity(ctx, user.ID); err != nil {
 			return translate(errors.Wrap(err, "approve identity"))
@@ -244,9 +257,9 @@ func (v *Verify) AdminCertificate(ctx context.Context, w http.ResponseWriter,
 		time.Second).UnixNano())
 
 	// Verify that the public key is associated with the entity.
-	sigHash, err := oracle.CreateAdminCertificate(ctx, dbConn, user, v.Config.Net, v.Config.IsTest,
-		v.Headers, v.Contracts, requestData.XPubs, requestData.Index, requestData.Issuer,
-		requestData.Contract, expiration)
+	sigHash, err := oracle.CreateAdminCertificate(ctx, v.Store, user, v.Config.Net, v.Config.IsTest,
+		v.Headers, v.Contracts, v.Normalizer, v.Requirements, requestData.XPubs,
+		requestData.Index, requestData.Issuer, requestData.Contract, expiration)
 	if err != nil {
 		return translate(errors.Wrap(err, "verify admin"))
 	}
@@ -256,15 +269,19 @@ func (v *Verify) AdminCertificate(ctx context.Context, w http.ResponseWriter,
 		return translate(errors.Wrap(err, "sign"))
 	}
 
+	countSignature("admin", sigHash.Approved)
+
 	response := struct {
-		Approved    bool              `json:"approved"`
-		Description string            `json:"description"`
-		Signature   bitcoin.Signature `json:"signature"`
-		BlockHeight uint32            `json:"block_height"`
-		Expiration  uint64            `json:"expiration"`
+		Approved    bool                    `json:"approved"`
+		Description string                  `json:"description"`
+		Mismatches  oracle.EntityMismatches `json:"mismatches,omitempty"`
+		Signature   bitcoin.Signature       `json:"signature"`
+		BlockHeight uint32                  `json:"block_height"`
+		Expiration  uint64                  `json:"expiration"`
 	}{
 		Approved:    sigHash.Approved,
 		Description: sigHash.Description,
+		Mismatches:  sigHash.Mismatches,
 		Signature:   sig,
 		BlockHeight: sigHash.BlockHeight,
 		Expiration:  expiration,
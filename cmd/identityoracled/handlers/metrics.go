@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
+	"github.com/tokenized/pkg/logger"
+
+	"go.opencensus.io/trace"
+)
+
+var (
+	signatures = metrics.NewCounter("identity_oracle_signatures_total",
+		"Signatures created by type (transfer, pubkey, xpub, or admin) and result.", "type",
+		"result")
+)
+
+// countSignature counts a signature that approves or denies a transfer or identity.
+func countSignature(kind string, approved bool) {
+	if approved {
+		signatures.Inc(kind, "approved")
+	} else {
+		signatures.Inc(kind, "denied")
+	}
+}
+
+// Metrics provides the service's metrics for Prometheus.
+type Metrics struct {
+	Registry *metrics.Registry
+}
+
+// Metrics returns the metrics in the Prometheus text exposition format.
+func (m *Metrics) Metrics(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Metrics.Metrics")
+	defer span.End()
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	// The status has been sent, so errors writing the body can only be logged.
+	if err := m.Registry.Write(w); err != nil {
+		logger.Error(ctx, "Failed to write metrics : %s", err)
+	}
+
+	return nil
+}
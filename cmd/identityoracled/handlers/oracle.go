@@ -7,8 +7,12 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
-	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/identity-oracle/internal/platform/sms"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
@@ -23,10 +27,45 @@ import (
 // Oracle provides support for identity checks.
 type Oracle struct {
 	Config          *web.Config
-	MasterDB        *db.DB
+	Store           oracle.Store
 	Approver        oracle.ApproverInterface
+	Normalizer      *oracle.Normalizer
 	Key             bitcoin.Key
 	ContractAddress bitcoin.RawAddress
+
+	// Mailer and SMSSender send verification codes. Email addresses and phone numbers aren't
+	// verified when they are nil.
+	Mailer                   mail.Mailer
+	SMSSender                sms.SMSSender
+	VerificationCodeDuration time.Duration
+
+	// DomainResolver retrieves domain challenge tokens. Domain names aren't verified when it is
+	// nil.
+	DomainResolver          domain.Resolver
+	DomainChallengeDuration time.Duration
+
+	// PaymailResolver retrieves paymail identity keys. Paymail handles aren't verified when it is
+	// nil.
+	PaymailResolver paymail.Resolver
+
+	// MaxDocumentSize is the largest identity document, in bytes, that can be uploaded.
+	MaxDocumentSize int64
+
+	// Validity is how long approved identities and field verifications last before the user must
+	// be verified again.
+	Validity *oracle.Validity
+
+	// DuplicateAction is what happens when an identity matches an existing user. One of "reject",
+	// "flag", or "allow". Duplicates aren't detected when it is empty.
+	DuplicateAction string
+
+	// DuplicateThreshold is the minimum similarity, from 0 to 1, of the names and street addresses
+	// of users at the same location for them to be duplicates.
+	DuplicateThreshold float64
+
+	// AdminToken authorizes user lookups without a signature from the user, as does an API key
+	// with the user:read scope.
+	AdminToken string
 }
 
 // Identity returns identity information about the oracle.
@@ -65,6 +104,10 @@ func (o *Oracle) Register(ctx context.Context, w http.ResponseWriter, r *http.Re
 		return translate(errors.Wrap(err, "unmarshal request"))
 	}
 
+	if err := oracle.ValidateEntity(&requestData.Entity); err != nil {
+		return translate(errors.Wrap(err, "validate entity"))
+	}
+
 	logger.InfoWithFields(ctx, []logger.Field{
 		logger.Stringer("public_key", requestData.PublicKey),
 	}, "Creating user")
@@ -80,18 +123,37 @@ func (o *Oracle) Register(ctx context.Context, w http.ResponseWriter, r *http.Re
 		return translate(oracle.ErrInvalidSignature)
 	}
 
-	entityBytes, err := proto.Marshal(&requestData.Entity)
+	// The identity history keeps the entity exactly as it was signed, while the user is stored
+	// with the canonical form that is used for comparisons.
+	signedEntityBytes, err := proto.Marshal(&requestData.Entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "protobuf marshal signed entity"))
+	}
+
+	entity := o.Normalizer.CanonicalEntity(&requestData.Entity)
+
+	entityBytes, err := proto.Marshal(entity)
 	if err != nil {
 		return translate(errors.Wrap(err, "protobuf marshal entity"))
 	}
 
 	userID := uuid.New().String()
 
+	duplicates, err := o.checkDuplicates(ctx, userID, entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "check duplicates"))
+	}
+
 	if o.Approver != nil {
 		if approved, description, err := o.Approver.ApproveRegistration(ctx, userID,
-			requestData.Entity, requestData.PublicKey); err != nil {
+			*entity, requestData.PublicKey); err != nil {
 			return translate(errors.Wrap(err, "approve registration"))
 		} else if !approved {
+			// The identity history isn't written since the user is never created.
+			logger.InfoWithFields(ctx, []logger.Field{
+				logger.String("description", description),
+			}, "Registration rejected")
+
 			response := struct {
 				Status string `json:"status"`
 			}{
@@ -103,24 +165,37 @@ func (o *Oracle) Register(ctx context.Context, w http.ResponseWriter, r *http.Re
 	}
 
 	// Insert user
-	dbConn := o.MasterDB.Copy()
-	defer dbConn.Close()
-
+	now := time.Now()
 	user := &oracle.User{
-		ID:           userID,
-		Entity:       entityBytes,
-		PublicKey:    requestData.PublicKey,
-		DateCreated:  time.Now(),
-		DateModified: time.Now(),
-		IsDeleted:    false,
+		ID:                  userID,
+		Entity:              entityBytes,
+		PublicKey:           requestData.PublicKey,
+		DateCreated:         now,
+		DateModified:        now,
+		IsDeleted:           false,
+		DateIdentityExpires: o.Validity.IdentityExpiry(now),
 	}
 
 	logger.Info(ctx, "Created user : %s", userID)
 
-	if err := oracle.CreateUser(ctx, dbConn, user); err != nil {
+	if err := oracle.CreateUser(ctx, o.Store, user); err != nil {
 		return translate(errors.Wrap(err, "create user"))
 	}
 
+	if err := oracle.CreateUserEntity(ctx, o.Store, &oracle.UserEntity{
+		UserID:      user.ID,
+		Entity:      signedEntityBytes,
+		Signature:   requestData.Signature.Bytes(),
+		Approved:    true,
+		DateCreated: user.DateCreated,
+	}); err != nil {
+		return translate(errors.Wrap(err, "create user entity"))
+	}
+
+	o.recordDuplicates(ctx, user.ID, entity, duplicates)
+	o.sendVerifications(ctx, user.ID, entity)
+	o.applyVerificationLevel(ctx, user.ID, entity)
+
 	response := struct {
 		Status string `json:"status"`
 		UserID string `json:"user_id"`
@@ -163,11 +238,8 @@ func (o *Oracle) AddXPub(ctx context.Context, w http.ResponseWriter, r *http.Req
 		logger.Stringer("xpubs", requestData.XPubs),
 	}, "Adding xpub")
 
-	dbConn := o.MasterDB.Copy()
-	defer dbConn.Close()
-
 	// Fetch User
-	user, err := oracle.FetchUser(ctx, dbConn, requestData.UserID)
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
 	if err != nil {
 		return translate(errors.Wrap(err, "fetch user"))
 	}
@@ -198,7 +270,7 @@ func (o *Oracle) AddXPub(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	// Insert xpub
-	if err := oracle.CreateXPub(ctx, dbConn, xpub); err != nil {
+	if err := oracle.CreateXPub(ctx, o.Store, xpub); err != nil {
 		return translate(errors.Wrap(err, "create xpub"))
 	}
 
@@ -208,7 +280,64 @@ func (o *Oracle) AddXPub(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return nil
 }
 
-// User returns the user id associated with an xpub.
+// signedRequestWindow is how far the timestamp of a signed request, like a user lookup or key
+// rotation, can be from the current time.
+const signedRequestWindow = 5 * time.Minute
+
+// errUserLookupUnauthorized is the response to every user lookup that isn't authorized, so it
+// doesn't reveal whether the xpub or paymail is registered.
+var errUserLookupUnauthorized = errors.Wrap(web.ErrUnauthorized,
+	"signature from user's key required")
+
+// verifySignedRequest returns true if the signature is the user's signature of the request value,
+// like the xpubs or paymail handle of a lookup, and a timestamp, in seconds since the unix epoch,
+// that is within signedRequestWindow of now. The signature hash is the double SHA256 of the value
+// followed by the timestamp as a little endian uint64. The timestamp keeps captured requests from
+// being replayed later.
+func verifySignedRequest(value []byte, timestamp uint64, signature *bitcoin.Signature,
+	publicKey bitcoin.PublicKey, now time.Time) bool {
+
+	if signature == nil {
+		return false
+	}
+
+	signed := time.Unix(int64(timestamp), 0)
+	if signed.Before(now.Add(-signedRequestWindow)) || signed.After(now.Add(signedRequestWindow)) {
+		return false
+	}
+
+	s := sha256.New()
+	s.Write(value)
+	if err := binary.Write(s, binary.LittleEndian, timestamp); err != nil {
+		return false
+	}
+	hash := sha256.Sum256(s.Sum(nil))
+
+	return signature.Verify(hash, publicKey)
+}
+
+// isSignedByUser returns true if the request value and timestamp are signed by the registered key
+// of the user, as verified by verifySignedRequest. It is false when the user doesn't exist.
+func (o *Oracle) isSignedByUser(ctx context.Context, userID string, value []byte,
+	timestamp uint64, signature *bitcoin.Signature) bool {
+
+	if len(userID) == 0 || signature == nil {
+		return false
+	}
+
+	user, err := oracle.FetchUser(ctx, o.Store, userID)
+	if err != nil {
+		if errors.Cause(err) != oracle.ErrUserNotFound {
+			logger.Error(ctx, "Failed to fetch signing user : %s", err)
+		}
+		return false
+	}
+
+	return verifySignedRequest(value, timestamp, signature, user.PublicKey, time.Now())
+}
+
+// User returns the user id associated with an xpub. The request must be signed by the user's key
+// unless the caller provides the admin token or an API key with the user:read scope.
 func (o *Oracle) User(ctx context.Context, w http.ResponseWriter,
 	r *http.Request, params map[string]string) error {
 
@@ -216,7 +345,9 @@ func (o *Oracle) User(ctx context.Context, w http.ResponseWriter,
 	defer span.End()
 
 	var requestData struct {
-		XPubs bitcoin.ExtendedKeys `json:"xpubs" validate:"required"`
+		XPubs     bitcoin.ExtendedKeys `json:"xpubs" validate:"required"`
+		Timestamp uint64               `json:"timestamp"`
+		Signature *bitcoin.Signature   `json:"signature"`
 	}
 
 	if err := web.Unmarshal(r.Body, &requestData); err != nil {
@@ -234,19 +365,50 @@ func (o *Oracle) User(ctx context.Context, w http.ResponseWriter,
 		}
 	}
 
-	dbConn := o.MasterDB.Copy()
-	defer dbConn.Close()
+	isAdmin := mid.HasAdminToken(r, o.AdminToken) || mid.HasScope(ctx, oracle.ScopeUserRead)
 
-	// Check user ID
-	userID, err := oracle.FetchUserIDByXPub(ctx, dbConn, requestData.XPubs)
+	// Callers that aren't authorized get the same response whether or not the xpub is registered
+	// so that they can't use this to link wallets to users.
+	user, err := oracle.FetchUserByXPub(ctx, o.Store, requestData.XPubs)
 	if err != nil {
+		if !isAdmin && errors.Cause(err) == oracle.ErrXPubNotFound {
+			return errUserLookupUnauthorized
+		}
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
+	if !isAdmin && !verifySignedRequest(requestData.XPubs.Bytes(), requestData.Timestamp,
+		requestData.Signature, user.PublicKey, time.Now()) {
+		return errUserLookupUnauthorized
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	verified, err := oracle.FetchVerifiedFields(ctx, o.Store, user.ID, entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch verified fields"))
+	}
+
+	attributes, err := fetchActiveAttributes(ctx, o.Store, user.ID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch attributes"))
+	}
+
 	response := struct {
-		UserID string `json:"user_id"`
+		UserID            string          `json:"user_id"`
+		Verified          map[string]bool `json:"verified,omitempty"`
+		VerificationLevel int             `json:"verification_level"`
+		Attributes        []userAttribute `json:"attributes,omitempty"`
+		IdentityExpires   *time.Time      `json:"date_identity_expires,omitempty"`
 	}{
-		UserID: *userID,
+		UserID:            user.ID,
+		Verified:          verified,
+		VerificationLevel: user.VerificationLevel,
+		Attributes:        attributes,
+		IdentityExpires:   user.DateIdentityExpires,
 	}
 
 	web.RespondData(ctx, w, response, http.StatusOK)
@@ -270,19 +432,17 @@ func (o *Oracle) UpdateIdentity(ctx context.Context, w http.ResponseWriter,
 		return translate(errors.Wrap(err, "unmarshal request"))
 	}
 
+	if err := oracle.ValidateEntity(&requestData.Entity); err != nil {
+		return translate(errors.Wrap(err, "validate entity"))
+	}
+
 	logger.InfoWithFields(ctx, []logger.Field{
 		logger.String("user_id", requestData.UserID),
 	}, "Updating identity")
 
-	dbConn := o.MasterDB.Copy()
-	defer dbConn.Close()
-
 	// Fetch User
-	user, err := oracle.FetchUser(ctx, dbConn, requestData.UserID)
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
 	if err != nil {
-		if err == db.ErrNotFound {
-			return web.ErrNotFound // User doesn't exist
-		}
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
@@ -304,11 +464,38 @@ func (o *Oracle) UpdateIdentity(ctx context.Context, w http.ResponseWriter,
 		return translate(oracle.ErrInvalidSignature)
 	}
 
+	signedEntityBytes, err := proto.Marshal(&requestData.Entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "protobuf marshal signed entity"))
+	}
+
+	entity := o.Normalizer.CanonicalEntity(&requestData.Entity)
+
+	entityBytes, err := proto.Marshal(entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "protobuf marshal entity"))
+	}
+
+	duplicates, err := o.checkDuplicates(ctx, user.ID, entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "check duplicates"))
+	}
+
 	if o.Approver != nil {
 		if approved, description, err := o.Approver.UpdateIdentity(ctx, user.ID,
-			requestData.Entity); err != nil {
+			*entity); err != nil {
 			return translate(errors.Wrap(err, "approve update entity"))
 		} else if !approved {
+			if err := oracle.CreateUserEntity(ctx, o.Store, &oracle.UserEntity{
+				UserID:      user.ID,
+				Entity:      signedEntityBytes,
+				Signature:   requestData.Signature.Bytes(),
+				Approved:    false,
+				Description: description,
+			}); err != nil {
+				return translate(errors.Wrap(err, "create user entity"))
+			}
+
 			response := struct {
 				Status string `json:"status"`
 			}{
@@ -319,17 +506,90 @@ func (o *Oracle) UpdateIdentity(ctx context.Context, w http.ResponseWriter,
 		}
 	}
 
-	// Update user in database
-	entityBytes, err := proto.Marshal(&requestData.Entity)
+	// Update user in database. The identity's validity period only restarts when the approver
+	// reports that it verified the identity again. Otherwise it is renewed by an administrator.
+	user.Entity = entityBytes
+
+	reverified, err := oracle.IdentityReverified(ctx, o.Approver, user.ID, *entity)
 	if err != nil {
-		return translate(errors.Wrap(err, "protobuf marshal entity"))
+		return translate(errors.Wrap(err, "identity reverified"))
+	}
+	if reverified {
+		user.DateIdentityExpires = o.Validity.IdentityExpiry(time.Now())
+		user.DateExpiryNotified = nil
 	}
-	user.Entity = entityBytes
 
-	if err := oracle.UpdateUser(ctx, dbConn, user); err != nil {
+	if err := oracle.UpdateUser(ctx, o.Store, user); err != nil {
 		return translate(errors.Wrap(err, "update user"))
 	}
 
+	if err := oracle.CreateUserEntity(ctx, o.Store, &oracle.UserEntity{
+		UserID:      user.ID,
+		Entity:      signedEntityBytes,
+		Signature:   requestData.Signature.Bytes(),
+		Approved:    true,
+		DateCreated: user.DateModified,
+	}); err != nil {
+		return translate(errors.Wrap(err, "create user entity"))
+	}
+
+	o.recordDuplicates(ctx, user.ID, entity, duplicates)
+	o.sendVerifications(ctx, user.ID, entity)
+	o.applyVerificationLevel(ctx, user.ID, entity)
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// RotateKey replaces the user's authentication public key. The request must be signed by the
+// user's current key.
+func (o *Oracle) RotateKey(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.RotateKey")
+	defer span.End()
+
+	var requestData struct {
+		UserID    string            `json:"user_id" validate:"required"`
+		PublicKey bitcoin.PublicKey `json:"public_key" validate:"required"`
+		Timestamp uint64            `json:"timestamp" validate:"required"`
+		Signature bitcoin.Signature `json:"signature" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.Stringer("public_key", requestData.PublicKey),
+	}, "Rotating user key")
+
+	// Fetch User
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	userID, err := uuid.Parse(requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "parse user id"))
+	}
+
+	// Verify signature is valid for user's current public key
+	signed := append(userID[:], requestData.PublicKey.Bytes()...)
+	if !verifySignedRequest(signed, requestData.Timestamp, &requestData.Signature,
+		user.PublicKey, time.Now()) {
+		return translate(oracle.ErrInvalidSignature)
+	}
+
+	if err := oracle.RotatePublicKey(ctx, o.Store, user, requestData.PublicKey,
+		"rotated by user"); err != nil {
+		return translate(errors.Wrap(err, "rotate public key"))
+	}
+
+	logger.Info(ctx, "Rotated user key : %s", user.ID)
+
 	web.Respond(ctx, w, nil, http.StatusOK)
 	return nil
 }
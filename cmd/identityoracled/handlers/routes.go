@@ -3,65 +3,196 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
 	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/identity-oracle/internal/platform/sms"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
 )
 
-// API returns a handler for a set of routes.
-func API(ctx context.Context, config *web.Config, masterDB *db.DB, key bitcoin.Key,
-	contractAddress bitcoin.RawAddress, headers oracle.Headers, contracts oracle.Contracts,
-	transferExpirationDurationSeconds, identityExpirationDurationSeconds int,
-	approver oracle.ApproverInterface) http.Handler {
+// APIConfig is what the API's routes are served with.
+type APIConfig struct {
+	Config   *web.Config
+	MasterDB *db.DB
+	Store    oracle.Store
+	Key      bitcoin.Key
+
+	// ContractAddress is the address of the oracle's contract.
+	ContractAddress bitcoin.RawAddress
+
+	Headers   oracle.Headers
+	Contracts oracle.Contracts
+	Chain     oracle.ChainState
+
+	// MaxHeaderAge is how old the latest block header can be before the service isn't ready. Zero
+	// doesn't check the age.
+	MaxHeaderAge time.Duration
+
+	TransferExpirationDurationSeconds int
+	IdentityExpirationDurationSeconds int
+
+	Approver     oracle.ApproverInterface
+	Normalizer   *oracle.Normalizer
+	Requirements *oracle.Requirements
+	Validity     *oracle.Validity
+
+	Mailer                   mail.Mailer
+	SMSSender                sms.SMSSender
+	VerificationCodeDuration time.Duration
+
+	DomainResolver          domain.Resolver
+	DomainChallengeDuration time.Duration
+
+	PaymailResolver paymail.Resolver
+
+	MaxDocumentSize int64
+
+	DuplicateAction    string
+	DuplicateThreshold float64
+
+	// UserLookupLimiter limits the failed user lookups of each client. Nil doesn't limit them.
+	UserLookupLimiter *mid.FailureLimiter
+
+	// RateLimiter limits the requests of each client by route. Nil doesn't limit them.
+	RateLimiter *mid.RateLimiter
+
+	// APIKeysRequired requires an API key with the route's scope for registrations, transfer
+	// approvals, and identity verifications.
+	APIKeysRequired bool
+
+	AdminToken string
+}
 
-	app := web.New(config, mid.ErrorHandler, mid.CORS)
+// API returns a handler for a set of routes.
+func API(ctx context.Context, cfg *APIConfig) http.Handler {
+	app := web.New(cfg.Config, mid.ErrorHandler, mid.CORS, mid.RecordRoute,
+		mid.APIKeys(apiKeyLookup(cfg.Store)), mid.RateLimit(cfg.RateLimiter))
 
 	// Register OPTIONS fallback handler for preflight requests.
 	app.HandleOptions(mid.CORSHandler)
 
 	hh := Health{
-		MasterDB: masterDB,
+		MasterDB:     cfg.MasterDB,
+		Chain:        cfg.Chain,
+		Approver:     cfg.Approver,
+		MaxHeaderAge: cfg.MaxHeaderAge,
 	}
 	app.Handle("GET", "/health", hh.Health)
+	app.Handle("GET", "/health/live", hh.Live)
+	app.Handle("GET", "/health/ready", hh.Ready)
+
+	mh := Metrics{
+		Registry: metrics.Default,
+	}
+	app.Handle("GET", "/metrics", mh.Metrics)
 
 	oh := Oracle{
-		Config:          config,
-		MasterDB:        masterDB,
-		Approver:        approver,
-		Key:             key,
-		ContractAddress: contractAddress,
+		Config:          cfg.Config,
+		Store:           cfg.Store,
+		Approver:        cfg.Approver,
+		Normalizer:      cfg.Normalizer,
+		Key:             cfg.Key,
+		ContractAddress: cfg.ContractAddress,
+
+		Mailer:                   cfg.Mailer,
+		SMSSender:                cfg.SMSSender,
+		VerificationCodeDuration: cfg.VerificationCodeDuration,
+
+		DomainResolver:          cfg.DomainResolver,
+		DomainChallengeDuration: cfg.DomainChallengeDuration,
+
+		PaymailResolver: cfg.PaymailResolver,
+
+		MaxDocumentSize: cfg.MaxDocumentSize,
+
+		Validity: cfg.Validity,
+
+		DuplicateAction:    cfg.DuplicateAction,
+		DuplicateThreshold: cfg.DuplicateThreshold,
+
+		AdminToken: cfg.AdminToken,
 	}
 	app.Handle("GET", "/oracle/id", oh.Identity)
-	app.Handle("POST", "/oracle/register", oh.Register)
+	app.Handle("POST", "/oracle/register", oh.Register,
+		mid.RequireScope(oracle.ScopeRegister, cfg.APIKeysRequired))
 	app.Handle("POST", "/oracle/addXPub", oh.AddXPub)
-	app.Handle("POST", "/oracle/user", oh.User)
+
+	var lookupLimits []web.Middleware
+	if cfg.UserLookupLimiter != nil {
+		lookupLimits = append(lookupLimits, mid.LimitFailures(cfg.UserLookupLimiter))
+	}
+	app.Handle("POST", "/oracle/user", oh.User, lookupLimits...)
 	app.Handle("POST", "/oracle/updateIdentity", oh.UpdateIdentity)
+	app.Handle("POST", "/oracle/rotateKey", oh.RotateKey)
+	app.Handle("POST", "/oracle/verifyEmail", oh.VerifyEmail)
+	app.Handle("POST", "/oracle/verifyPhone", oh.VerifyPhone)
+	app.Handle("POST", "/oracle/domainChallenge", oh.DomainChallenge)
+	app.Handle("POST", "/oracle/verifyDomain", oh.VerifyDomain)
+	app.Handle("POST", "/oracle/verifyPaymail", oh.VerifyPaymail)
+	app.Handle("GET", "/oracle/userByPaymail", oh.UserByPaymail, lookupLimits...)
+	app.Handle("POST", "/oracle/uploadDocument", oh.UploadDocument)
 
 	th := Transfers{
-		Config:                            config,
-		MasterDB:                          masterDB,
-		Key:                               key,
-		Headers:                           headers,
-		TransferExpirationDurationSeconds: transferExpirationDurationSeconds,
-		Approver:                          approver,
+		Config:                            cfg.Config,
+		Store:                             cfg.Store,
+		Key:                               cfg.Key,
+		Headers:                           cfg.Headers,
+		TransferExpirationDurationSeconds: cfg.TransferExpirationDurationSeconds,
+		Approver:                          cfg.Approver,
+		Requirements:                      cfg.Requirements,
 	}
-	app.Handle("POST", "/transfer/approve", th.TransferSignature)
+	app.Handle("POST", "/transfer/approve", th.TransferSignature,
+		mid.RequireScope(oracle.ScopeTransferApprove, cfg.APIKeysRequired))
 
 	vh := Verify{
-		Config:                            config,
-		MasterDB:                          masterDB,
-		Key:                               key,
-		Headers:                           headers,
-		Contracts:                         contracts,
-		IdentityExpirationDurationSeconds: identityExpirationDurationSeconds,
-		Approver:                          approver,
+		Config:                            cfg.Config,
+		Store:                             cfg.Store,
+		Key:                               cfg.Key,
+		Headers:                           cfg.Headers,
+		Contracts:                         cfg.Contracts,
+		IdentityExpirationDurationSeconds: cfg.IdentityExpirationDurationSeconds,
+		Approver:                          cfg.Approver,
+		Normalizer:                        cfg.Normalizer,
+		Requirements:                      cfg.Requirements,
+	}
+	identityVerify := mid.RequireScope(oracle.ScopeIdentityVerify, cfg.APIKeysRequired)
+	app.Handle("POST", "/identity/verifyPubKey", vh.PubKeySignature, identityVerify)
+	app.Handle("POST", "/identity/verifyXPub", vh.XPubSignature, identityVerify)
+	app.Handle("POST", "/identity/verifyAdmin", vh.AdminCertificate, identityVerify)
+
+	ah := Admin{
+		Config:   cfg.Config,
+		Store:    cfg.Store,
+		Validity: cfg.Validity,
 	}
-	app.Handle("POST", "/identity/verifyPubKey", vh.PubKeySignature)
-	app.Handle("POST", "/identity/verifyXPub", vh.XPubSignature)
-	app.Handle("POST", "/identity/verifyAdmin", vh.AdminCertificate)
+	adminAuth := mid.AdminAuth(cfg.AdminToken)
+	adminRead := mid.AdminAuthOrScope(cfg.AdminToken, oracle.ScopeAdminRead)
+	app.Handle("POST", "/admin/recoverKey", ah.RecoverKey, adminAuth)
+	app.Handle("POST", "/admin/publicKeys", ah.PublicKeys, adminRead)
+	app.Handle("POST", "/admin/identityHistory", ah.IdentityHistory, adminRead)
+	app.Handle("POST", "/admin/verifications", ah.Verifications, adminRead)
+	app.Handle("POST", "/admin/setVerificationLevel", ah.SetVerificationLevel, adminAuth)
+	app.Handle("POST", "/admin/setAttribute", ah.SetAttribute, adminAuth)
+	app.Handle("POST", "/admin/removeAttribute", ah.RemoveAttribute, adminAuth)
+	app.Handle("POST", "/admin/setInstrumentRequirement", ah.SetInstrumentRequirement, adminAuth)
+	app.Handle("POST", "/admin/documents", ah.Documents, adminRead)
+	app.Handle("POST", "/admin/document", ah.Document, adminRead)
+	app.Handle("POST", "/admin/renewIdentity", ah.RenewIdentity, adminAuth)
+	app.Handle("POST", "/admin/screeningHits", ah.ScreeningHits, adminRead)
+	app.Handle("POST", "/admin/reviewScreeningHit", ah.ReviewScreeningHit, adminAuth)
+	app.Handle("POST", "/admin/duplicates", ah.Duplicates, adminRead)
+	app.Handle("POST", "/admin/reviewDuplicate", ah.ReviewDuplicate, adminAuth)
+	app.Handle("POST", "/admin/mergeDuplicate", ah.MergeDuplicate, adminAuth)
+	app.Handle("POST", "/admin/createAPIKey", ah.CreateAPIKey, adminAuth)
+	app.Handle("POST", "/admin/apiKeys", ah.APIKeys, adminAuth)
+	app.Handle("POST", "/admin/revokeAPIKey", ah.RevokeAPIKey, adminAuth)
 
 	return app
 }
@@ -5,12 +5,14 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/tokenized/identity-oracle/internal/mid"
 	"github.com/tokenized/identity-oracle/internal/oracle"
-	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 )
@@ -18,12 +20,13 @@ import (
 // Transfer provides support for transferring bitcoin and tokens.
 type Transfers struct {
 	Config                            *web.Config
-	MasterDB                          *db.DB
+	Store                             oracle.Store
 	Key                               bitcoin.Key
 	Headers                           oracle.Headers
 	TransferExpirationDurationSeconds int
 
-	Approver oracle.ApproverInterface
+	Approver     oracle.ApproverInterface
+	Requirements *oracle.Requirements
 }
 
 // TransferSignature returns an approve/deny signature for a transfer receiver.
@@ -56,14 +59,15 @@ func (t *Transfers) TransferSignature(ctx context.Context, w http.ResponseWriter
 		logger.Uint32("index", requestData.Index),
 	}, "Creating transfer certificate")
 
-	dbConn := t.MasterDB.Copy()
-	defer dbConn.Close()
-
-	user, err := oracle.FetchUserByXPub(ctx, dbConn, requestData.XPubs)
+	user, err := oracle.FetchUserByXPub(ctx, t.Store, requestData.XPubs)
 	if err != nil {
 		return translate(errors.Wrap(err, "fetch user"))
 	}
 
+	if err := mid.LimitUser(ctx, user.ID); err != nil {
+		return errors.Wrap(err, "rate limit user")
+	}
+
 	approved := true
 	var description string
 	if t.Approver != nil {
@@ -75,11 +79,48 @@ func (t *Transfers) TransferSignature(ctx context.Context, w http.ResponseWriter
 		}
 	}
 
+	if approved {
+		if expired := oracle.CheckIdentityExpiry(user, time.Now()); len(expired) != 0 {
+			approved = false
+			description = expired.Error()
+		}
+	}
+
+	if approved {
+		userEntity := &actions.EntityField{}
+		if err := proto.Unmarshal(user.Entity, userEntity); err != nil {
+			return translate(errors.Wrap(err, "unmarshal user entity"))
+		}
+
+		unmet, err := t.Requirements.CheckTransfer(ctx, t.Store, user.ID, userEntity)
+		if err != nil {
+			return translate(errors.Wrap(err, "check requirements"))
+		}
+
+		if len(unmet) != 0 {
+			approved = false
+			description = unmet
+		}
+	}
+
+	if approved {
+		unmet, err := oracle.CheckInstrumentRequirement(ctx, t.Store, user, requestData.Contract,
+			requestData.InstrumentID)
+		if err != nil {
+			return translate(errors.Wrap(err, "check instrument requirement"))
+		}
+
+		if len(unmet) != 0 {
+			approved = false
+			description = unmet
+		}
+	}
+
 	expiration := uint64(time.Now().Add(time.Duration(t.TransferExpirationDurationSeconds) *
 		time.Second).UnixNano())
 
 	// Check that xpub is in DB. Check that entity associated xpub meets criteria for instrument.
-	sigHash, height, blockHash, err := oracle.CreateReceiveSignature(ctx, dbConn, t.Headers,
+	sigHash, height, blockHash, err := oracle.CreateReceiveSignature(ctx, t.Store, t.Headers,
 		t.Config.Net, requestData.Contract, requestData.InstrumentID, requestData.XPubs,
 		requestData.Index, expiration, approved)
 	if err != nil {
@@ -91,6 +132,8 @@ func (t *Transfers) TransferSignature(ctx context.Context, w http.ResponseWriter
 		return translate(errors.Wrap(err, "sign"))
 	}
 
+	countSignature("transfer", approved)
+
 	response := struct {
 		Approved     bool              `json:"approved"`
 		Description  string            `json:"description"`
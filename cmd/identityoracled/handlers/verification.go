@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/mid"
+	"github.com/tokenized/identity-oracle/internal/oracle"
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// VerifyEmail confirms the user controls their email address with the code that was emailed to
+// it.
+func (o *Oracle) VerifyEmail(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.VerifyEmail")
+	defer span.End()
+
+	return o.confirmVerification(ctx, w, r, "EmailAddress")
+}
+
+// VerifyPhone confirms the user controls their phone number with the code that was texted to it.
+func (o *Oracle) VerifyPhone(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.VerifyPhone")
+	defer span.End()
+
+	return o.confirmVerification(ctx, w, r, "PhoneNumber")
+}
+
+// confirmVerification checks the code sent to the value of the field in the user's identity. The
+// user signs the code so that nobody else can use up the verification's attempts.
+func (o *Oracle) confirmVerification(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	field string) error {
+
+	var requestData struct {
+		UserID    string            `json:"user_id" validate:"required"`
+		Code      string            `json:"code" validate:"required"`
+		Signature bitcoin.Signature `json:"signature" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("field", field),
+	}, "Confirming verification")
+
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	userID, err := uuid.Parse(requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "parse user id"))
+	}
+
+	hash := oracle.VerificationSigHash(userID, field, requestData.Code)
+	if !requestData.Signature.Verify(hash, user.PublicKey) {
+		return translate(oracle.ErrInvalidSignature)
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	value := oracle.VerifiableFieldValue(entity, field)
+	if len(value) == 0 {
+		return translate(errors.Wrapf(oracle.ErrVerificationNotFound, "no %s", field))
+	}
+
+	if _, err := oracle.ConfirmFieldVerification(ctx, o.Store, o.Validity, requestData.UserID,
+		field, value, requestData.Code); err != nil {
+		return translate(errors.Wrap(err, "confirm verification"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// DomainChallenge returns a token that the user must publish on the domain name in their identity
+// to verify they control it. The user signs the request, as verified by verifySignedRequest, with
+// the user id bytes followed by the domain name as the value so that nobody else can replace the
+// user's challenge.
+func (o *Oracle) DomainChallenge(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.DomainChallenge")
+	defer span.End()
+
+	var requestData struct {
+		UserID    string            `json:"user_id" validate:"required"`
+		Timestamp uint64            `json:"timestamp" validate:"required"`
+		Signature bitcoin.Signature `json:"signature" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if o.DomainResolver == nil {
+		return errors.Wrap(web.ErrForbidden, "domain verification disabled")
+	}
+
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	userID, err := uuid.Parse(requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "parse user id"))
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	signed := append(userID[:], []byte(entity.DomainName)...)
+	if !verifySignedRequest(signed, requestData.Timestamp, &requestData.Signature,
+		user.PublicKey, time.Now()) {
+		return translate(oracle.ErrInvalidSignature)
+	}
+
+	if len(entity.DomainName) == 0 {
+		return errors.Wrap(web.ErrValidation, "no domain name")
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("domain", entity.DomainName),
+	}, "Creating domain challenge")
+
+	token, err := oracle.CreateDomainChallenge(ctx, o.Store, requestData.UserID,
+		entity.DomainName, o.DomainChallengeDuration)
+	if err != nil {
+		return translate(errors.Wrap(err, "create challenge"))
+	}
+
+	response := struct {
+		Token       string    `json:"token"`
+		URL         string    `json:"url"`
+		DateExpires time.Time `json:"date_expires"`
+	}{
+		Token:       token,
+		URL:         "https://" + entity.DomainName + domain.WellKnownPath,
+		DateExpires: time.Now().Add(o.DomainChallengeDuration),
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// VerifyDomain checks that the token from a domain challenge has been published on the domain
+// name in the user's identity.
+func (o *Oracle) VerifyDomain(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.VerifyDomain")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if o.DomainResolver == nil {
+		return errors.Wrap(web.ErrForbidden, "domain verification disabled")
+	}
+
+	entity, err := o.fetchUserEntity(ctx, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user entity"))
+	}
+
+	if len(entity.DomainName) == 0 {
+		return translate(errors.Wrap(oracle.ErrVerificationNotFound, "no domain name"))
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("domain", entity.DomainName),
+	}, "Verifying domain")
+
+	if _, err := oracle.VerifyDomain(ctx, o.Store, o.DomainResolver, o.Validity,
+		requestData.UserID, entity.DomainName); err != nil {
+		return translate(errors.Wrap(err, "verify domain"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// VerifyPaymail checks that the identity public key of the paymail handle in the user's identity
+// belongs to the user.
+func (o *Oracle) VerifyPaymail(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.VerifyPaymail")
+	defer span.End()
+
+	var requestData struct {
+		UserID string `json:"user_id" validate:"required"`
+	}
+
+	if err := web.Unmarshal(r.Body, &requestData); err != nil {
+		return translate(errors.Wrap(err, "unmarshal request"))
+	}
+
+	if o.PaymailResolver == nil {
+		return errors.Wrap(web.ErrForbidden, "paymail verification disabled")
+	}
+
+	user, err := oracle.FetchUser(ctx, o.Store, requestData.UserID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	if len(entity.PaymailHandle) == 0 {
+		return errors.Wrap(web.ErrValidation, "no paymail handle")
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("user_id", requestData.UserID),
+		logger.String("paymail", entity.PaymailHandle),
+	}, "Verifying paymail")
+
+	if _, err := oracle.VerifyPaymail(ctx, o.Store, o.PaymailResolver, o.Validity, user,
+		entity.PaymailHandle); err != nil {
+		return translate(errors.Wrap(err, "verify paymail"))
+	}
+
+	web.Respond(ctx, w, nil, http.StatusOK)
+	return nil
+}
+
+// UserByPaymail returns the user that has verified a paymail handle so wallets can locate a
+// counterparty's identity by paymail. The handle is the paymail query parameter. The caller must be
+// a registered user, identified by the user_id parameter, that signs the request with their own
+// key, or provide the admin token or an API key with the user:read scope.
+func (o *Oracle) UserByPaymail(ctx context.Context, w http.ResponseWriter,
+	r *http.Request, params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.Oracle.UserByPaymail")
+	defer span.End()
+
+	query := r.URL.Query()
+	handle := query.Get("paymail")
+	callerID := query.Get("user_id")
+
+	if _, _, err := paymail.SplitHandle(handle); err != nil {
+		return translate(errors.Wrap(err, "paymail"))
+	}
+
+	var timestamp uint64
+	if value := query.Get("timestamp"); len(value) != 0 {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.Wrap(web.ErrValidation, "timestamp")
+		}
+		timestamp = parsed
+	}
+
+	var signature *bitcoin.Signature
+	if value := query.Get("signature"); len(value) != 0 {
+		parsed, err := bitcoin.SignatureFromStr(value)
+		if err != nil {
+			return errors.Wrap(web.ErrValidation, "signature")
+		}
+		signature = &parsed
+	}
+
+	logger.InfoWithFields(ctx, []logger.Field{
+		logger.String("paymail", handle),
+		logger.String("caller_user_id", callerID),
+	}, "Finding user by paymail")
+
+	// Callers that aren't authorized get the same response whatever the reason, and before the
+	// paymail is looked up, so that they can't use this to link paymails to users.
+	if !mid.HasAdminToken(r, o.AdminToken) && !mid.HasScope(ctx, oracle.ScopeUserRead) &&
+		!o.isSignedByUser(ctx, callerID, []byte(handle), timestamp, signature) {
+		return errUserLookupUnauthorized
+	}
+
+	userID, err := oracle.FetchUserIDByPaymail(ctx, o.Store, handle)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	user, err := oracle.FetchUser(ctx, o.Store, userID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch user"))
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return translate(errors.Wrap(err, "unmarshal user entity"))
+	}
+
+	verified, err := oracle.FetchVerifiedFields(ctx, o.Store, userID, entity)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch verified fields"))
+	}
+
+	attributes, err := fetchActiveAttributes(ctx, o.Store, userID)
+	if err != nil {
+		return translate(errors.Wrap(err, "fetch attributes"))
+	}
+
+	response := struct {
+		UserID            string          `json:"user_id"`
+		Verified          map[string]bool `json:"verified,omitempty"`
+		VerificationLevel int             `json:"verification_level"`
+		Attributes        []userAttribute `json:"attributes,omitempty"`
+		IdentityExpires   *time.Time      `json:"date_identity_expires,omitempty"`
+	}{
+		UserID:            userID,
+		Verified:          verified,
+		VerificationLevel: user.VerificationLevel,
+		Attributes:        attributes,
+		IdentityExpires:   user.DateIdentityExpires,
+	}
+
+	web.RespondData(ctx, w, response, http.StatusOK)
+	return nil
+}
+
+// fetchUserEntity returns the identity registered to the user.
+func (o *Oracle) fetchUserEntity(ctx context.Context,
+	userID string) (*actions.EntityField, error) {
+
+	user, err := oracle.FetchUser(ctx, o.Store, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch user")
+	}
+
+	entity := &actions.EntityField{}
+	if err := proto.Unmarshal(user.Entity, entity); err != nil {
+		return nil, errors.Wrap(err, "unmarshal user entity")
+	}
+
+	return entity, nil
+}
+
+// sendVerifications starts verification of the entity's contact fields that the user hasn't
+// verified yet. Failures are logged because the identity has already been saved and verification
+// can be restarted by updating the identity.
+func (o *Oracle) sendVerifications(ctx context.Context, userID string,
+	entity *actions.EntityField) {
+
+	if o.Mailer != nil && len(entity.EmailAddress) != 0 {
+		verified, err := oracle.IsFieldVerified(ctx, o.Store, userID, "EmailAddress",
+			entity.EmailAddress)
+		if err != nil {
+			logger.Error(ctx, "Failed to check email verification : %s", err)
+		} else if !verified {
+			if err := oracle.SendEmailVerification(ctx, o.Store, o.Mailer, userID,
+				entity.EmailAddress, o.VerificationCodeDuration); err != nil {
+				logger.Error(ctx, "Failed to send email verification : %s", err)
+			}
+		}
+	}
+
+	if o.SMSSender != nil && len(entity.PhoneNumber) != 0 {
+		verified, err := oracle.IsFieldVerified(ctx, o.Store, userID, "PhoneNumber",
+			entity.PhoneNumber)
+		if err != nil {
+			logger.Error(ctx, "Failed to check phone verification : %s", err)
+		} else if !verified {
+			if err := oracle.SendPhoneVerification(ctx, o.Store, o.SMSSender, userID,
+				entity.PhoneNumber, o.VerificationCodeDuration); err != nil {
+				logger.Error(ctx, "Failed to send phone verification : %s", err)
+			}
+		}
+	}
+}
+
+// applyVerificationLevel sets the user's verification level and attributes from the approver when
+// it supports them. Failures are logged because the identity has already been saved.
+func (o *Oracle) applyVerificationLevel(ctx context.Context, userID string,
+	entity *actions.EntityField) {
+
+	approver, ok := o.Approver.(oracle.VerificationApproverInterface)
+	if !ok {
+		return
+	}
+
+	level, attributes, err := approver.VerificationLevel(ctx, userID, *entity)
+	if err != nil {
+		logger.Error(ctx, "Failed to get verification level from approver : %s", err)
+		return
+	}
+
+	if err := oracle.SetVerificationLevel(ctx, o.Store, userID, level); err != nil {
+		logger.Error(ctx, "Failed to set verification level : %s", err)
+	}
+
+	for _, attribute := range attributes {
+		attribute.UserID = userID
+		attribute.SetBy = oracle.SetByApprover
+		if err := oracle.SetUserAttribute(ctx, o.Store, attribute); err != nil {
+			logger.Error(ctx, "Failed to set attribute %s : %s", attribute.Attribute, err)
+		}
+	}
+}
+
+// userAttribute is an active attribute flag as returned in user lookups.
+type userAttribute struct {
+	Attribute   string     `json:"attribute"`
+	DateExpires *time.Time `json:"date_expires,omitempty"`
+}
+
+// fetchActiveAttributes returns the user's attribute flags that haven't expired.
+func fetchActiveAttributes(ctx context.Context, store oracle.Store,
+	userID string) ([]userAttribute, error) {
+
+	attributes, err := oracle.FetchUserAttributes(ctx, store, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []userAttribute
+	for _, attribute := range attributes {
+		result = append(result, userAttribute{
+			Attribute:   attribute.Attribute,
+			DateExpires: attribute.DateExpires,
+		})
+	}
+
+	return result, nil
+}
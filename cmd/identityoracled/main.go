@@ -8,6 +8,7 @@ import (
 
 	"github.com/tokenized/config"
 	"github.com/tokenized/identity-oracle/cmd/identityoracled/bootstrap"
+	"github.com/tokenized/identity-oracle/internal/platform/tracing"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
 	"github.com/tokenized/pkg/rpcnode"
@@ -47,6 +48,50 @@ func main() {
 
 	config.DumpSafe(ctx, cfg)
 
+	// -------------------------------------------------------------------------
+	// Commands
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "encrypt":
+			if err := bootstrap.EncryptEntities(ctx, &cfg.Oracle); err != nil {
+				logger.Fatal(ctx, "main : Encrypt entities : %s", err)
+			}
+		case "fingerprint":
+			if err := bootstrap.FingerprintUsers(ctx, &cfg.Oracle); err != nil {
+				logger.Fatal(ctx, "main : Fingerprint users : %s", err)
+			}
+		case "migrate":
+			if err := bootstrap.Migrate(ctx, &cfg.Oracle, os.Args[2:]); err != nil {
+				logger.Fatal(ctx, "main : Migrate : %s", err)
+			}
+		default:
+			logger.Fatal(ctx, "main : Unknown command : %s", os.Args[1])
+		}
+		return
+	}
+
+	// -------------------------------------------------------------------------
+	// Tracing
+
+	tracer, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:     cfg.Oracle.Tracing.Exporter,
+		Endpoint:     cfg.Oracle.Tracing.Endpoint,
+		File:         cfg.Oracle.Tracing.File,
+		SampleRate:   cfg.Oracle.Tracing.SampleRate,
+		TrustSampled: cfg.Oracle.Tracing.TrustSampled,
+		ServiceName:  cfg.Oracle.Tracing.ServiceName,
+		Timeout:      cfg.Oracle.Tracing.Timeout,
+	})
+	if err != nil {
+		logger.Fatal(ctx, "main : Setup tracing : %s", err)
+	}
+	defer func() {
+		if err := tracer.Close(); err != nil {
+			logger.Error(ctx, "main : Close tracing : %s", err)
+		}
+	}()
+
 	// -------------------------------------------------------------------------
 	// RPC Node
 	rpcConfig := &rpcnode.Config{
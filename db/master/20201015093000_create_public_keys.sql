@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public_keys (
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    date_revoked TIMESTAMPTZ,
+    revoke_reason TEXT NOT NULL DEFAULT ''
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY public_keys ADD CONSTRAINT public_keys_unique UNIQUE (user_id, public_key);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO public_keys (user_id, public_key, date_created)
+    SELECT id, public_key, date_created FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public_keys CASCADE;
+-- +goose StatementEnd
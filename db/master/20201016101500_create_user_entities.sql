@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_entities (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    entity BYTEA NOT NULL,
+    signature BYTEA,
+    approved boolean NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY user_entities ADD CONSTRAINT user_entities_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_entities_user_id ON user_entities (user_id, date_created);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Existing users only have their current identity, which was last set at date_modified. The
+-- description marks these entries as reconstructed rather than recorded at submission.
+INSERT INTO user_entities (id, user_id, entity, approved, description, date_created)
+    SELECT md5(random()::text || id::text)::uuid, id, entity, true, 'backfilled', date_modified
+    FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_entities CASCADE;
+-- +goose StatementEnd
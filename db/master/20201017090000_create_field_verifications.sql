@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE field_verifications (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL REFERENCES users (id),
+    field TEXT NOT NULL,
+    value_hash BYTEA NOT NULL,
+    code_hash BYTEA NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    date_created TIMESTAMPTZ NOT NULL,
+    date_expires TIMESTAMPTZ NOT NULL,
+    date_verified TIMESTAMPTZ NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY field_verifications ADD CONSTRAINT field_verifications_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX field_verifications_user_field ON field_verifications (user_id, field, value_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS field_verifications CASCADE;
+-- +goose StatementEnd
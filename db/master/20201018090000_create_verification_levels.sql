@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN verification_level integer NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE user_attributes (
+    user_id uuid NOT NULL REFERENCES users (id),
+    attribute TEXT NOT NULL,
+    date_expires TIMESTAMPTZ NULL,
+    set_by TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY user_attributes ADD CONSTRAINT user_attributes_pkey PRIMARY KEY (user_id, attribute);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE instrument_requirements (
+    contract TEXT NOT NULL,
+    instrument_id TEXT NOT NULL,
+    min_verification_level integer NOT NULL DEFAULT 0,
+    required_attributes TEXT NOT NULL DEFAULT '',
+    date_modified TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY instrument_requirements ADD CONSTRAINT instrument_requirements_pkey PRIMARY KEY (contract, instrument_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS instrument_requirements CASCADE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_attributes CASCADE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN verification_level;
+-- +goose StatementEnd
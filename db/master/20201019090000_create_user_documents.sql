@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_documents (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL REFERENCES users (id),
+    document_type TEXT NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    size bigint NOT NULL,
+    content_hash BYTEA NOT NULL,
+    storage_key TEXT NOT NULL,
+    signature BYTEA NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY user_documents ADD CONSTRAINT user_documents_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_documents_user ON user_documents (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_documents CASCADE;
+-- +goose StatementEnd
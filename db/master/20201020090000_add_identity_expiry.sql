@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN date_identity_expires TIMESTAMPTZ NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN date_expiry_notified TIMESTAMPTZ NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE field_verifications ADD COLUMN date_valid_until TIMESTAMPTZ NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE field_verifications DROP COLUMN date_valid_until;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN date_expiry_notified;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN date_identity_expires;
+-- +goose StatementEnd
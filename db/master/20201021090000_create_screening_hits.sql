@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE screening_hits (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    field TEXT NOT NULL,
+    screened_name TEXT NOT NULL,
+    list_name TEXT NOT NULL,
+    entry_id TEXT NOT NULL,
+    entry_name TEXT NOT NULL,
+    matched_name TEXT NOT NULL,
+    score double precision NOT NULL,
+    status TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMPTZ NOT NULL,
+    date_reviewed TIMESTAMPTZ NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY screening_hits ADD CONSTRAINT screening_hits_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY screening_hits ADD CONSTRAINT screening_hits_unique UNIQUE (user_id, screened_name, list_name, entry_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX screening_hits_status ON screening_hits (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS screening_hits CASCADE;
+-- +goose StatementEnd
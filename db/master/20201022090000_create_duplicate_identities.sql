@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_fingerprints (
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    fingerprint BYTEA NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY user_fingerprints ADD CONSTRAINT user_fingerprints_pkey PRIMARY KEY (user_id, kind);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_fingerprints_fingerprint ON user_fingerprints (kind, fingerprint);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE duplicate_identities (
+    id uuid NOT NULL,
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    duplicate_user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMPTZ NOT NULL,
+    date_reviewed TIMESTAMPTZ NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY duplicate_identities ADD CONSTRAINT duplicate_identities_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY duplicate_identities ADD CONSTRAINT duplicate_identities_unique UNIQUE (user_id, duplicate_user_id, kind);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX duplicate_identities_status ON duplicate_identities (status);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN merged_into uuid NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS merged_into;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS duplicate_identities CASCADE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_fingerprints CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys (
+    id uuid NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash BYTEA NOT NULL,
+    scopes TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    date_last_used TIMESTAMPTZ NULL,
+    date_revoked TIMESTAMPTZ NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY api_keys ADD CONSTRAINT api_keys_pkey PRIMARY KEY (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY api_keys ADD CONSTRAINT api_keys_key_hash UNIQUE (key_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys CASCADE;
+-- +goose StatementEnd
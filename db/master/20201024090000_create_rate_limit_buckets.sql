@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE rate_limit_buckets (
+    key TEXT NOT NULL,
+    tokens DOUBLE PRECISION NOT NULL,
+    date_updated TIMESTAMPTZ NOT NULL,
+    date_full TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE ONLY rate_limit_buckets ADD CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX rate_limit_buckets_date_full ON rate_limit_buckets (date_full);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rate_limit_buckets CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE xpubs (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    xpub BLOB NOT NULL,
+    required_signers INTEGER NOT NULL DEFAULT 1,
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT xpubs_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX xpubs_unique ON xpubs (user_id, xpub);
+
+-- +goose Down
+-- SQL in this section is executed when the migration is rolled back.
+DROP TABLE IF EXISTS xpubs;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN approved;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN approved BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public_keys (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    public_key BLOB NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_revoked TIMESTAMP,
+    revoke_reason TEXT NOT NULL DEFAULT ''
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX public_keys_unique ON public_keys (user_id, public_key);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO public_keys (user_id, public_key, date_created)
+    SELECT id, public_key, date_created FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS public_keys;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_entities (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    entity BLOB NOT NULL,
+    signature BLOB,
+    approved BOOLEAN NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT user_entities_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_entities_user_id ON user_entities (user_id, date_created);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Existing users only have their current identity, which was last set at date_modified. The
+-- description marks these entries as reconstructed rather than recorded at submission.
+INSERT INTO user_entities (id, user_id, entity, approved, description, date_created)
+    SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2))
+        || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6))), id, entity, true,
+        'backfilled', date_modified FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_entities;
+-- +goose StatementEnd
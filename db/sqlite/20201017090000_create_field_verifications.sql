@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE field_verifications (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id),
+    field TEXT NOT NULL,
+    value_hash BLOB NOT NULL,
+    code_hash BLOB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    date_created TIMESTAMP NOT NULL,
+    date_expires TIMESTAMP NOT NULL,
+    date_verified TIMESTAMP NULL,
+    CONSTRAINT field_verifications_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX field_verifications_user_field ON field_verifications (user_id, field, value_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS field_verifications;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN verification_level INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE user_attributes (
+    user_id TEXT NOT NULL REFERENCES users (id),
+    attribute TEXT NOT NULL,
+    date_expires TIMESTAMP NULL,
+    set_by TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT user_attributes_pkey PRIMARY KEY (user_id, attribute)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE instrument_requirements (
+    contract TEXT NOT NULL,
+    instrument_id TEXT NOT NULL,
+    min_verification_level INTEGER NOT NULL DEFAULT 0,
+    required_attributes TEXT NOT NULL DEFAULT '',
+    date_modified TIMESTAMP NOT NULL,
+    CONSTRAINT instrument_requirements_pkey PRIMARY KEY (contract, instrument_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS instrument_requirements;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_attributes;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN verification_level;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_documents (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id),
+    document_type TEXT NOT NULL,
+    file_name TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    size INTEGER NOT NULL,
+    content_hash BLOB NOT NULL,
+    storage_key TEXT NOT NULL,
+    signature BLOB NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT user_documents_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_documents_user ON user_documents (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_documents;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE screening_hits (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    screened_name TEXT NOT NULL,
+    list_name TEXT NOT NULL,
+    entry_id TEXT NOT NULL,
+    entry_name TEXT NOT NULL,
+    matched_name TEXT NOT NULL,
+    score REAL NOT NULL,
+    status TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMP NOT NULL,
+    date_reviewed TIMESTAMP NULL,
+    CONSTRAINT screening_hits_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX screening_hits_unique ON screening_hits (user_id, screened_name, list_name, entry_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX screening_hits_status ON screening_hits (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS screening_hits;
+-- +goose StatementEnd
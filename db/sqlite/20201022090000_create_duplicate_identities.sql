@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_fingerprints (
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    fingerprint BLOB NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT user_fingerprints_pkey PRIMARY KEY (user_id, kind)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX user_fingerprints_fingerprint ON user_fingerprints (kind, fingerprint);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE duplicate_identities (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    duplicate_user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    date_created TIMESTAMP NOT NULL,
+    date_reviewed TIMESTAMP NULL,
+    CONSTRAINT duplicate_identities_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX duplicate_identities_unique ON duplicate_identities (user_id, duplicate_user_id, kind);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX duplicate_identities_status ON duplicate_identities (status);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN merged_into TEXT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN merged_into;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS duplicate_identities;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_fingerprints;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys (
+    id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash BLOB NOT NULL,
+    scopes TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_last_used TIMESTAMP NULL,
+    date_revoked TIMESTAMP NULL,
+    CONSTRAINT api_keys_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX api_keys_key_hash ON api_keys (key_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE rate_limit_buckets (
+    key TEXT NOT NULL,
+    tokens REAL NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+    date_full TIMESTAMP NOT NULL,
+    CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX rate_limit_buckets_date_full ON rate_limit_buckets (date_full);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rate_limit_buckets;
+-- +goose StatementEnd
@@ -8,12 +8,14 @@ require (
 	github.com/google/uuid v1.1.2
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.8
 	github.com/pkg/errors v0.9.1
 	github.com/tokenized/config v0.1.0
 	github.com/tokenized/pkg v0.4.0
 	github.com/tokenized/specification v1.1.1
 	github.com/tokenized/spynode v0.2.0
 	go.opencensus.io v0.22.2
+	golang.org/x/text v0.3.2
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
 	gopkg.in/go-playground/validator.v8 v8.18.2
 )
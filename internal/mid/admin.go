@@ -0,0 +1,63 @@
+package mid
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// AdminAuth returns a middleware that rejects requests that don't provide the admin token as a
+// bearer token in the Authorization header.
+func AdminAuth(token string) web.Middleware {
+	return func(next web.Handler) web.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.AdminAuth")
+			defer span.End()
+
+			if len(token) == 0 {
+				return errors.Wrap(web.ErrForbidden, "admin api disabled")
+			}
+
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				return errors.Wrap(web.ErrUnauthorized, "missing admin token")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")),
+				[]byte(token)) != 1 {
+				return errors.Wrap(web.ErrUnauthorized, "invalid admin token")
+			}
+
+			return next(ctx, w, r, params)
+		}
+
+		return h
+	}
+}
+
+// HasAdminToken returns true if the request provides the admin token as a bearer token in the
+// Authorization header. It is used by routes that accept either the admin token or another form
+// of authentication.
+func HasAdminToken(r *http.Request, token string) bool {
+	if len(token) == 0 {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")),
+		[]byte(token)) == 1
+}
@@ -0,0 +1,150 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// HeaderXAPIKey is the HTTP header containing an integrator's API key.
+	HeaderXAPIKey = "X-API-Key"
+)
+
+type integratorKey struct{}
+
+// Integrator is the caller identified by an API key.
+type Integrator struct {
+	KeyID  string
+	Name   string
+	Scopes []string
+}
+
+// HasScope returns true if the integrator is allowed to use the scope.
+func (i *Integrator) HasScope(scope string) bool {
+	if i == nil {
+		return false
+	}
+
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// APIKeyLookup returns the integrator with an API key, or nil if the key isn't valid.
+type APIKeyLookup func(ctx context.Context, key string) (*Integrator, error)
+
+// IntegratorFromContext returns the integrator making the request, or nil if the request didn't
+// provide an API key.
+func IntegratorFromContext(ctx context.Context) *Integrator {
+	integrator, _ := ctx.Value(integratorKey{}).(*Integrator)
+	return integrator
+}
+
+// HasScope returns true if the request was made by an integrator allowed to use the scope.
+func HasScope(ctx context.Context, scope string) bool {
+	return IntegratorFromContext(ctx).HasScope(scope)
+}
+
+// APIKeys returns a middleware that identifies the integrator making a request from the API key
+// in the X-API-Key header and adds it to the context. Requests without a key continue without an
+// integrator. Requests with a key that isn't valid are rejected.
+func APIKeys(lookup APIKeyLookup) web.Middleware {
+	return func(next web.Handler) web.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.APIKeys")
+			defer span.End()
+
+			key := r.Header.Get(HeaderXAPIKey)
+			if len(key) == 0 {
+				return next(ctx, w, r, params)
+			}
+
+			integrator, err := lookup(ctx, key)
+			if err != nil {
+				return errors.Wrap(err, "lookup api key")
+			}
+			if integrator == nil {
+				return errors.Wrap(web.ErrUnauthorized, "invalid api key")
+			}
+
+			// Let the request log record which integrator made the request.
+			if audit, ok := ctx.Value(auditKey{}).(*requestAudit); ok {
+				audit.integrator = integrator
+			}
+
+			ctx = context.WithValue(ctx, integratorKey{}, integrator)
+			return next(ctx, w, r.WithContext(ctx), params)
+		}
+
+		return h
+	}
+}
+
+// RequireScope returns a middleware that rejects requests from integrators that don't have the
+// scope. When required is true requests without an API key are also rejected, otherwise they
+// are allowed so that existing anonymous clients keep working.
+func RequireScope(scope string, required bool) web.Middleware {
+	return func(next web.Handler) web.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RequireScope")
+			defer span.End()
+
+			integrator := IntegratorFromContext(ctx)
+			if integrator == nil {
+				if required {
+					return errors.Wrap(web.ErrUnauthorized, "api key required")
+				}
+				return next(ctx, w, r, params)
+			}
+
+			if !integrator.HasScope(scope) {
+				return errors.Wrapf(web.ErrForbidden, "api key missing scope %s", scope)
+			}
+
+			return next(ctx, w, r, params)
+		}
+
+		return h
+	}
+}
+
+// AdminAuthOrScope returns a middleware that allows requests that provide the admin token or are
+// from an integrator with the scope. It is used for admin routes that integrators can be given
+// access to, like read only routes.
+func AdminAuthOrScope(token, scope string) web.Middleware {
+	adminAuth := AdminAuth(token)
+
+	return func(next web.Handler) web.Handler {
+		withAdminAuth := adminAuth(next)
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			if HasScope(ctx, scope) {
+				return next(ctx, w, r, params)
+			}
+
+			return withAdminAuth(ctx, w, r, params)
+		}
+
+		return h
+	}
+}
@@ -0,0 +1,125 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// FailureLimiter counts failed requests from each client address and blocks clients that fail
+// too often. It slows down probing of routes where a failure reveals nothing but repeated
+// attempts could, like guessing which xpubs are registered.
+type FailureLimiter struct {
+	limit          int
+	window         time.Duration
+	trustedProxies TrustedProxies
+
+	failures  map[string]*failureCount
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+type failureCount struct {
+	count int
+	start time.Time
+}
+
+// NewFailureLimiter creates a limiter that blocks a client after limit failed requests within
+// window, until the window ends. Clients are identified by host address, which is only taken from
+// the X-Forwarded-For header of requests from trustedProxies.
+func NewFailureLimiter(limit int, window time.Duration,
+	trustedProxies TrustedProxies) *FailureLimiter {
+
+	return &FailureLimiter{
+		limit:          limit,
+		window:         window,
+		trustedProxies: trustedProxies,
+		failures:       make(map[string]*failureCount),
+		lastSweep:      time.Now(),
+	}
+}
+
+// LimitFailures returns a middleware that rejects requests from clients that have made too many
+// failed requests. A request fails when the handler returns an unauthorized or not found error.
+func LimitFailures(limiter *FailureLimiter) web.Middleware {
+	return func(next web.Handler) web.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.LimitFailures")
+			defer span.End()
+
+			address := getRemoteAddress(r, limiter.trustedProxies)
+			if limiter.isBlocked(address, time.Now()) {
+				logger.WarnWithFields(ctx, []logger.Field{
+					logger.String("remote", address),
+					logger.String("path", r.URL.Path),
+				}, "Too many failed requests")
+				return errors.Wrap(web.ErrTooManyRequests, "too many failed requests")
+			}
+
+			err := next(ctx, w, r, params)
+
+			switch errors.Cause(err) {
+			case web.ErrUnauthorized, web.ErrNotFound:
+				limiter.addFailure(address, time.Now())
+			}
+
+			return err
+		}
+
+		return h
+	}
+}
+
+func (l *FailureLimiter) isBlocked(address string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	failures, exists := l.failures[address]
+	if !exists {
+		return false
+	}
+
+	if now.Sub(failures.start) >= l.window {
+		delete(l.failures, address)
+		return false
+	}
+
+	return failures.count >= l.limit
+}
+
+func (l *FailureLimiter) addFailure(address string, now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Remove clients whose windows have ended so the map doesn't grow without bound.
+	if now.Sub(l.lastSweep) >= l.window {
+		for key, failures := range l.failures {
+			if now.Sub(failures.start) >= l.window {
+				delete(l.failures, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	failures, exists := l.failures[address]
+	if !exists || now.Sub(failures.start) >= l.window {
+		l.failures[address] = &failureCount{
+			count: 1,
+			start: now,
+		}
+		return
+	}
+
+	failures.count++
+}
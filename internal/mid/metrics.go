@@ -0,0 +1,54 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+)
+
+const (
+	// unmatchedRoute is the route label of requests that don't match a route, so that arbitrary
+	// paths don't each create new metrics.
+	unmatchedRoute = "unmatched"
+)
+
+var (
+	httpRequests = metrics.NewCounter("identity_oracle_http_requests_total",
+		"HTTP requests by route and status.", "method", "route", "status")
+	httpRequestDuration = metrics.NewHistogram("identity_oracle_http_request_duration_seconds",
+		"HTTP request latency by route.", metrics.DefaultBuckets, "method", "route")
+)
+
+// RecordRoute records the route handling a request so that the request logging middleware can
+// count it. It must be an application wide middleware so that it is only called for requests that
+// match a route. Routes are static paths, so the route is the request path.
+func RecordRoute(next web.Handler) web.Handler {
+
+	// Create the handler that will be attached in the middleware chain.
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+		params map[string]string) error {
+
+		if audit, ok := ctx.Value(auditKey{}).(*requestAudit); ok {
+			audit.route = r.URL.Path
+		}
+
+		return next(ctx, w, r, params)
+	}
+
+	return h
+}
+
+// recordHTTPRequest counts a request and records its latency.
+func recordHTTPRequest(start time.Time, r *http.Request, lrw *loggingResponseWriter) {
+	route := unmatchedRoute
+	if audit, ok := r.Context().Value(auditKey{}).(*requestAudit); ok && len(audit.route) != 0 {
+		route = audit.route
+	}
+
+	httpRequests.Inc(r.Method, route, strconv.Itoa(lrw.statusCode))
+	httpRequestDuration.ObserveSince(start, r.Method, route)
+}
@@ -0,0 +1,277 @@
+package mid
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/web"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// HeaderRetryAfter tells a rate limited client how many seconds to wait before trying again.
+	HeaderRetryAfter = "Retry-After"
+)
+
+var (
+	// ErrInvalidRateLimit occurs when a rate limit isn't in the "requests/period" format.
+	ErrInvalidRateLimit = errors.New("Invalid rate limit")
+)
+
+// RouteLimit is a token bucket that holds Requests tokens and refills completely over Period. Each
+// request takes a token, so a client can make Requests requests at once and then Requests per
+// Period.
+type RouteLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRateLimit parses a rate limit in the format "requests/period", for example "60/1m".
+func ParseRateLimit(s string) (RouteLimit, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return RouteLimit{}, errors.Wrap(ErrInvalidRateLimit, s)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return RouteLimit{}, errors.Wrap(ErrInvalidRateLimit, s)
+	}
+
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return RouteLimit{}, errors.Wrap(ErrInvalidRateLimit, s)
+	}
+
+	return RouteLimit{
+		Requests: requests,
+		Period:   period,
+	}, nil
+}
+
+// rate returns the tokens added to a bucket per second.
+func (l RouteLimit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// takeToken refills a bucket that had tokens at updated and takes a token from it. Returns the
+// tokens left, when the bucket was updated, when it will be full again, and zero if a token was
+// taken or how long until one is available.
+func takeToken(limit RouteLimit, tokens float64, updated,
+	now time.Time) (float64, time.Time, time.Time, time.Duration) {
+
+	// Buckets shared between instances can be updated by a clock that is slightly ahead.
+	if now.After(updated) {
+		tokens = math.Min(float64(limit.Requests), tokens+now.Sub(updated).Seconds()*limit.rate())
+		updated = now
+	}
+
+	var wait time.Duration
+	if tokens >= 1.0 {
+		tokens--
+	} else {
+		wait = time.Duration((1.0 - tokens) / limit.rate() * float64(time.Second))
+	}
+
+	full := updated.Add(time.Duration((float64(limit.Requests) - tokens) / limit.rate() *
+		float64(time.Second)))
+
+	return tokens, updated, full, wait
+}
+
+// RateLimitStore holds the token buckets of a rate limiter.
+type RateLimitStore interface {
+	// Take takes a token from the bucket with the key, creating a full bucket if it doesn't
+	// exist. Returns zero if a token was taken or how long until one is available.
+	Take(ctx context.Context, key string, limit RouteLimit, now time.Time) (time.Duration, error)
+}
+
+// RateLimiter limits how often clients can call routes. Each route has its own limit and each
+// client has its own bucket for each route. Clients are identified by their API key, or their
+// address when they don't provide one, and by the user their request is for.
+type RateLimiter struct {
+	store          RateLimitStore
+	routes         map[string]RouteLimit
+	trustedProxies TrustedProxies
+}
+
+type rateLimitKey struct{}
+
+// requestRateLimit is the rate limit of the route being requested. Handlers use it to limit
+// requests for a user once they know who it is.
+type requestRateLimit struct {
+	limiter *RateLimiter
+	path    string
+	limit   RouteLimit
+	w       http.ResponseWriter
+}
+
+// NewRateLimiter creates a rate limiter. routes maps route paths to limits in the format
+// "requests/period". Routes that aren't included aren't limited.
+func NewRateLimiter(store RateLimitStore, routes map[string]string,
+	trustedProxies TrustedProxies) (*RateLimiter, error) {
+
+	result := &RateLimiter{
+		store:          store,
+		routes:         make(map[string]RouteLimit),
+		trustedProxies: trustedProxies,
+	}
+
+	for path, s := range routes {
+		limit, err := ParseRateLimit(s)
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+
+		result.routes[path] = limit
+	}
+
+	return result, nil
+}
+
+// RateLimit returns a middleware that rejects requests from clients that have used up their limit
+// for the route with ErrTooManyRequests and a Retry-After header. It must run after APIKeys so
+// that integrators are identified by their key. Returns nil, which is skipped, when limiter is
+// nil.
+func RateLimit(limiter *RateLimiter) web.Middleware {
+	if limiter == nil {
+		return nil
+	}
+
+	return func(next web.Handler) web.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request,
+			params map[string]string) error {
+
+			limit, exists := limiter.routes[r.URL.Path]
+			if !exists {
+				return next(ctx, w, r, params)
+			}
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RateLimit")
+			defer span.End()
+
+			rateLimit := &requestRateLimit{
+				limiter: limiter,
+				path:    r.URL.Path,
+				limit:   limit,
+				w:       w,
+			}
+
+			var client string
+			if integrator := IntegratorFromContext(ctx); integrator != nil {
+				client = "api_key:" + integrator.KeyID
+			} else {
+				client = "ip:" + getRemoteAddress(r, limiter.trustedProxies)
+			}
+
+			if err := rateLimit.take(ctx, client); err != nil {
+				return err
+			}
+
+			ctx = context.WithValue(ctx, rateLimitKey{}, rateLimit)
+			return next(ctx, w, r, params)
+		}
+
+		return h
+	}
+}
+
+// LimitUser takes a token from the user's bucket for the route being requested. Returns
+// ErrTooManyRequests, and sets the Retry-After header, when the user has used up their limit.
+// Does nothing when the route isn't rate limited.
+func LimitUser(ctx context.Context, userID string) error {
+	rateLimit, ok := ctx.Value(rateLimitKey{}).(*requestRateLimit)
+	if !ok {
+		return nil
+	}
+
+	return rateLimit.take(ctx, "user:"+userID)
+}
+
+func (l *requestRateLimit) take(ctx context.Context, client string) error {
+	wait, err := l.limiter.store.Take(ctx, client+":"+l.path, l.limit, time.Now())
+	if err != nil {
+		// Don't make the service unavailable because the limits can't be checked.
+		logger.Error(ctx, "Failed to check rate limit : %s", err)
+		return nil
+	}
+
+	if wait == 0 {
+		return nil
+	}
+
+	logger.WarnWithFields(ctx, []logger.Field{
+		logger.String("client", client),
+		logger.String("path", l.path),
+	}, "Rate limited")
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	l.w.Header().Set(HeaderRetryAfter, strconv.Itoa(seconds))
+
+	return errors.Wrap(web.ErrTooManyRequests, "rate limit exceeded")
+}
+
+// TrustedProxies are the networks of the proxies in front of the service. Their X-Forwarded-For
+// headers are used to find client addresses.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses addresses and CIDR ranges, for example "10.0.0.0/8".
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	var result TrustedProxies
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) == 0 {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.Errorf("invalid proxy address %s", value)
+			}
+
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.Wrap(err, value)
+		}
+
+		result = append(result, network)
+	}
+
+	return result, nil
+}
+
+func (p TrustedProxies) contains(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package mid
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+
+	"github.com/pkg/errors"
+)
+
+// DBRateLimitStore holds token buckets in the database so that limits are shared by all instances
+// of the service.
+type DBRateLimitStore struct {
+	MasterDB *db.DB
+
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+// NewDBRateLimitStore creates a rate limit store in the database.
+func NewDBRateLimitStore(masterDB *db.DB) *DBRateLimitStore {
+	return &DBRateLimitStore{
+		MasterDB:  masterDB,
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *DBRateLimitStore) Take(ctx context.Context, key string, limit RouteLimit,
+	now time.Time) (time.Duration, error) {
+
+	if err := s.sweep(ctx, now); err != nil {
+		return 0, errors.Wrap(err, "sweep")
+	}
+
+	tx := s.MasterDB.Copy()
+	defer tx.Close()
+
+	if err := tx.Begin(); err != nil {
+		return 0, errors.Wrap(err, "begin")
+	}
+
+	// Create a full bucket if there isn't one so that it can be locked.
+	insertSQL := `INSERT
+		INTO rate_limit_buckets (
+			key,
+			tokens,
+			date_updated,
+			date_full
+		)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO NOTHING`
+
+	if err := tx.Execute(ctx, insertSQL, key, float64(limit.Requests), now, now); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "insert bucket")
+	}
+
+	var bucket struct {
+		Tokens      float64   `db:"tokens"`
+		DateUpdated time.Time `db:"date_updated"`
+	}
+
+	selectSQL := `SELECT tokens, date_updated
+		FROM rate_limit_buckets
+		WHERE key = ?`
+
+	// SQLite doesn't lock rows. The insert already locked the whole database for the transaction.
+	if tx.Driver() != db.DriverSQLite {
+		selectSQL += ` FOR UPDATE`
+	}
+
+	if err := tx.Get(ctx, &bucket, selectSQL, key); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "select bucket")
+	}
+
+	tokens, updated, full, wait := takeToken(limit, bucket.Tokens, bucket.DateUpdated, now)
+
+	updateSQL := `UPDATE rate_limit_buckets
+		SET tokens = ?, date_updated = ?, date_full = ?
+		WHERE key = ?`
+
+	if err := tx.Execute(ctx, updateSQL, tokens, updated, full, key); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "update bucket")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit")
+	}
+
+	return wait, nil
+}
+
+// sweep removes buckets that have refilled so the table doesn't grow without bound.
+func (s *DBRateLimitStore) sweep(ctx context.Context, now time.Time) error {
+	s.lock.Lock()
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		s.lock.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.lock.Unlock()
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	return dbConn.Execute(ctx, `DELETE FROM rate_limit_buckets WHERE date_full <= ?`, now)
+}
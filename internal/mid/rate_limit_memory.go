@@ -0,0 +1,67 @@
+package mid
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitSweepInterval is how often buckets that have refilled are removed.
+	rateLimitSweepInterval = time.Minute
+)
+
+// MemoryRateLimitStore holds token buckets in memory. Each instance of the service has its own
+// limits.
+type MemoryRateLimitStore struct {
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	lock      sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// NewMemoryRateLimitStore creates an in memory rate limit store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RouteLimit,
+	now time.Time) (time.Duration, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Remove buckets that have refilled so the map doesn't grow without bound. A full bucket is
+	// the same as one that doesn't exist.
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for k, bucket := range s.buckets {
+			if !now.Before(bucket.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{
+			tokens:  float64(limit.Requests),
+			updated: now,
+		}
+		s.buckets[key] = bucket
+	}
+
+	var wait time.Duration
+	bucket.tokens, bucket.updated, bucket.full, wait = takeToken(limit, bucket.tokens,
+		bucket.updated, now)
+
+	return wait, nil
+}
@@ -2,6 +2,7 @@ package mid
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -23,15 +24,29 @@ const (
 	HeaderXForwardedFor = "X-Forwarded-For"
 )
 
+type auditKey struct{}
+
+// requestAudit collects information about a request, from deeper in the middleware chain, that is
+// logged with it.
+type requestAudit struct {
+	integrator *Integrator
+	route      string
+}
+
 // RequestLoggingMiddleware is our common HTTP request logging middleware.
 type RequestLoggingMiddleware struct {
-	LogConfig logger.Config
+	LogConfig      logger.Config
+	TrustedProxies TrustedProxies
 }
 
-// NewRequestLoggingMiddleware returns a new request logging middleware.
-func NewRequestLoggingMiddleware(logConfig logger.Config) RequestLoggingMiddleware {
+// NewRequestLoggingMiddleware returns a new request logging middleware. Client addresses are only
+// taken from the X-Forwarded-For header of requests from trustedProxies.
+func NewRequestLoggingMiddleware(logConfig logger.Config,
+	trustedProxies TrustedProxies) RequestLoggingMiddleware {
+
 	return RequestLoggingMiddleware{
-		LogConfig: logConfig,
+		LogConfig:      logConfig,
+		TrustedProxies: trustedProxies,
 	}
 }
 
@@ -52,6 +67,12 @@ func (m *RequestLoggingMiddleware) Handler(next http.Handler) http.Handler {
 		// add the request ID to log entries
 		ctx = logger.ContextWithLogTrace(ctx, traceID)
 
+		// collect audit information, like the integrator, for the log entry
+		ctx = context.WithValue(ctx, auditKey{}, &requestAudit{})
+
+		// start the request's span, continuing the caller's trace if there is one
+		ctx, span := startRequestSpan(ctx, r, traceID)
+
 		// put the context in the request
 		r = r.WithContext(ctx)
 
@@ -62,7 +83,12 @@ func (m *RequestLoggingMiddleware) Handler(next http.Handler) http.Handler {
 		next.ServeHTTP(lrw, r)
 
 		// log the result
-		logHTTPRequest(start, r, lrw)
+		logHTTPRequest(start, r, lrw, m.TrustedProxies)
+
+		// count the result
+		recordHTTPRequest(start, r, lrw)
+
+		endRequestSpan(span, lrw.statusCode)
 	})
 }
 
@@ -71,7 +97,8 @@ func (m *RequestLoggingMiddleware) Handler(next http.Handler) http.Handler {
 // The LoggingMiddlware function has set the status code.
 func logHTTPRequest(start time.Time,
 	r *http.Request,
-	lrw *loggingResponseWriter) {
+	lrw *loggingResponseWriter,
+	trustedProxies TrustedProxies) {
 
 	ctx := r.Context()
 
@@ -87,7 +114,13 @@ func logHTTPRequest(start time.Time,
 		logger.String("method", r.Method),
 		logger.String("path", r.URL.Path),
 		logger.String("type", "http"),
-		logger.String("remote", getRemoteAddress(ctx, r)),
+		logger.String("remote", getRemoteAddress(r, trustedProxies)),
+	}
+
+	// integrator, if the request used an api key
+	if audit, ok := ctx.Value(auditKey{}).(*requestAudit); ok && audit.integrator != nil {
+		fields = append(fields, logger.String("integrator", audit.integrator.Name),
+			logger.String("api_key_id", audit.integrator.KeyID))
 	}
 
 	// params, if any
@@ -128,27 +161,44 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
-// getRemoteAddress returns the remote address for the HTTP Request.
-func getRemoteAddress(ctx context.Context, r *http.Request) string {
-	// Get the remote address.
-	//
-	// Try the header first, falling back to the address in the request.
-	addr := r.Header.Get(HeaderXForwardedFor)
+// getRemoteAddress returns the address of the client that made the request. It is used for both
+// request logs and limiter keys so they agree. The X-Forwarded-For header is only used when the
+// request came through trusted proxies, since clients can set it to anything.
+func getRemoteAddress(r *http.Request, trustedProxies TrustedProxies) string {
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
 
-	if len(addr) > 0 {
-		// Use the address from the header.
-		//
-		// The header can take the following formats
-		//
-		// - "1.2.3.42, 1.2.3.4"
-		// - "1.2.3.42"
-		//
-		// Where the left-most address is the client address, with each hop adding their address to
-		// the list.
-		return strings.Split(strings.Replace(addr, " ", "", -1), ",")[0]
+	if !trustedProxies.contains(address) {
+		return address
+	}
+
+	// The header can take the following formats
+	//
+	// - "1.2.3.42, 1.2.3.4"
+	// - "1.2.3.42"
+	//
+	// Each proxy appends the address it received the request from, so the client is the right
+	// most address that isn't a trusted proxy.
+	forwarded := forwardedAddresses(r.Header.Get(HeaderXForwardedFor))
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if len(forwarded[i]) == 0 {
+			continue
+		}
+
+		address = forwarded[i]
+		if !trustedProxies.contains(address) {
+			break
+		}
 	}
 
-	return r.RemoteAddr
+	return address
+}
+
+// forwardedAddresses returns the addresses in an X-Forwarded-For header.
+func forwardedAddresses(addr string) []string {
+	return strings.Split(strings.Replace(addr, " ", "", -1), ",")
 }
 
 // buildTraceID returns a trace ID from a header if provided, otherwise a new ID is returned.
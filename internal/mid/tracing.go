@@ -0,0 +1,64 @@
+package mid
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+)
+
+var traceContextFormat = &tracecontext.HTTPFormat{}
+
+// startRequestSpan starts the span of an HTTP request, which handler spans are children of. The
+// span continues the caller's trace when the request has a W3C traceparent header. Otherwise the
+// request's trace id, from the X-Trace or X-Request-Id header or generated, is used as the trace
+// id when it is a UUID or 32 hex characters, so that a trace can be found from the request's logs.
+func startRequestSpan(ctx context.Context, r *http.Request,
+	traceID string) (context.Context, *trace.Span) {
+
+	name := r.Method + " " + r.URL.Path
+
+	var span *trace.Span
+	if parent, ok := traceContextFormat.SpanContextFromRequest(r); ok {
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, name, parent,
+			trace.WithSpanKind(trace.SpanKindServer))
+	} else if id, ok := parseTraceID(traceID); ok {
+		// A parent with an empty span id makes this the root span of the trace.
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, name, trace.SpanContext{TraceID: id},
+			trace.WithSpanKind(trace.SpanKindServer))
+	} else {
+		ctx, span = trace.StartSpan(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+	}
+
+	span.AddAttributes(
+		trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+		trace.StringAttribute(ochttp.PathAttribute, r.URL.Path),
+		trace.StringAttribute("trace", traceID),
+	)
+
+	return ctx, span
+}
+
+// endRequestSpan records the response status of a request and ends its span.
+func endRequestSpan(span *trace.Span, statusCode int) {
+	span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(statusCode)))
+	span.SetStatus(ochttp.TraceStatus(statusCode, http.StatusText(statusCode)))
+	span.End()
+}
+
+// parseTraceID parses a UUID or 32 hex characters as a trace id.
+func parseTraceID(s string) (trace.TraceID, bool) {
+	var result trace.TraceID
+
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != len(result) {
+		return result, false
+	}
+
+	copy(result[:], b)
+	return result, result != trace.TraceID{}
+}
@@ -0,0 +1,119 @@
+package oracle
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// API key scopes are the routes an integrator can call.
+	ScopeRegister        = "register"
+	ScopeUserRead        = "user:read"
+	ScopeTransferApprove = "transfer:approve"
+	ScopeIdentityVerify  = "identity:verify"
+	ScopeAdminRead       = "admin:read"
+
+	// apiKeyPrefix starts every API key so that they are recognizable, for example in leaked
+	// credential scans.
+	apiKeyPrefix = "iok_"
+
+	// apiKeyPrefixLength is how much of a key is stored in plaintext to identify it.
+	apiKeyPrefixLength = 12
+)
+
+// Scopes are the valid API key scopes.
+var Scopes = []string{
+	ScopeRegister,
+	ScopeUserRead,
+	ScopeTransferApprove,
+	ScopeIdentityVerify,
+	ScopeAdminRead,
+}
+
+// ScopeList is a list of API key scopes stored as a comma separated string.
+type ScopeList = AttributeList
+
+// ValidateScope returns an error if scope isn't one of Scopes.
+func ValidateScope(scope string) error {
+	for _, s := range Scopes {
+		if s == scope {
+			return nil
+		}
+	}
+
+	return errors.Wrap(ErrInvalidScope, scope)
+}
+
+// CreateAPIKey creates an API key for an integrator. Returns the key, which is only available
+// now since only its hash is stored.
+func CreateAPIKey(ctx context.Context, store Store, name string,
+	scopes []string) (*APIKey, string, error) {
+
+	for _, scope := range scopes {
+		if err := ValidateScope(scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", errors.Wrap(err, "generate key")
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	result := &APIKey{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Prefix:      key[:apiKeyPrefixLength],
+		Scopes:      ScopeList(scopes),
+		DateCreated: time.Now(),
+	}
+
+	if err := store.InsertAPIKey(ctx, result, apiKeyHash(key)); err != nil {
+		return nil, "", err
+	}
+
+	return result, key, nil
+}
+
+// FetchAPIKeyByKey returns the API key that hasn't been revoked with the key and records that it
+// was used.
+func FetchAPIKeyByKey(ctx context.Context, store Store, key string) (*APIKey, error) {
+	result, err := store.FetchAPIKeyByHash(ctx, apiKeyHash(key))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := store.SetAPIKeyLastUsed(ctx, result.ID, now); err != nil {
+		return nil, errors.Wrap(err, "update last used")
+	}
+	result.DateLastUsed = &now
+
+	return result, nil
+}
+
+// FetchAPIKeys returns all API keys, including revoked keys.
+func FetchAPIKeys(ctx context.Context, store Store) ([]*APIKey, error) {
+	return store.FetchAPIKeys(ctx)
+}
+
+// RevokeAPIKey stops an API key from being accepted.
+func RevokeAPIKey(ctx context.Context, store Store, id string) error {
+	if _, err := store.FetchAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	return store.RevokeAPIKey(ctx, id, time.Now())
+}
+
+func apiKeyHash(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
@@ -0,0 +1,92 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// DocumentTypes are the kinds of identity documents that can be uploaded.
+var DocumentTypes = []string{
+	"passport",
+	"national_id",
+	"drivers_license",
+	"proof_of_address",
+	"company_registration",
+	"other",
+}
+
+// ValidateDocumentType checks that the document type is one of DocumentTypes.
+func ValidateDocumentType(documentType string) error {
+	for _, t := range DocumentTypes {
+		if documentType == t {
+			return nil
+		}
+	}
+
+	return errors.Wrap(ErrInvalidDocumentType, documentType)
+}
+
+// DocumentSigHash returns the hash a user signs to upload a document. It commits to the user id,
+// the document type, and the SHA256 of the content.
+func DocumentSigHash(userID uuid.UUID, documentType string, contentHash []byte) bitcoin.Hash32 {
+	s := sha256.New()
+	s.Write(userID[:])
+	s.Write([]byte(documentType))
+	s.Write(contentHash)
+	return bitcoin.Hash32(sha256.Sum256(s.Sum(nil)))
+}
+
+// CreateDocument writes the document content and metadata to the store. ID, Size, ContentHash,
+// StorageKey, and DateCreated are set from the content.
+func CreateDocument(ctx context.Context, store Store, document *Document,
+	content []byte) error {
+
+	if err := ValidateDocumentType(document.DocumentType); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(content)
+
+	document.ID = uuid.New().String()
+	document.Size = int64(len(content))
+	document.ContentHash = hash[:]
+	document.StorageKey = "documents/" + document.UserID + "/" + document.ID
+	document.DateCreated = time.Now()
+
+	return store.InsertDocument(ctx, document, content)
+}
+
+// FetchDocument returns the metadata of a document.
+func FetchDocument(ctx context.Context, store Store, id string) (*Document, error) {
+	return store.FetchDocument(ctx, id)
+}
+
+// FetchDocuments returns the metadata of all documents uploaded by a user, oldest first.
+func FetchDocuments(ctx context.Context, store Store, userID string) ([]*Document, error) {
+	return store.FetchDocuments(ctx, userID)
+}
+
+// FetchDocumentContent reads a document's content from the store and checks it still matches the
+// hash recorded when it was uploaded.
+func FetchDocumentContent(ctx context.Context, store Store, document *Document) ([]byte,
+	error) {
+
+	content, err := store.ReadDocumentContent(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(content)
+	if !bytes.Equal(hash[:], document.ContentHash) {
+		return nil, errors.Wrap(ErrDocumentHashMismatch, document.ID)
+	}
+
+	return content, nil
+}
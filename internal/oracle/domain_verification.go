@@ -0,0 +1,123 @@
+package oracle
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/domain"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DomainTokenPrefix starts every domain verification token so it can be recognized among
+	// other TXT records.
+	DomainTokenPrefix = "tokenized-identity-verification="
+)
+
+// CreateDomainChallenge starts verification of a user's domain name and returns the token the
+// user must publish at https://<domain>/.well-known/tokenized-identity or in a DNS TXT record of
+// the domain.
+func CreateDomainChallenge(ctx context.Context, store Store, userID, domainName string,
+	duration time.Duration) (string, error) {
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "generate token")
+	}
+
+	token := DomainTokenPrefix + hex.EncodeToString(random)
+
+	if err := createFieldVerification(ctx, store, userID, "DomainName", domainName, token,
+		duration); err != nil {
+		return "", errors.Wrap(err, "create verification")
+	}
+
+	return token, nil
+}
+
+// VerifyDomain checks whether the domain has published the token of any of the user's pending
+// challenges for it and marks that challenge verified. The verification expires according to
+// validity.
+func VerifyDomain(ctx context.Context, store Store, resolver domain.Resolver,
+	validity *Validity, userID, domainName string) (*FieldVerification, error) {
+
+	pending, err := fetchPendingFieldVerifications(ctx, store, userID, "DomainName", domainName)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch pending")
+	}
+
+	if len(pending) == 0 {
+		return nil, errors.Wrap(ErrVerificationNotFound, "DomainName")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domainName)), ".")
+
+	var tokens []string
+	wellKnown, wellKnownErr := resolver.WellKnown(ctx, host)
+	if wellKnownErr != nil {
+		logger.Warn(ctx, "Failed to fetch well known tokens for %s : %s", host, wellKnownErr)
+	}
+	tokens = append(tokens, wellKnown...)
+
+	txt, txtErr := resolver.TXT(ctx, host)
+	if txtErr != nil {
+		logger.Warn(ctx, "Failed to lookup TXT records for %s : %s", host, txtErr)
+	}
+	tokens = append(tokens, txt...)
+
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if !strings.HasPrefix(token, DomainTokenPrefix) {
+			continue
+		}
+
+		for _, verification := range pending {
+			if subtle.ConstantTimeCompare(verificationCodeHash(verification.ID, token),
+				verification.CodeHash) != 1 {
+				continue
+			}
+
+			if err := markFieldVerified(ctx, store, validity, verification,
+				time.Now()); err != nil {
+				return nil, errors.Wrap(err, "mark verified")
+			}
+
+			return verification, nil
+		}
+	}
+
+	if wellKnownErr != nil && txtErr != nil {
+		return nil, errors.Wrapf(ErrVerificationTokenNotPublished, "%s : %s", wellKnownErr,
+			txtErr)
+	}
+
+	return nil, errors.Wrap(ErrVerificationTokenNotPublished, host)
+}
+
+// fetchPendingFieldVerifications returns the unexpired verifications of a user's field value that
+// haven't been completed yet.
+func fetchPendingFieldVerifications(ctx context.Context, store Store, userID, field,
+	value string) ([]*FieldVerification, error) {
+
+	verifications, err := store.FetchFieldVerificationsByValue(ctx, userID, field,
+		verificationValueHash(store, field, value))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*FieldVerification
+	now := time.Now()
+	for _, verification := range verifications {
+		if verification.DateVerified == nil && verification.DateExpires.After(now) {
+			result = append(result, verification)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,335 @@
+package oracle
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/sanctions"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Duplicate actions are what happens when a registration matches an existing user. Reject
+	// denies the registration, flag accepts it and records the duplicate for review, and allow
+	// ignores duplicates.
+	DuplicateActionReject = "reject"
+	DuplicateActionFlag   = "flag"
+	DuplicateActionAllow  = "allow"
+
+	// Duplicate statuses. Pending duplicates are waiting for the operator to review them. Linked
+	// duplicates are separate users that the operator knows belong to the same party. Merged
+	// duplicates have had one of the users merged into the other.
+	DuplicatePending   = "pending"
+	DuplicateLinked    = "linked"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+
+	// FingerprintNameAddress is the kind of duplicate found when entities at the same location
+	// have similar names and street addresses.
+	FingerprintNameAddress = "NameAddress"
+
+	// FingerprintLocation is the kind of the fingerprint of an entity's country and postal code,
+	// or city when it doesn't have a postal code. Names and streets can't be compared for
+	// similarity through hashes, so only the entities of users at the same location are compared.
+	FingerprintLocation = "Location"
+)
+
+// fingerprintKinds are the kinds of identifying information that must match exactly between
+// users, in the order they are reported. Name and address duplicates are reported after them.
+var fingerprintKinds = []string{"LEI", "EmailAddress", "PhoneNumber"}
+
+// ValidateDuplicateAction returns an error if action isn't "reject", "flag", or "allow".
+func ValidateDuplicateAction(action string) error {
+	switch action {
+	case DuplicateActionReject, DuplicateActionFlag, DuplicateActionAllow:
+		return nil
+	}
+
+	return errors.Wrap(ErrInvalidDuplicateAction, action)
+}
+
+// EntityFingerprints returns hashes of the identifying information in an entity by kind. Values are
+// normalized first so that differences in case, spacing, punctuation, and word order don't hide a
+// duplicate. Only hashes, keyed by the store, are stored so that they can be compared while
+// entities are encrypted without being reversible by hashing guesses.
+func EntityFingerprints(store Store, entity *actions.EntityField) map[string][]byte {
+	result := make(map[string][]byte)
+
+	if lei := strings.ToUpper(strings.TrimSpace(entity.LEI)); len(lei) != 0 {
+		result["LEI"] = verificationValueHash(store, "LEI", lei)
+	}
+
+	if email := strings.ToLower(strings.TrimSpace(entity.EmailAddress)); len(email) != 0 {
+		result["EmailAddress"] = verificationValueHash(store, "EmailAddress", email)
+	}
+
+	if phone := phoneDigits(entity.PhoneNumber); len(phone) != 0 {
+		result["PhoneNumber"] = verificationValueHash(store, "PhoneNumber", phone)
+	}
+
+	if key := locationKey(entity); len(key) != 0 {
+		result[FingerprintLocation] = verificationValueHash(store, FingerprintLocation, key)
+	}
+
+	return result
+}
+
+// phoneDigits returns a phone number without formatting.
+func phoneDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if (r >= '0' && r <= '9') || (r == '+' && b.Len() == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// streetKey returns the compared form of an entity's street address.
+func streetKey(entity *actions.EntityField) string {
+	return sanctions.NameKey(entity.UnitNumber + " " + entity.BuildingNumber + " " +
+		entity.Street)
+}
+
+// locationKey returns the compared form of an entity's location. Returns an empty string when the
+// entity doesn't have both a name and an address, since a name alone isn't identifying.
+func locationKey(entity *actions.EntityField) string {
+	if len(sanctions.NameKey(entity.Name)) == 0 || len(streetKey(entity)) == 0 {
+		return ""
+	}
+
+	place := sanctions.NameKey(entity.PostalZIPCode)
+	if len(place) == 0 {
+		place = sanctions.NameKey(entity.SuburbCity)
+		if len(place) == 0 {
+			return ""
+		}
+	}
+
+	return strings.ToUpper(entity.CountryCode) + "|" + place
+}
+
+// isSimilarNameAddress returns true when both the names and the street addresses of the entities
+// have a similarity of at least threshold, from 0 to 1. Names are compared in the same way as
+// sanctions screening, so differences in case, punctuation, word order, and company suffixes are
+// ignored.
+func isSimilarNameAddress(a, b *actions.EntityField, threshold float64) bool {
+	if sanctions.JaroWinkler(sanctions.NameKey(a.Name), sanctions.NameKey(b.Name)) < threshold {
+		return false
+	}
+
+	return sanctions.JaroWinkler(streetKey(a), streetKey(b)) >= threshold
+}
+
+// SetFingerprints replaces the stored fingerprints of a user's identity.
+func SetFingerprints(ctx context.Context, store Store, userID string,
+	entity *actions.EntityField) error {
+
+	return store.SetFingerprints(ctx, userID, EntityFingerprints(store, entity), time.Now())
+}
+
+// FingerprintAllUsers stores the fingerprints of every user's current identity. It is used to
+// fingerprint users that registered before duplicate detection was added, and to replace
+// fingerprints after the keys they are hashed with change. Returns the number of users
+// fingerprinted.
+func FingerprintAllUsers(ctx context.Context, store Store) (int, error) {
+	userIDs, err := store.FetchUserIDs(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "fetch users")
+	}
+
+	count := 0
+	for _, userID := range userIDs {
+		entity, err := fetchUserEntity(ctx, store, userID)
+		if err != nil {
+			return count, errors.Wrapf(err, "fetch user entity %s", userID)
+		}
+
+		if err := SetFingerprints(ctx, store, userID, entity); err != nil {
+			return count, errors.Wrapf(err, "set fingerprints %s", userID)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// FindDuplicates returns a pending duplicate for each existing user, other than userID, that
+// shares identifying information with the entity. LEIs, email addresses, and phone numbers must
+// match exactly. Names and street addresses of users at the same location match when their
+// similarity is at least threshold. The duplicates aren't stored.
+func FindDuplicates(ctx context.Context, store Store, userID string, entity *actions.EntityField,
+	threshold float64) ([]*DuplicateIdentity, error) {
+
+	fingerprints := EntityFingerprints(store, entity)
+	now := time.Now()
+
+	var result []*DuplicateIdentity
+	for _, kind := range fingerprintKinds {
+		fingerprint, exists := fingerprints[kind]
+		if !exists {
+			continue
+		}
+
+		duplicateUserIDs, err := store.FetchFingerprintUserIDs(ctx, kind, fingerprint, userID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch %s duplicates", kind)
+		}
+
+		for _, duplicateUserID := range duplicateUserIDs {
+			result = append(result, &DuplicateIdentity{
+				ID:              uuid.New().String(),
+				UserID:          userID,
+				DuplicateUserID: duplicateUserID,
+				Kind:            kind,
+				Status:          DuplicatePending,
+				DateCreated:     now,
+			})
+		}
+	}
+
+	fingerprint, exists := fingerprints[FingerprintLocation]
+	if !exists {
+		return result, nil
+	}
+
+	nearbyUserIDs, err := store.FetchFingerprintUserIDs(ctx, FingerprintLocation, fingerprint,
+		userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch location duplicates")
+	}
+
+	for _, nearbyUserID := range nearbyUserIDs {
+		nearby, err := fetchUserEntity(ctx, store, nearbyUserID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch user entity %s", nearbyUserID)
+		}
+
+		if !isSimilarNameAddress(entity, nearby, threshold) {
+			continue
+		}
+
+		result = append(result, &DuplicateIdentity{
+			ID:              uuid.New().String(),
+			UserID:          userID,
+			DuplicateUserID: nearbyUserID,
+			Kind:            FingerprintNameAddress,
+			Status:          DuplicatePending,
+			DateCreated:     now,
+		})
+	}
+
+	return result, nil
+}
+
+// DuplicateKinds returns the kinds of information shared by the duplicates, like
+// "EmailAddress,PhoneNumber".
+func DuplicateKinds(duplicates []*DuplicateIdentity) string {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, duplicate := range duplicates {
+		if !seen[duplicate.Kind] {
+			seen[duplicate.Kind] = true
+			kinds = append(kinds, duplicate.Kind)
+		}
+	}
+
+	return strings.Join(kinds, ",")
+}
+
+// FlagDuplicates records duplicates for review. Duplicates that were already recorded keep their
+// status.
+func FlagDuplicates(ctx context.Context, store Store, duplicates []*DuplicateIdentity) error {
+	for _, duplicate := range duplicates {
+		if err := store.InsertDuplicate(ctx, duplicate); err != nil {
+			return errors.Wrap(err, "insert duplicate")
+		}
+	}
+
+	return nil
+}
+
+// FetchDuplicates returns recorded duplicates, oldest first. A non-empty userID only returns
+// duplicates involving that user and a non-empty status only returns duplicates with that status.
+func FetchDuplicates(ctx context.Context, store Store, userID,
+	status string) ([]*DuplicateIdentity, error) {
+
+	return store.FetchDuplicates(ctx, userID, status)
+}
+
+// FetchDuplicate returns a recorded duplicate.
+func FetchDuplicate(ctx context.Context, store Store, id string) (*DuplicateIdentity, error) {
+	return store.FetchDuplicate(ctx, id)
+}
+
+// ReviewDuplicate records the operator's decision that a duplicate is either "linked", meaning
+// the users are separate accounts of the same party, or "dismissed", meaning they are different
+// parties.
+func ReviewDuplicate(ctx context.Context, store Store, id, status,
+	note string) (*DuplicateIdentity, error) {
+
+	if status != DuplicateLinked && status != DuplicateDismissed {
+		return nil, errors.Wrap(ErrInvalidReviewStatus, status)
+	}
+
+	result, err := FetchDuplicate(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result.Status = status
+	result.Note = note
+	result.DateReviewed = &now
+
+	if err := store.UpdateDuplicate(ctx, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MergeDuplicate merges one user of a duplicate into the other. keepUserID is the user that
+// remains and must be one of the duplicate's users. The other user's xpubs are moved to the kept
+// user and the other user is deleted.
+func MergeDuplicate(ctx context.Context, store Store, id, keepUserID,
+	note string) (*DuplicateIdentity, error) {
+
+	result, err := FetchDuplicate(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var mergeUserID string
+	switch keepUserID {
+	case result.UserID:
+		mergeUserID = result.DuplicateUserID
+	case result.DuplicateUserID:
+		mergeUserID = result.UserID
+	default:
+		return nil, errors.Wrap(ErrInvalidMergeUser, keepUserID)
+	}
+
+	if _, err := FetchUser(ctx, store, keepUserID); err != nil {
+		return nil, errors.Wrap(err, "fetch kept user")
+	}
+	if _, err := FetchUser(ctx, store, mergeUserID); err != nil {
+		return nil, errors.Wrap(err, "fetch merged user")
+	}
+
+	now := time.Now()
+	result.Status = DuplicateMerged
+	result.Note = note
+	result.DateReviewed = &now
+
+	if err := store.MergeUsers(ctx, keepUserID, mergeUserID, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -2,81 +2,152 @@ package oracle
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/tokenized/specification/dist/golang/actions"
+)
+
+const (
+	// MismatchDifferent means the oracle holds a different value for the field.
+	MismatchDifferent = "different"
+
+	// MismatchNotRegistered means the oracle holds no value for the field.
+	MismatchNotRegistered = "not_registered"
 
-	"github.com/pkg/errors"
+	// MismatchNotFound means a list item, like an administrator, is not in the oracle's list.
+	MismatchNotFound = "not_found"
+
+	// MismatchNotVerified means the field matches but the user hasn't proven control of it.
+	MismatchNotVerified = "not_verified"
+
+	// MismatchExpired means the user's identity has expired and they must be verified again.
+	MismatchExpired = "expired"
 )
 
+// EntityMismatch describes a field that doesn't match the identity held by the oracle.
+type EntityMismatch struct {
+	Field       string `json:"field"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// EntityMismatches is a list of all of the fields that don't match the identity held by the
+// oracle.
+type EntityMismatches []EntityMismatch
+
+// Error implements the error interface for EntityMismatches.
+func (m EntityMismatches) Error() string {
+	descriptions := make([]string, 0, len(m))
+	for _, mismatch := range m {
+		descriptions = append(descriptions, mismatch.Description)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
 // VerifyEntityIsSubset returns no error if all of the data in sub is specified in full.
 // This is used to allow verification of some but not all of the known data about an identity.
+// When the data doesn't match the error is an EntityMismatches listing every field that didn't
+// match. Values are compared exactly.
 func VerifyEntityIsSubset(sub, full *actions.EntityField) error {
-	if len(sub.Name) != 0 && sub.Name != full.Name {
-		return errors.New("Name doesn't match")
-	}
-	if len(sub.Type) != 0 && sub.Type != full.Type {
-		return errors.New("Type doesn't match")
+	mismatches := CompareEntity(sub, full, nil)
+	if len(mismatches) != 0 {
+		return mismatches
 	}
-	if len(sub.LEI) != 0 && sub.LEI != full.LEI {
-		return errors.New("LEI doesn't match")
-	}
-	if len(sub.UnitNumber) != 0 && sub.UnitNumber != full.UnitNumber {
-		return errors.New("UnitNumber doesn't match")
-	}
-	if len(sub.BuildingNumber) != 0 && sub.BuildingNumber != full.BuildingNumber {
-		return errors.New("BuildingNumber doesn't match")
-	}
-	if len(sub.Street) != 0 && sub.Street != full.Street {
-		return errors.New("Street doesn't match")
-	}
-	if len(sub.SuburbCity) != 0 && sub.SuburbCity != full.SuburbCity {
-		return errors.New("SuburbCity doesn't match")
-	}
-	if len(sub.TerritoryStateProvinceCode) != 0 && sub.TerritoryStateProvinceCode !=
-		full.TerritoryStateProvinceCode {
-		return errors.New("TerritoryStateProvinceCode doesn't match")
-	}
-	if len(sub.CountryCode) != 0 && sub.CountryCode != full.CountryCode {
-		return errors.New("CountryCode doesn't match")
-	}
-	if len(sub.PostalZIPCode) != 0 && sub.PostalZIPCode != full.PostalZIPCode {
-		return errors.New("PostalZIPCode doesn't match")
-	}
-	if len(sub.EmailAddress) != 0 && sub.EmailAddress != full.EmailAddress {
-		return errors.New("EmailAddress doesn't match")
+
+	return nil
+}
+
+// CompareEntity returns all of the fields in sub that are not specified in full. Values are
+// normalized before they are compared. A nil normalizer compares values exactly.
+func CompareEntity(sub, full *actions.EntityField, normalizer *Normalizer) EntityMismatches {
+	var result EntityMismatches
+
+	// Phone numbers in sub are in the country of full if sub doesn't specify a country.
+	subCountryCode := sub.CountryCode
+	if len(subCountryCode) == 0 {
+		subCountryCode = full.CountryCode
 	}
-	if len(sub.PhoneNumber) != 0 && sub.PhoneNumber != full.PhoneNumber {
-		return errors.New("PhoneNumber doesn't match")
+
+	compare := func(field, subValue, fullValue string) {
+		if len(subValue) == 0 || normalizer.Equal(field, subValue, subCountryCode, fullValue,
+			full.CountryCode) {
+			return
+		}
+
+		code := MismatchDifferent
+		if len(fullValue) == 0 {
+			code = MismatchNotRegistered
+		}
+
+		result = append(result, EntityMismatch{
+			Field:       field,
+			Code:        code,
+			Description: fmt.Sprintf("%s doesn't match", field),
+		})
 	}
 
+	compare("Name", sub.Name, full.Name)
+	compare("Type", sub.Type, full.Type)
+	compare("LEI", sub.LEI, full.LEI)
+	compare("UnitNumber", sub.UnitNumber, full.UnitNumber)
+	compare("BuildingNumber", sub.BuildingNumber, full.BuildingNumber)
+	compare("Street", sub.Street, full.Street)
+	compare("SuburbCity", sub.SuburbCity, full.SuburbCity)
+	compare("TerritoryStateProvinceCode", sub.TerritoryStateProvinceCode,
+		full.TerritoryStateProvinceCode)
+	compare("CountryCode", sub.CountryCode, full.CountryCode)
+	compare("PostalZIPCode", sub.PostalZIPCode, full.PostalZIPCode)
+	compare("EmailAddress", sub.EmailAddress, full.EmailAddress)
+	compare("PhoneNumber", sub.PhoneNumber, full.PhoneNumber)
+
 	for i, admin := range sub.Administration {
-		if !AdministratorIsInList(admin, full.Administration) {
-			return fmt.Errorf("Administrator %d", i)
+		if !normalizer.AdministratorIsInList(admin, full.Administration) {
+			result = append(result, EntityMismatch{
+				Field:       fmt.Sprintf("Administration[%d]", i),
+				Code:        MismatchNotFound,
+				Description: fmt.Sprintf("Administrator %d", i),
+			})
 		}
 	}
 
 	for i, manager := range sub.Management {
-		if !ManagerIsInList(manager, full.Management) {
-			return fmt.Errorf("Manager %d", i)
+		if !normalizer.ManagerIsInList(manager, full.Management) {
+			result = append(result, EntityMismatch{
+				Field:       fmt.Sprintf("Management[%d]", i),
+				Code:        MismatchNotFound,
+				Description: fmt.Sprintf("Manager %d", i),
+			})
 		}
 	}
 
-	if len(sub.DomainName) != 0 && sub.DomainName != full.DomainName {
-		return errors.New("DomainName doesn't match")
-	}
-	if len(sub.PaymailHandle) != 0 && sub.PaymailHandle != full.PaymailHandle {
-		return errors.New("PaymailHandle doesn't match")
-	}
+	compare("DomainName", sub.DomainName, full.DomainName)
+	compare("PaymailHandle", sub.PaymailHandle, full.PaymailHandle)
 
-	return nil
+	return result
 }
 
 // AdministratorIsInList returns true if the administrator is in the list.
 func AdministratorIsInList(admin *actions.AdministratorField,
 	list []*actions.AdministratorField) bool {
 
+	var normalizer *Normalizer
+	return normalizer.AdministratorIsInList(admin, list)
+}
+
+// ManagerIsInList returns true if the manager is in the list.
+func ManagerIsInList(manager *actions.ManagerField, list []*actions.ManagerField) bool {
+	var normalizer *Normalizer
+	return normalizer.ManagerIsInList(manager, list)
+}
+
+// AdministratorIsInList returns true if the administrator is in the list comparing normalized
+// names.
+func (n *Normalizer) AdministratorIsInList(admin *actions.AdministratorField,
+	list []*actions.AdministratorField) bool {
+
+	name := n.Normalize("AdministratorName", admin.Name, "")
 	for _, item := range list {
-		if admin.Type == item.Type && admin.Name == item.Name {
+		if admin.Type == item.Type && name == n.Normalize("AdministratorName", item.Name, "") {
 			return true // found match
 		}
 	}
@@ -84,10 +155,13 @@ func AdministratorIsInList(admin *actions.AdministratorField,
 	return false
 }
 
-// ManagerIsInList returns true if the manager is in the list.
-func ManagerIsInList(manager *actions.ManagerField, list []*actions.ManagerField) bool {
+// ManagerIsInList returns true if the manager is in the list comparing normalized names.
+func (n *Normalizer) ManagerIsInList(manager *actions.ManagerField,
+	list []*actions.ManagerField) bool {
+
+	name := n.Normalize("ManagerName", manager.Name, "")
 	for _, item := range list {
-		if manager.Type == item.Type && manager.Name == item.Name {
+		if manager.Type == item.Type && name == n.Normalize("ManagerName", item.Name, "") {
 			return true // found match
 		}
 	}
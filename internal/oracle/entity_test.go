@@ -355,3 +355,57 @@ func TestEntityIsSubset(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareEntity(t *testing.T) {
+	sub := &actions.EntityField{
+		Name:         "Tokenized LLC",
+		EmailAddress: "satoshi@tokenized.com",
+		CountryCode:  "AUS",
+		Administration: []*actions.AdministratorField{
+			&actions.AdministratorField{
+				Type: 2,
+				Name: "Satoshi Nakamoto",
+			},
+			&actions.AdministratorField{
+				Type: 5,
+				Name: "John",
+			},
+		},
+	}
+
+	full := &actions.EntityField{
+		Name:        "Tokenized",
+		CountryCode: "AUS",
+		Administration: []*actions.AdministratorField{
+			&actions.AdministratorField{
+				Type: 2,
+				Name: "Satoshi Nakamoto",
+			},
+		},
+	}
+
+	want := EntityMismatches{
+		{Field: "Name", Code: MismatchDifferent, Description: "Name doesn't match"},
+		{Field: "EmailAddress", Code: MismatchNotRegistered,
+			Description: "EmailAddress doesn't match"},
+		{Field: "Administration[1]", Code: MismatchNotFound, Description: "Administrator 1"},
+	}
+
+	got := CompareEntity(sub, full, nil)
+
+	if len(got) != len(want) {
+		t.Fatalf("Wrong mismatch count : got %d, want %d : %s", len(got), len(want), got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Wrong mismatch %d : got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if err := VerifyEntityIsSubset(sub, full); err == nil {
+		t.Errorf("Entity should not have verified")
+	} else if err.Error() != want.Error() {
+		t.Errorf("Wrong error : got %s, want %s", err, want)
+	}
+}
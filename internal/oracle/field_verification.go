@@ -0,0 +1,345 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/identity-oracle/internal/platform/sms"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxVerificationAttempts is the number of codes that can be tried before a verification has
+	// to be restarted.
+	MaxVerificationAttempts = 5
+)
+
+// CreateFieldVerification starts verification of a user's field value and returns the one-time
+// code that must be delivered to the user. Only hashes of the value and code are stored.
+func CreateFieldVerification(ctx context.Context, store Store, userID, field, value string,
+	duration time.Duration) (string, error) {
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return "", errors.Wrap(err, "generate code")
+	}
+
+	if err := createFieldVerification(ctx, store, userID, field, value, code,
+		duration); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+func createFieldVerification(ctx context.Context, store Store, userID, field, value,
+	code string, duration time.Duration) error {
+
+	id := uuid.New().String()
+	now := time.Now()
+
+	return store.InsertFieldVerification(ctx, &FieldVerification{
+		ID:          id,
+		UserID:      userID,
+		Field:       field,
+		ValueHash:   verificationValueHash(store, field, value),
+		CodeHash:    verificationCodeHash(id, code),
+		DateCreated: now,
+		DateExpires: now.Add(duration),
+	})
+}
+
+// ConfirmFieldVerification checks the code against the latest pending verification of the user's
+// field value and marks it verified if it matches. The verification expires according to validity.
+func ConfirmFieldVerification(ctx context.Context, store Store, validity *Validity, userID,
+	field, value, code string) (*FieldVerification, error) {
+
+	verifications, err := store.FetchFieldVerificationsByValue(ctx, userID, field,
+		verificationValueHash(store, field, value))
+	if err != nil {
+		return nil, err
+	}
+
+	var result *FieldVerification
+	for _, verification := range verifications {
+		if verification.DateVerified == nil {
+			result = verification
+			break
+		}
+	}
+
+	if result == nil {
+		return nil, errors.Wrap(ErrVerificationNotFound, field)
+	}
+
+	now := time.Now()
+	if now.After(result.DateExpires) {
+		return nil, errors.Wrap(ErrVerificationExpired, field)
+	}
+
+	// The attempt is recorded before the code is checked so that concurrent guesses can't get
+	// past the limit.
+	counted, err := store.IncrementVerificationAttempts(ctx, result.ID, MaxVerificationAttempts)
+	if err != nil {
+		return nil, errors.Wrap(err, "update attempts")
+	}
+
+	if !counted {
+		return nil, errors.Wrap(ErrVerificationAttempts, field)
+	}
+
+	if subtle.ConstantTimeCompare(verificationCodeHash(result.ID, code), result.CodeHash) != 1 {
+		return nil, errors.Wrap(ErrInvalidVerificationCode, field)
+	}
+
+	if err := markFieldVerified(ctx, store, validity, result, now); err != nil {
+		return nil, errors.Wrap(err, "mark verified")
+	}
+
+	return result, nil
+}
+
+func markFieldVerified(ctx context.Context, store Store, validity *Validity,
+	verification *FieldVerification, now time.Time) error {
+
+	validUntil := validity.FieldExpiry(verification.Field, now)
+	if err := store.MarkFieldVerified(ctx, verification.ID, now, validUntil); err != nil {
+		return err
+	}
+
+	verification.DateVerified = &now
+	verification.DateValidUntil = validUntil
+	return nil
+}
+
+// IsFieldVerified returns true if the user has verified the field value and the verification
+// hasn't expired.
+func IsFieldVerified(ctx context.Context, store Store, userID, field,
+	value string) (bool, error) {
+
+	verifications, err := store.FetchFieldVerificationsByValue(ctx, userID, field,
+		verificationValueHash(store, field, value))
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	for _, verification := range verifications {
+		if verification.isValid(now) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isValid returns true if the verification was completed and hasn't expired at the time
+// specified.
+func (v *FieldVerification) isValid(now time.Time) bool {
+	return v.DateVerified != nil && (v.DateValidUntil == nil || v.DateValidUntil.After(now))
+}
+
+// SendEmailVerification starts verification of a user's email address and emails the code to it.
+func SendEmailVerification(ctx context.Context, store Store, mailer mail.Mailer, userID,
+	email string, duration time.Duration) error {
+
+	code, err := CreateFieldVerification(ctx, store, userID, "EmailAddress", email, duration)
+	if err != nil {
+		return errors.Wrap(err, "create verification")
+	}
+
+	body := fmt.Sprintf("Your identity oracle email verification code is %s\n\n"+
+		"It expires in %s.\n", code, duration)
+
+	if err := mailer.Send(ctx, email, "Email verification code", body); err != nil {
+		return errors.Wrap(err, "send email")
+	}
+
+	return nil
+}
+
+// SendPhoneVerification starts verification of a user's phone number and texts the code to it.
+func SendPhoneVerification(ctx context.Context, store Store, sender sms.SMSSender, userID,
+	phoneNumber string, duration time.Duration) error {
+
+	code, err := CreateFieldVerification(ctx, store, userID, "PhoneNumber", phoneNumber,
+		duration)
+	if err != nil {
+		return errors.Wrap(err, "create verification")
+	}
+
+	message := fmt.Sprintf("Your identity oracle verification code is %s", code)
+
+	if err := sender.SendSMS(ctx, phoneNumber, message); err != nil {
+		return errors.Wrap(err, "send sms")
+	}
+
+	return nil
+}
+
+// RehashFieldVerifications replaces the value hashes of field verifications that were hashed with
+// a previous key, like before value hashes were keyed or before the master key was rotated. Since
+// values aren't stored, only verifications of values in a user's current or previous identities
+// can be rehashed. Returns the number of verifications rehashed.
+func RehashFieldVerifications(ctx context.Context, store Store) (int, error) {
+	userIDs, err := store.FetchUserIDs(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "fetch users")
+	}
+
+	count := 0
+	for _, userID := range userIDs {
+		verifications, err := store.FetchFieldVerifications(ctx, userID)
+		if err != nil {
+			return count, errors.Wrapf(err, "fetch field verifications %s", userID)
+		}
+
+		if len(verifications) == 0 {
+			continue
+		}
+
+		values, err := fetchVerifiableFieldValues(ctx, store, userID)
+		if err != nil {
+			return count, errors.Wrapf(err, "fetch field values %s", userID)
+		}
+
+		for _, verification := range verifications {
+			for _, value := range values[verification.Field] {
+				previous := store.PreviousValueHashes([]byte(verification.Field + ":" + value))
+				if !containsHash(previous, verification.ValueHash) {
+					continue
+				}
+
+				valueHash := verificationValueHash(store, verification.Field, value)
+				if err := store.SetVerificationValueHash(ctx, verification.ID,
+					valueHash); err != nil {
+					return count, errors.Wrapf(err, "set value hash %s", verification.ID)
+				}
+
+				count++
+				break
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// fetchVerifiableFieldValues returns the values of each verifiable field in the user's current
+// and previous identities.
+func fetchVerifiableFieldValues(ctx context.Context, store Store,
+	userID string) (map[string][]string, error) {
+
+	entity, err := fetchUserEntity(ctx, store, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch user entity")
+	}
+	entities := []*actions.EntityField{entity}
+
+	userEntities, err := store.FetchUserEntities(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch user entities")
+	}
+
+	for _, userEntity := range userEntities {
+		previous := &actions.EntityField{}
+		if err := proto.Unmarshal(userEntity.Entity, previous); err != nil {
+			return nil, errors.Wrap(err, "unmarshal entity")
+		}
+		entities = append(entities, previous)
+	}
+
+	result := make(map[string][]string)
+	for _, field := range verifiableFields {
+		for _, entity := range entities {
+			if value := VerifiableFieldValue(entity, field); len(value) != 0 {
+				result[field] = append(result[field], value)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func containsHash(hashes [][]byte, hash []byte) bool {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchFieldVerifications returns all of a user's field verifications, oldest first.
+func FetchFieldVerifications(ctx context.Context, store Store,
+	userID string) ([]*FieldVerification, error) {
+
+	return store.FetchFieldVerifications(ctx, userID)
+}
+
+// FetchVerifiedFields returns the verification state of each verifiable field included in the
+// entity.
+func FetchVerifiedFields(ctx context.Context, store Store, userID string,
+	entity *actions.EntityField) (map[string]bool, error) {
+
+	result := make(map[string]bool)
+	for _, field := range verifiableFields {
+		value := VerifiableFieldValue(entity, field)
+		if len(value) == 0 {
+			continue
+		}
+
+		verified, err := IsFieldVerified(ctx, store, userID, field, value)
+		if err != nil {
+			return nil, errors.Wrap(err, field)
+		}
+
+		result[field] = verified
+	}
+
+	return result, nil
+}
+
+// generateVerificationCode returns a random 6 digit code.
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// VerificationSigHash returns the hash a user signs to confirm a field verification. It commits to
+// the user id, the field, and the code so that only the user can use up the verification's
+// attempts.
+func VerificationSigHash(userID uuid.UUID, field, code string) bitcoin.Hash32 {
+	s := sha256.New()
+	s.Write(userID[:])
+	s.Write([]byte(field))
+	s.Write([]byte(code))
+	return bitcoin.Hash32(sha256.Sum256(s.Sum(nil)))
+}
+
+// verificationValueHash returns the hash a field value is looked up by. It is keyed by the store
+// so that values can't be found by hashing guesses.
+func verificationValueHash(store Store, field, value string) []byte {
+	return store.HashValue([]byte(field + ":" + value))
+}
+
+func verificationCodeHash(id, code string) []byte {
+	hash := sha256.Sum256([]byte(id + ":" + code))
+	return hash[:]
+}
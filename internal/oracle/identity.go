@@ -2,8 +2,8 @@ package oracle
 
 import (
 	"context"
+	"time"
 
-	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
 	"github.com/tokenized/specification/dist/golang/actions"
@@ -13,8 +13,33 @@ import (
 	"github.com/pkg/errors"
 )
 
-func VerifyPubKey(ctx context.Context, user *User, headers Headers,
-	entity *actions.EntityField, xpub bitcoin.ExtendedKey, index uint32) (*SignatureHash, error) {
+// checkIdentity returns the reasons the user's identity isn't approved. The entity must match the
+// entity registered to the user, the user's identity must not have expired, and the requirements
+// must be met. Only the first of these that fails is reported.
+func checkIdentity(ctx context.Context, store Store, user *User, normalizer *Normalizer,
+	requirements *Requirements, entity, userEntity *actions.EntityField) (EntityMismatches, error) {
+
+	mismatches := CompareEntity(entity, userEntity, normalizer)
+	if len(mismatches) != 0 {
+		return mismatches, nil
+	}
+
+	mismatches = CheckIdentityExpiry(user, time.Now())
+	if len(mismatches) != 0 {
+		return mismatches, nil
+	}
+
+	unmet, err := requirements.Check(ctx, store, user.ID, entity, userEntity)
+	if err != nil {
+		return nil, errors.Wrap(err, "check requirements")
+	}
+
+	return unmet, nil
+}
+
+func VerifyPubKey(ctx context.Context, store Store, user *User, headers Headers,
+	normalizer *Normalizer, requirements *Requirements, entity *actions.EntityField,
+	xpub bitcoin.ExtendedKey, index uint32) (*SignatureHash, error) {
 
 	userEntity := &actions.EntityField{}
 	if err := proto.Unmarshal(user.Entity, userEntity); err != nil {
@@ -25,8 +50,13 @@ func VerifyPubKey(ctx context.Context, user *User, headers Headers,
 	approved := true
 	approve := uint8(1)
 	var description string
-	if err := VerifyEntityIsSubset(entity, userEntity); err != nil {
-		description = err.Error()
+	mismatches, err := checkIdentity(ctx, store, user, normalizer, requirements, entity,
+		userEntity)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) != 0 {
+		description = mismatches.Error()
 		approved = false
 		approve = 0
 	}
@@ -55,11 +85,13 @@ func VerifyPubKey(ctx context.Context, user *User, headers Headers,
 		BlockHeight: height,
 		Approved:    approved,
 		Description: description,
+		Mismatches:  mismatches,
 	}, nil
 }
 
-func VerifyXPub(ctx context.Context, user *User, headers Headers,
-	entity *actions.EntityField, xpub bitcoin.ExtendedKeys) (*SignatureHash, error) {
+func VerifyXPub(ctx context.Context, store Store, user *User, headers Headers,
+	normalizer *Normalizer, requirements *Requirements, entity *actions.EntityField,
+	xpub bitcoin.ExtendedKeys) (*SignatureHash, error) {
 
 	userEntity := &actions.EntityField{}
 	if err := proto.Unmarshal(user.Entity, userEntity); err != nil {
@@ -70,8 +102,13 @@ func VerifyXPub(ctx context.Context, user *User, headers Headers,
 	approved := true
 	approve := uint8(1)
 	var description string
-	if err := VerifyEntityIsSubset(entity, userEntity); err != nil {
-		description = err.Error()
+	mismatches, err := checkIdentity(ctx, store, user, normalizer, requirements, entity,
+		userEntity)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) != 0 {
+		description = mismatches.Error()
 		approved = false
 		approve = 0
 	}
@@ -92,6 +129,7 @@ func VerifyXPub(ctx context.Context, user *User, headers Headers,
 		BlockHeight: height,
 		Approved:    approved,
 		Description: description,
+		Mismatches:  mismatches,
 	}, nil
 }
 
@@ -101,17 +139,17 @@ func VerifyXPub(ctx context.Context, user *User, headers Headers,
 //   uint32 - block height of block hash included in signature hash
 //   bitcoin.Hash32 - block hash included in signature hash
 //   bool - true if approved
-func CreateAdminCertificate(ctx context.Context, dbConn *db.DB, user *User, net bitcoin.Network,
-	isTest bool, headers Headers, contracts Contracts, xpubs bitcoin.ExtendedKeys, index uint32,
-	issuer actions.EntityField, entityContract bitcoin.RawAddress,
-	expiration uint64) (*SignatureHash, error) {
+func CreateAdminCertificate(ctx context.Context, store Store, user *User, net bitcoin.Network,
+	isTest bool, headers Headers, contracts Contracts, normalizer *Normalizer,
+	requirements *Requirements, xpubs bitcoin.ExtendedKeys, index uint32, issuer actions.EntityField,
+	entityContract bitcoin.RawAddress, expiration uint64) (*SignatureHash, error) {
 
 	userEntity := &actions.EntityField{}
 	if err := proto.Unmarshal(user.Entity, userEntity); err != nil {
 		return nil, errors.Wrap(err, "unmarshal user entity")
 	}
 
-	xpubData, err := FetchXPubByXPub(ctx, dbConn, xpubs)
+	xpubData, err := FetchXPubByXPub(ctx, store, xpubs)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetch xpub")
 	}
@@ -160,8 +198,13 @@ func CreateAdminCertificate(ctx context.Context, dbConn *db.DB, user *User, net
 	}
 
 	// Verify the entity matches that registered to the user.
-	if err := VerifyEntityIsSubset(checkEntity, userEntity); err != nil {
-		description = err.Error()
+	mismatches, err := checkIdentity(ctx, store, user, normalizer, requirements, checkEntity,
+		userEntity)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) != 0 {
+		description = mismatches.Error()
 		approved = false
 		approve = 0
 	}
@@ -185,5 +228,6 @@ func CreateAdminCertificate(ctx context.Context, dbConn *db.DB, user *User, net
 		BlockHeight: height,
 		Approved:    approved,
 		Description: description,
+		Mismatches:  mismatches,
 	}, nil
 }
@@ -0,0 +1,154 @@
+package oracle
+
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"go.opencensus.io/trace"
+)
+
+var (
+	approverDuration = metrics.NewHistogram("identity_oracle_approver_duration_seconds",
+		"Approver call latency by method.", metrics.DefaultBuckets, "method")
+	approverCalls = metrics.NewCounter("identity_oracle_approver_calls_total",
+		"Approver calls by method and result (approved, denied, or error).", "method", "result")
+)
+
+// InstrumentedApprover traces calls to the next approver and records their latency and results.
+type InstrumentedApprover struct {
+	Next ApproverInterface
+}
+
+// instrumentedVerificationApprover is an InstrumentedApprover whose next approver sets
+// verification levels.
+type instrumentedVerificationApprover struct {
+	*InstrumentedApprover
+	next VerificationApproverInterface
+}
+
+// NewInstrumentedApprover creates an approver that traces and records metrics of next. The result
+// implements VerificationApproverInterface when next does.
+func NewInstrumentedApprover(next ApproverInterface) ApproverInterface {
+	result := &InstrumentedApprover{
+		Next: next,
+	}
+
+	if verificationApprover, ok := next.(VerificationApproverInterface); ok {
+		return &instrumentedVerificationApprover{
+			InstrumentedApprover: result,
+			next:                 verificationApprover,
+		}
+	}
+
+	return result
+}
+
+func (a *InstrumentedApprover) ApproveRegistration(ctx context.Context, userID string,
+	entity actions.EntityField, publicKey bitcoin.PublicKey) (bool, string, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.ApproveRegistration")
+	defer span.End()
+
+	start := time.Now()
+	approved, description, err := a.Next.ApproveRegistration(ctx, userID, entity, publicKey)
+	observeApprover(span, "ApproveRegistration", start, approved, err)
+	return approved, description, err
+}
+
+func (a *InstrumentedApprover) UpdateIdentity(ctx context.Context, userID string,
+	entity actions.EntityField) (bool, string, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.UpdateIdentity")
+	defer span.End()
+
+	start := time.Now()
+	approved, description, err := a.Next.UpdateIdentity(ctx, userID, entity)
+	observeApprover(span, "UpdateIdentity", start, approved, err)
+	return approved, description, err
+}
+
+func (a *InstrumentedApprover) ApproveIdentity(ctx context.Context,
+	userID string) (bool, string, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.ApproveIdentity")
+	defer span.End()
+
+	start := time.Now()
+	approved, description, err := a.Next.ApproveIdentity(ctx, userID)
+	observeApprover(span, "ApproveIdentity", start, approved, err)
+	return approved, description, err
+}
+
+func (a *InstrumentedApprover) ApproveTransfer(ctx context.Context, contract, instrumentID string,
+	userID string) (bool, string, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.ApproveTransfer")
+	defer span.End()
+
+	start := time.Now()
+	approved, description, err := a.Next.ApproveTransfer(ctx, contract, instrumentID, userID)
+	observeApprover(span, "ApproveTransfer", start, approved, err)
+	return approved, description, err
+}
+
+// HealthCheck checks the next approver.
+func (a *InstrumentedApprover) HealthCheck(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.HealthCheck")
+	defer span.End()
+
+	err := CheckApproverHealth(ctx, a.Next)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnavailable, Message: err.Error()})
+	}
+	return err
+}
+
+// IdentityReverified checks whether the next approver verified the identity again.
+func (a *InstrumentedApprover) IdentityReverified(ctx context.Context, userID string,
+	entity actions.EntityField) (bool, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.IdentityReverified")
+	defer span.End()
+
+	start := time.Now()
+	reverified, err := IdentityReverified(ctx, a.Next, userID, entity)
+	observeApprover(span, "IdentityReverified", start, reverified, err)
+	return reverified, err
+}
+
+func (a *instrumentedVerificationApprover) VerificationLevel(ctx context.Context, userID string,
+	entity actions.EntityField) (int, []*UserAttribute, error) {
+
+	ctx, span := trace.StartSpan(ctx, "oracle.Approver.VerificationLevel")
+	defer span.End()
+
+	start := time.Now()
+	level, attributes, err := a.next.VerificationLevel(ctx, userID, entity)
+	observeApprover(span, "VerificationLevel", start, true, err)
+	return level, attributes, err
+}
+
+func observeApprover(span *trace.Span, method string, start time.Time, approved bool,
+	err error) {
+
+	approverDuration.ObserveSince(start, method)
+
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	} else {
+		span.AddAttributes(trace.BoolAttribute("approved", approved))
+	}
+
+	switch {
+	case err != nil:
+		approverCalls.Inc(method, "error")
+	case approved:
+		approverCalls.Inc(method, "approved")
+	default:
+		approverCalls.Inc(method, "denied")
+	}
+}
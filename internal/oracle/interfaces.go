@@ -42,3 +42,52 @@ type ApproverInterface interface {
 	ApproveTransfer(ctx context.Context, contract, instrumentID string,
 		userID string) (bool, string, error)
 }
+
+// VerificationApproverInterface is optionally implemented by an approver to set the verification
+// level and attributes of a user when their identity is registered or updated.
+type VerificationApproverInterface interface {
+	// VerificationLevel returns the verification level of the user and the attributes to set on
+	// the user. Attributes the user already has that are not returned are left unchanged.
+	VerificationLevel(ctx context.Context, userID string,
+		entity actions.EntityField) (int, []*UserAttribute, error)
+}
+
+// ReverificationApproverInterface is optionally implemented by an approver that verifies the
+// identity again when it approves an update, so the identity's validity period can restart.
+type ReverificationApproverInterface interface {
+	// IdentityReverified is called after an approved identity update and returns true if the
+	// approver verified the identity again.
+	IdentityReverified(ctx context.Context, userID string, entity actions.EntityField) (bool, error)
+}
+
+// HealthCheckerInterface is optionally implemented by an approver that depends on another service
+// so that readiness checks can report whether it is reachable.
+type HealthCheckerInterface interface {
+	// HealthCheck returns an error when the approver can't currently be reached.
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckApproverHealth returns the result of the approver's health check. Approvers that don't
+// implement HealthCheckerInterface, and nil approvers, are considered healthy.
+func CheckApproverHealth(ctx context.Context, approver ApproverInterface) error {
+	checker, ok := approver.(HealthCheckerInterface)
+	if !ok {
+		return nil
+	}
+
+	return checker.HealthCheck(ctx)
+}
+
+// IdentityReverified returns whether the approver verified the identity again when approving an
+// update. Approvers that don't implement ReverificationApproverInterface, and nil approvers, don't
+// re-verify identities.
+func IdentityReverified(ctx context.Context, approver ApproverInterface, userID string,
+	entity actions.EntityField) (bool, error) {
+
+	reverifier, ok := approver.(ReverificationApproverInterface)
+	if !ok {
+		return false, nil
+	}
+
+	return reverifier.IdentityReverified(ctx, userID, entity)
+}
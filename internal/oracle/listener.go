@@ -8,24 +8,67 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
 	"github.com/tokenized/specification/dist/golang/actions"
 	"github.com/tokenized/specification/dist/golang/protocol"
 	"github.com/tokenized/spynode/pkg/client"
+	"go.opencensus.io/trace"
 )
 
 const (
 	// contractsStorageKey is the path to the contract formations.
 	contractsStorageKey = "contract_formations"
 
+	// chainstateStorageKey is the path to the spynode checkpoint. Version 0 contains the next
+	// message id. Version 1 adds when it was saved.
 	chainstateStorageKey = "chainstate"
-	chainstateVersion    = uint8(0)
+	chainstateVersion    = uint8(1)
 )
 
+var (
+	headerHeight = metrics.NewGauge("identity_oracle_header_height",
+		"Height of the latest block header.")
+	spyNodeReady = metrics.NewGauge("identity_oracle_spynode_ready",
+		"1 when the spynode client is registered and receiving messages.")
+	spyNodeInSync = metrics.NewGauge("identity_oracle_spynode_in_sync",
+		"1 when the spynode has caught up with the chain.")
+	contractFormations = metrics.NewCounter("identity_oracle_contract_formations_total",
+		"Contract formations seen by result (saved, updated, or ignored).", "result")
+
+	// latestHeaderReceived is the unix time, in nanoseconds, that the latest header was received.
+	latestHeaderReceived int64
+)
+
+func init() {
+	metrics.NewGaugeFunc("identity_oracle_header_age_seconds",
+		"Seconds since the latest block header was received.", func() float64 {
+			received := atomic.LoadInt64(&latestHeaderReceived)
+			if received == 0 {
+				return 0
+			}
+			return time.Since(time.Unix(0, received)).Seconds()
+		})
+}
+
+// recordHeaders records the height of the latest header and when it was received.
+func (l *Listener) recordHeaders(height uint32) {
+	now := time.Now()
+
+	l.statusLock.Lock()
+	l.headersReceived = now
+	l.statusLock.Unlock()
+
+	headerHeight.Set(float64(height))
+	atomic.StoreInt64(&latestHeaderReceived, now.UnixNano())
+}
+
 type Headers interface {
 	// RecentSigHash returns a header hash and height for the current tip -4
 	RecentSigHash(context.Context) (*bitcoin.Hash32, uint32, error)
@@ -37,6 +80,28 @@ type Contracts interface {
 	GetContractFormation(context.Context, bitcoin.RawAddress) (*actions.ContractFormation, error)
 }
 
+// ChainState reports whether block headers are available to sign with.
+type ChainState interface {
+	ChainStatus() ChainStatus
+}
+
+// ChainStatus is the state of the spynode connection and block headers.
+type ChainStatus struct {
+	// SpyNodeReady is true when the client is registered with spynode and receiving messages.
+	SpyNodeReady bool
+
+	// InSync is true when spynode has caught up with the chain.
+	InSync bool
+
+	// HeaderCount is how many recent headers are held. Signatures require HeadersRequired.
+	HeaderCount     int
+	HeadersRequired int
+	Height          uint32
+
+	// HeadersReceived is when the latest header was received. Zero when none have been.
+	HeadersReceived time.Time
+}
+
 type Listener struct {
 	spyNode client.Client
 	dbConn  *db.DB
@@ -47,6 +112,16 @@ type Listener struct {
 	hashes     []bitcoin.Hash32
 	height     uint32
 	hashesLock sync.Mutex
+
+	spyNodeReady    bool
+	inSync          bool
+	headersReceived time.Time
+	statusLock      sync.Mutex
+
+	// Checkpoints aren't saved until spynode has been told which message to start from.
+	checkpointEnabled bool
+	checkpointID      uint64
+	checkpointLock    sync.Mutex
 }
 
 func NewListener(spyNode client.Client, dbConn *db.DB, net bitcoin.Network, isTest bool) *Listener {
@@ -70,6 +145,25 @@ func (l *Listener) RecentSigHash(ctx context.Context) (*bitcoin.Hash32, uint32,
 	return &l.hashes[0], l.height - uint32(l.offset) + 1, nil
 }
 
+// ChainStatus returns the state of the spynode connection and block headers.
+func (l *Listener) ChainStatus() ChainStatus {
+	l.hashesLock.Lock()
+	result := ChainStatus{
+		HeaderCount:     len(l.hashes),
+		HeadersRequired: l.offset,
+		Height:          l.height,
+	}
+	l.hashesLock.Unlock()
+
+	l.statusLock.Lock()
+	result.SpyNodeReady = l.spyNodeReady
+	result.InSync = l.inSync
+	result.HeadersReceived = l.headersReceived
+	l.statusLock.Unlock()
+
+	return result
+}
+
 func (l *Listener) GetContractFormation(ctx context.Context,
 	ra bitcoin.RawAddress) (*actions.ContractFormation, error) {
 
@@ -94,6 +188,10 @@ func (l *Listener) GetContractFormation(ctx context.Context,
 }
 
 func (l *Listener) HandleTx(ctx context.Context, tx *client.Tx) {
+	ctx, span := trace.StartSpan(ctx, "oracle.Listener.HandleTx")
+	defer span.End()
+	defer l.checkpoint(ctx)
+
 	// Only look for contract formations and save them.
 	if len(tx.Outputs) == 0 {
 		return
@@ -125,6 +223,9 @@ func (l *Listener) HandleTx(ctx context.Context, tx *client.Tx) {
 func (l *Listener) HandleTxUpdate(ctx context.Context, update *client.TxUpdate) {}
 
 func (l *Listener) HandleHeaders(ctx context.Context, headers *client.Headers) {
+	ctx, span := trace.StartSpan(ctx, "oracle.Listener.HandleHeaders")
+	defer span.End()
+
 	count := len(headers.Headers)
 	if count == 0 {
 		return
@@ -138,7 +239,15 @@ func (l *Listener) HandleHeaders(ctx context.Context, headers *client.Headers) {
 	// Append any matching
 	latest := l.hashes[len(l.hashes)-1]
 	appendedCount := 0
+	knownCount := 0
 	for _, header := range headers.Headers {
+		if l.hasHash(header.BlockHash()) {
+			// Already have this header, possibly from a replay of messages since the last
+			// checkpoint.
+			knownCount++
+			continue
+		}
+
 		if header.PrevBlock.Equal(&latest) {
 			// Add as new latest
 			latest = *header.BlockHash()
@@ -167,9 +276,16 @@ func (l *Listener) HandleHeaders(ctx context.Context, headers *client.Headers) {
 		}
 	}
 
+	height := l.height
 	l.hashesLock.Unlock()
 
 	if appendedCount == 0 {
+		if knownCount != 0 {
+			logger.Info(ctx, "Already have %d headers", knownCount)
+			l.checkpoint(ctx)
+			return
+		}
+
 		// No link to current hashes. Something must have gone wrong so just rebuild.
 		logger.Info(ctx, "No link to new headers. Reinitializing")
 		if err := l.InitializeHeaders(ctx); err != nil {
@@ -179,12 +295,34 @@ func (l *Listener) HandleHeaders(ctx context.Context, headers *client.Headers) {
 	}
 
 	logger.Info(ctx, "Appended %d headers", appendedCount)
+	l.recordHeaders(height)
 	if err := l.cleanHashes(ctx); err != nil {
 		logger.Error(ctx, "Failed to clean hashes : %s", err)
 	}
+
+	l.checkpoint(ctx)
+}
+
+// hasHash returns true if the hash is one of the recent headers. hashesLock must be held.
+func (l *Listener) hasHash(hash *bitcoin.Hash32) bool {
+	for _, h := range l.hashes {
+		if h.Equal(hash) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (l *Listener) HandleInSync(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "oracle.Listener.HandleInSync")
+	defer span.End()
+
+	l.statusLock.Lock()
+	l.inSync = true
+	l.statusLock.Unlock()
+	spyNodeInSync.Set(1)
+
 	l.hashesLock.Lock()
 	defer l.hashesLock.Unlock()
 
@@ -197,6 +335,9 @@ func (l *Listener) HandleInSync(ctx context.Context) {
 }
 
 func (l *Listener) HandleMessage(ctx context.Context, payload client.MessagePayload) {
+	ctx, span := trace.StartSpan(ctx, "oracle.Listener.HandleMessage")
+	defer span.End()
+
 	switch msg := payload.(type) {
 	case *client.AcceptRegister:
 		logger.Info(ctx, "SpyNode registration accepted")
@@ -229,8 +370,17 @@ func (l *Listener) HandleMessage(ctx context.Context, payload client.MessagePayl
 				return
 			}
 
+			l.statusLock.Lock()
+			l.spyNodeReady = true
+			l.statusLock.Unlock()
+			spyNodeReady.Set(1)
 			logger.Info(ctx, "SpyNode client ready at next message %d", nextMessageID)
 
+			l.checkpointLock.Lock()
+			l.checkpointEnabled = true
+			l.checkpointID = nextMessageID
+			l.checkpointLock.Unlock()
+
 			if err := l.InitializeHeaders(ctx); err != nil {
 				logger.Error(ctx, "Failed to initialize headers : %s", err)
 			}
@@ -238,6 +388,17 @@ func (l *Listener) HandleMessage(ctx context.Context, payload client.MessagePayl
 	}
 }
 
+// HandleDisconnect records that the spynode stopped.
+func (l *Listener) HandleDisconnect(ctx context.Context) {
+	l.statusLock.Lock()
+	l.spyNodeReady = false
+	l.inSync = false
+	l.statusLock.Unlock()
+
+	spyNodeReady.Set(0)
+	spyNodeInSync.Set(0)
+}
+
 func (l *Listener) cleanHashes(ctx context.Context) error {
 	l.hashesLock.Lock()
 
@@ -289,9 +450,12 @@ func (l *Listener) InitializeHeaders(ctx context.Context) error {
 		l.hashes[i] = *header.BlockHash()
 	}
 
+	height := l.height
 	l.hashesLock.Unlock()
 
-	logger.Info(ctx, "Pulled initial headers (%d) to height %d : %s", count, l.height,
+	l.recordHeaders(height)
+
+	logger.Info(ctx, "Pulled initial headers (%d) to height %d : %s", count, height,
 		headers.Headers[count-1].BlockHash())
 
 	return nil
@@ -317,9 +481,15 @@ func (l *Listener) SaveContractFormation(ctx context.Context, ra bitcoin.RawAddr
 			return errors.Wrap(err, "write contract formation")
 		}
 
+		contractFormations.Inc("saved")
 		return nil
 	}
 
+	if bytes.Equal(b, script) {
+		contractFormations.Inc("ignored")
+		return nil // already saved, probably replayed since the last checkpoint
+	}
+
 	// Check timestamp vs current version to ensure we keep the latest.
 	action, err := protocol.Deserialize(b, l.isTest)
 	if err != nil {
@@ -330,6 +500,7 @@ func (l *Listener) SaveContractFormation(ctx context.Context, ra bitcoin.RawAddr
 			return errors.Wrap(err, "write contract formation")
 		}
 
+		contractFormations.Inc("updated")
 		return nil
 	}
 
@@ -342,10 +513,12 @@ func (l *Listener) SaveContractFormation(ctx context.Context, ra bitcoin.RawAddr
 			return errors.Wrap(err, "write contract formation")
 		}
 
+		contractFormations.Inc("updated")
 		return nil
 	}
 
 	if current.Timestamp > formation.Timestamp {
+		contractFormations.Inc("ignored")
 		return nil // already have a later version
 	}
 
@@ -355,9 +528,11 @@ func (l *Listener) SaveContractFormation(ctx context.Context, ra bitcoin.RawAddr
 		return errors.Wrap(err, "write contract formation")
 	}
 
+	contractFormations.Inc("updated")
 	return nil
 }
 
+// GetNextMessageID returns the next spynode message id from the last checkpoint.
 func (l *Listener) GetNextMessageID(ctx context.Context) (*uint64, error) {
 	b, err := l.dbConn.Fetch(ctx, chainstateStorageKey)
 	if err != nil {
@@ -375,8 +550,8 @@ func (l *Listener) GetNextMessageID(ctx context.Context) (*uint64, error) {
 		return nil, errors.Wrap(err, "version")
 	}
 
-	if version != 0 {
-		return nil, errors.New("Wrong version")
+	if version > chainstateVersion {
+		return nil, errors.Errorf("Unsupported chainstate version : %d", version)
 	}
 
 	var result uint64
@@ -384,9 +559,20 @@ func (l *Listener) GetNextMessageID(ctx context.Context) (*uint64, error) {
 		return nil, errors.Wrap(err, "next message id")
 	}
 
+	if version >= 1 {
+		var saved int64
+		if err := binary.Read(r, binary.LittleEndian, &saved); err != nil {
+			return nil, errors.Wrap(err, "saved")
+		}
+
+		logger.Info(ctx, "Checkpoint of next message %d saved %s", result,
+			time.Unix(0, saved).Format(time.RFC3339))
+	}
+
 	return &result, nil
 }
 
+// SaveNextMessageID saves a checkpoint of the next spynode message id.
 func (l *Listener) SaveNextMessageID(ctx context.Context, nextMessageID uint64) error {
 	var buf bytes.Buffer
 	if err := binary.Write(&buf, binary.LittleEndian, chainstateVersion); err != nil {
@@ -397,9 +583,50 @@ func (l *Listener) SaveNextMessageID(ctx context.Context, nextMessageID uint64)
 		return errors.Wrap(err, "next message id")
 	}
 
+	if err := binary.Write(&buf, binary.LittleEndian, time.Now().UnixNano()); err != nil {
+		return errors.Wrap(err, "saved")
+	}
+
 	if err := l.dbConn.Put(ctx, chainstateStorageKey, buf.Bytes()); err != nil {
 		return errors.Wrap(err, "put")
 	}
 
 	return nil
 }
+
+// Checkpoint saves the next spynode message id so that few messages are requested again after a
+// crash. Nothing is saved before spynode is told which message to start from or when the id
+// hasn't changed since the last checkpoint. Messages replayed since the last checkpoint are
+// handled again, so handlers must be idempotent.
+func (l *Listener) Checkpoint(ctx context.Context) error {
+	if l.spyNode == nil {
+		return nil
+	}
+
+	l.checkpointLock.Lock()
+	defer l.checkpointLock.Unlock()
+
+	if !l.checkpointEnabled {
+		return nil
+	}
+
+	nextMessageID := l.spyNode.NextMessageID()
+	if nextMessageID == l.checkpointID {
+		return nil
+	}
+
+	if err := l.SaveNextMessageID(ctx, nextMessageID); err != nil {
+		return errors.Wrap(err, "save next message id")
+	}
+
+	l.checkpointID = nextMessageID
+	return nil
+}
+
+// checkpoint saves a checkpoint after messages are processed. Failures are only logged because a
+// later checkpoint will include the messages.
+func (l *Listener) checkpoint(ctx context.Context) {
+	if err := l.Checkpoint(ctx); err != nil {
+		logger.Error(ctx, "Failed to checkpoint : %s", err)
+	}
+}
@@ -138,3 +138,127 @@ func TestNewHeader(t *testing.T) {
 			headers[6].BlockHash())
 	}
 }
+
+func TestReplayHeaders(t *testing.T) {
+	ctx := tests.Context()
+
+	listener := &Listener{
+		offset: 4,
+	}
+
+	headers := make([]*wire.BlockHeader, 5)
+	var prevHash bitcoin.Hash32
+	rand.Read(prevHash[:])
+	for i := range headers {
+		var merkleRoot bitcoin.Hash32
+		rand.Read(merkleRoot[:])
+		headers[i] = &wire.BlockHeader{
+			Version:    1,
+			PrevBlock:  prevHash,
+			MerkleRoot: merkleRoot,
+			Timestamp:  uint32(time.Now().Unix()),
+			Bits:       rand.Uint32(),
+			Nonce:      rand.Uint32(),
+		}
+
+		prevHash = *headers[i].BlockHash()
+	}
+
+	listener.height = 4
+	listener.hashes = []bitcoin.Hash32{
+		*headers[0].BlockHash(),
+		*headers[1].BlockHash(),
+		*headers[2].BlockHash(),
+		*headers[3].BlockHash(),
+	}
+
+	// Headers already held, as when messages since the last checkpoint are replayed.
+	listener.HandleHeaders(ctx, &client.Headers{
+		RequestHeight: -1,
+		StartHeight:   3,
+		Headers: []*wire.BlockHeader{
+			headers[2],
+			headers[3],
+		},
+	})
+
+	if len(listener.hashes) != listener.offset {
+		t.Fatalf("Wrong number of hashes : got %d, want %d", len(listener.hashes), listener.offset)
+	}
+
+	if listener.height != 4 {
+		t.Fatalf("Wrong hash height : got %d, want %d", listener.height, 4)
+	}
+
+	if !listener.hashes[listener.offset-1].Equal(headers[3].BlockHash()) {
+		t.Fatalf("Wrong latest hash : got %s, want %s", listener.hashes[listener.offset-1],
+			headers[3].BlockHash())
+	}
+
+	// Replayed headers followed by a new one.
+	listener.HandleHeaders(ctx, &client.Headers{
+		RequestHeight: -1,
+		StartHeight:   4,
+		Headers: []*wire.BlockHeader{
+			headers[3],
+			headers[4],
+		},
+	})
+
+	if len(listener.hashes) != listener.offset {
+		t.Fatalf("Wrong number of hashes : got %d, want %d", len(listener.hashes), listener.offset)
+	}
+
+	if listener.height != 5 {
+		t.Fatalf("Wrong hash height : got %d, want %d", listener.height, 5)
+	}
+
+	if !listener.hashes[listener.offset-1].Equal(headers[4].BlockHash()) {
+		t.Fatalf("Wrong latest hash : got %s, want %s", listener.hashes[listener.offset-1],
+			headers[4].BlockHash())
+	}
+}
+
+func TestChainstate(t *testing.T) {
+	ctx := tests.Context()
+	test := tests.New()
+
+	listener := NewListener(nil, test.MasterDB, bitcoin.MainNet, true)
+
+	if err := listener.SaveNextMessageID(ctx, 1234); err != nil {
+		t.Fatalf("Failed to save next message id : %s", err)
+	}
+
+	nextMessageID, err := listener.GetNextMessageID(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next message id : %s", err)
+	}
+
+	if *nextMessageID != 1234 {
+		t.Fatalf("Wrong next message id : got %d, want %d", *nextMessageID, 1234)
+	}
+
+	// Version 0 only contains the next message id.
+	if err := test.MasterDB.Put(ctx, chainstateStorageKey,
+		[]byte{0, 45, 0, 0, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatalf("Failed to put version 0 chainstate : %s", err)
+	}
+
+	nextMessageID, err = listener.GetNextMessageID(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get version 0 next message id : %s", err)
+	}
+
+	if *nextMessageID != 45 {
+		t.Fatalf("Wrong version 0 next message id : got %d, want %d", *nextMessageID, 45)
+	}
+
+	if err := test.MasterDB.Put(ctx, chainstateStorageKey,
+		[]byte{chainstateVersion + 1, 45, 0, 0, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatalf("Failed to put future chainstate : %s", err)
+	}
+
+	if _, err := listener.GetNextMessageID(ctx); err == nil {
+		t.Fatalf("Unsupported chainstate version should fail")
+	}
+}
@@ -12,6 +12,38 @@ var (
 	ErrXPubNotFound     = errors.New("Extended Public Key Not Found")
 	ErrUserNotFound     = errors.New("User Not Found")
 	ErrInvalidSignature = errors.New("Invalid Signature")
+
+	ErrPublicKeyNotFound  = errors.New("Public Key Not Found")
+	ErrPublicKeyUnchanged = errors.New("Public Key Unchanged")
+	ErrPublicKeyReused    = errors.New("Public Key Previously Used")
+
+	ErrUserEntityNotFound = errors.New("User Entity Not Found")
+
+	ErrVerificationNotFound    = errors.New("Verification Not Found")
+	ErrVerificationExpired     = errors.New("Verification Expired")
+	ErrVerificationAttempts    = errors.New("Too Many Verification Attempts")
+	ErrInvalidVerificationCode = errors.New("Invalid Verification Code")
+
+	ErrVerificationTokenNotPublished = errors.New("Verification Token Not Published")
+	ErrPaymailKeyMismatch            = errors.New("Paymail Key Mismatch")
+
+	ErrInvalidAttribute = errors.New("Invalid Attribute")
+
+	ErrDocumentNotFound     = errors.New("Document Not Found")
+	ErrInvalidDocumentType  = errors.New("Invalid Document Type")
+	ErrDocumentTooLarge     = errors.New("Document Too Large")
+	ErrDocumentHashMismatch = errors.New("Document Hash Mismatch")
+
+	ErrScreeningHitNotFound = errors.New("Screening Hit Not Found")
+	ErrInvalidReviewStatus  = errors.New("Invalid Review Status")
+
+	ErrDuplicateIdentity      = errors.New("Duplicate Identity")
+	ErrDuplicateNotFound      = errors.New("Duplicate Not Found")
+	ErrInvalidDuplicateAction = errors.New("Invalid Duplicate Action")
+	ErrInvalidMergeUser       = errors.New("Invalid Merge User")
+
+	ErrAPIKeyNotFound = errors.New("API Key Not Found")
+	ErrInvalidScope   = errors.New("Invalid Scope")
 )
 
 type User struct {
@@ -21,6 +53,129 @@ type User struct {
 	DateCreated  time.Time         `db:"date_created" json:"date_created"`
 	DateModified time.Time         `db:"date_modified" json:"date_modified"`
 	IsDeleted    bool              `db:"is_deleted" json:"is_deleted"`
+
+	// VerificationLevel is the KYC tier of the user. It can only be set by the admin API or the
+	// approver.
+	VerificationLevel int `db:"verification_level" json:"verification_level"`
+
+	// DateIdentityExpires is when the user must be verified again. Nil never expires.
+	DateIdentityExpires *time.Time `db:"date_identity_expires" json:"date_identity_expires,omitempty"`
+	DateExpiryNotified  *time.Time `db:"date_expiry_notified" json:"-"`
+}
+
+// PublicKey is an entry in a user's authentication key history.
+type PublicKey struct {
+	UserID       string            `db:"user_id" json:"user_id"`
+	PublicKey    bitcoin.PublicKey `db:"public_key" json:"public_key"`
+	DateCreated  time.Time         `db:"date_created" json:"date_created"`
+	DateRevoked  *time.Time        `db:"date_revoked" json:"date_revoked,omitempty"`
+	RevokeReason string            `db:"revoke_reason" json:"revoke_reason,omitempty"`
+}
+
+// UserEntity is an entry in a user's identity history. It records each identity submitted by the
+// user along with the approval decision made at the time.
+type UserEntity struct {
+	ID          string    `db:"id" json:"id"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	Entity      []byte    `db:"entity" json:"entity"`
+	Signature   []byte    `db:"signature" json:"signature"`
+	Approved    bool      `db:"approved" json:"approved"`
+	Description string    `db:"description" json:"description"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// FieldVerification is a proof of control of an entity field value, like an email address. The
+// value and code are only stored as hashes.
+type FieldVerification struct {
+	ID           string     `db:"id" json:"id"`
+	UserID       string     `db:"user_id" json:"user_id"`
+	Field        string     `db:"field" json:"field"`
+	ValueHash    []byte     `db:"value_hash" json:"-"`
+	CodeHash     []byte     `db:"code_hash" json:"-"`
+	Attempts     int        `db:"attempts" json:"attempts"`
+	DateCreated  time.Time  `db:"date_created" json:"date_created"`
+	DateExpires  time.Time  `db:"date_expires" json:"date_expires"`
+	DateVerified *time.Time `db:"date_verified" json:"date_verified,omitempty"`
+
+	// DateValidUntil is when a completed verification expires. Nil never expires.
+	DateValidUntil *time.Time `db:"date_valid_until" json:"date_valid_until,omitempty"`
+}
+
+// UserAttribute is a flag set on a user, like "accredited_investor". A nil DateExpires never
+// expires.
+type UserAttribute struct {
+	UserID      string     `db:"user_id" json:"user_id"`
+	Attribute   string     `db:"attribute" json:"attribute"`
+	DateExpires *time.Time `db:"date_expires" json:"date_expires,omitempty"`
+	SetBy       string     `db:"set_by" json:"set_by"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+}
+
+// InstrumentRequirement is the verification a user needs to receive an instrument.
+type InstrumentRequirement struct {
+	Contract             string        `db:"contract" json:"contract"`
+	InstrumentID         string        `db:"instrument_id" json:"instrument_id"`
+	MinVerificationLevel int           `db:"min_verification_level" json:"min_verification_level"`
+	RequiredAttributes   AttributeList `db:"required_attributes" json:"required_attributes"`
+	DateModified         time.Time     `db:"date_modified" json:"date_modified"`
+}
+
+// Document is an identity document uploaded by a user for review. The content is kept in storage
+// under StorageKey and ContentHash is the SHA256 of the content as uploaded.
+type Document struct {
+	ID           string    `db:"id" json:"id"`
+	UserID       string    `db:"user_id" json:"user_id"`
+	DocumentType string    `db:"document_type" json:"document_type"`
+	FileName     string    `db:"file_name" json:"file_name"`
+	ContentType  string    `db:"content_type" json:"content_type"`
+	Size         int64     `db:"size" json:"size"`
+	ContentHash  []byte    `db:"content_hash" json:"content_hash"`
+	StorageKey   string    `db:"storage_key" json:"-"`
+	Signature    []byte    `db:"signature" json:"signature"`
+	DateCreated  time.Time `db:"date_created" json:"date_created"`
+}
+
+// ScreeningHit is a name in a user's identity that is similar to a name on a sanctions list. Hits
+// start pending and are confirmed or dismissed by the operator.
+type ScreeningHit struct {
+	ID           string     `db:"id" json:"id"`
+	UserID       string     `db:"user_id" json:"user_id"`
+	Field        string     `db:"field" json:"field"`
+	ScreenedName string     `db:"screened_name" json:"screened_name"`
+	ListName     string     `db:"list_name" json:"list_name"`
+	EntryID      string     `db:"entry_id" json:"entry_id"`
+	EntryName    string     `db:"entry_name" json:"entry_name"`
+	MatchedName  string     `db:"matched_name" json:"matched_name"`
+	Score        float64    `db:"score" json:"score"`
+	Status       string     `db:"status" json:"status"`
+	Note         string     `db:"note" json:"note"`
+	DateCreated  time.Time  `db:"date_created" json:"date_created"`
+	DateReviewed *time.Time `db:"date_reviewed" json:"date_reviewed,omitempty"`
+}
+
+// DuplicateIdentity is an existing user found to share identifying information with a user.
+// Kind is the information they share, for example "EmailAddress".
+type DuplicateIdentity struct {
+	ID              string     `db:"id" json:"id"`
+	UserID          string     `db:"user_id" json:"user_id"`
+	DuplicateUserID string     `db:"duplicate_user_id" json:"duplicate_user_id"`
+	Kind            string     `db:"kind" json:"kind"`
+	Status          string     `db:"status" json:"status"`
+	Note            string     `db:"note" json:"note"`
+	DateCreated     time.Time  `db:"date_created" json:"date_created"`
+	DateReviewed    *time.Time `db:"date_reviewed" json:"date_reviewed,omitempty"`
+}
+
+// APIKey identifies an integrator, like a wallet or contract agent, that calls the API. Only a
+// hash of the key is stored. Prefix is the start of the key so it can be recognized.
+type APIKey struct {
+	ID           string     `db:"id" json:"id"`
+	Name         string     `db:"name" json:"name"`
+	Prefix       string     `db:"prefix" json:"prefix"`
+	Scopes       ScopeList  `db:"scopes" json:"scopes"`
+	DateCreated  time.Time  `db:"date_created" json:"date_created"`
+	DateLastUsed *time.Time `db:"date_last_used" json:"date_last_used,omitempty"`
+	DateRevoked  *time.Time `db:"date_revoked" json:"date_revoked,omitempty"`
 }
 
 type XPub struct {
@@ -38,4 +193,5 @@ type SignatureHash struct {
 	BlockHeight uint32
 	Approved    bool
 	Description string
+	Mismatches  EntityMismatches
 }
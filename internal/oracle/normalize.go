@@ -0,0 +1,251 @@
+package oracle
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Strictness specifies how closely an entity field must match to be considered the same.
+type Strictness int
+
+const (
+	// StrictnessExact compares values byte for byte.
+	StrictnessExact Strictness = iota
+
+	// StrictnessCanonical compares values after converting them to a canonical format. Unicode NFC,
+	// trimmed and collapsed whitespace, lower case email addresses and domain names, E.164 phone
+	// numbers and ISO 3166-1 alpha-3 country codes.
+	StrictnessCanonical
+
+	// StrictnessLoose compares canonical values ignoring case and punctuation.
+	StrictnessLoose
+)
+
+var (
+	ErrUnknownStrictness = errors.New("Unknown Strictness")
+	ErrUnknownField      = errors.New("Unknown Entity Field")
+)
+
+// defaultStrictness is the strictness used for each entity field unless it is overridden.
+var defaultStrictness = map[string]Strictness{
+	"Name":                       StrictnessLoose,
+	"Type":                       StrictnessExact,
+	"LEI":                        StrictnessCanonical,
+	"UnitNumber":                 StrictnessLoose,
+	"BuildingNumber":             StrictnessLoose,
+	"Street":                     StrictnessLoose,
+	"SuburbCity":                 StrictnessLoose,
+	"TerritoryStateProvinceCode": StrictnessLoose,
+	"CountryCode":                StrictnessCanonical,
+	"PostalZIPCode":              StrictnessLoose,
+	"EmailAddress":               StrictnessCanonical,
+	"PhoneNumber":                StrictnessCanonical,
+	"AdministratorName":          StrictnessLoose,
+	"ManagerName":                StrictnessLoose,
+	"DomainName":                 StrictnessCanonical,
+	"PaymailHandle":              StrictnessCanonical,
+}
+
+// ParseStrictness converts the text form of a strictness ("exact", "canonical", "loose").
+func ParseStrictness(s string) (Strictness, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "exact":
+		return StrictnessExact, nil
+	case "canonical":
+		return StrictnessCanonical, nil
+	case "loose":
+		return StrictnessLoose, nil
+	}
+
+	return StrictnessExact, errors.Wrap(ErrUnknownStrictness, s)
+}
+
+// Normalizer converts entity field values so that trivially different forms of the same value
+// compare as equal.
+type Normalizer struct {
+	strictness map[string]Strictness
+}
+
+// NewNormalizer creates a normalizer. overrides maps entity field names to text strictness values
+// and replaces the default strictness for those fields.
+func NewNormalizer(overrides map[string]string) (*Normalizer, error) {
+	result := &Normalizer{
+		strictness: make(map[string]Strictness),
+	}
+
+	for field, strictness := range defaultStrictness {
+		result.strictness[field] = strictness
+	}
+
+	for field, s := range overrides {
+		if _, exists := defaultStrictness[field]; !exists {
+			return nil, errors.Wrap(ErrUnknownField, field)
+		}
+
+		strictness, err := ParseStrictness(s)
+		if err != nil {
+			return nil, errors.Wrap(err, field)
+		}
+
+		result.strictness[field] = strictness
+	}
+
+	return result, nil
+}
+
+// Strictness returns the strictness used for a field. A nil normalizer is always exact.
+func (n *Normalizer) Strictness(field string) Strictness {
+	if n == nil {
+		return StrictnessExact
+	}
+
+	return n.strictness[field]
+}
+
+// Normalize returns the form of the value used to compare the field. countryCode is used to
+// convert national phone numbers to E.164.
+func (n *Normalizer) Normalize(field, value, countryCode string) string {
+	return n.normalize(field, value, countryCode, n.Strictness(field))
+}
+
+func (n *Normalizer) normalize(field, value, countryCode string, strictness Strictness) string {
+	if strictness == StrictnessExact {
+		return value
+	}
+
+	result := canonicalText(value)
+
+	switch field {
+	case "EmailAddress", "DomainName", "PaymailHandle":
+		result = strings.TrimSuffix(strings.ToLower(result), ".")
+	case "LEI", "CountryCode", "TerritoryStateProvinceCode", "PostalZIPCode":
+		result = strings.ToUpper(result)
+	}
+
+	switch field {
+	case "PhoneNumber":
+		result = canonicalPhoneNumber(result, countryCode)
+	case "CountryCode":
+		if c, ok := lookupCountry(result); ok {
+			result = c.Alpha3
+		}
+	}
+
+	if strictness == StrictnessLoose {
+		result = looseText(result)
+	}
+
+	return result
+}
+
+// Equal returns true if the two values match at the field's strictness.
+func (n *Normalizer) Equal(field, a, aCountryCode, b, bCountryCode string) bool {
+	return n.Normalize(field, a, aCountryCode) == n.Normalize(field, b, bCountryCode)
+}
+
+// CanonicalEntity returns a copy of the entity with each field converted to its canonical format.
+// Fields that are compared exactly are not modified.
+func (n *Normalizer) CanonicalEntity(entity *actions.EntityField) *actions.EntityField {
+	result := proto.Clone(entity).(*actions.EntityField)
+	if n == nil {
+		return result
+	}
+
+	canonical := func(field, value string) string {
+		strictness := n.Strictness(field)
+		if strictness > StrictnessCanonical {
+			strictness = StrictnessCanonical
+		}
+		return n.normalize(field, value, entity.CountryCode, strictness)
+	}
+
+	result.Name = canonical("Name", result.Name)
+	result.LEI = canonical("LEI", result.LEI)
+	result.UnitNumber = canonical("UnitNumber", result.UnitNumber)
+	result.BuildingNumber = canonical("BuildingNumber", result.BuildingNumber)
+	result.Street = canonical("Street", result.Street)
+	result.SuburbCity = canonical("SuburbCity", result.SuburbCity)
+	result.TerritoryStateProvinceCode = canonical("TerritoryStateProvinceCode",
+		result.TerritoryStateProvinceCode)
+	result.CountryCode = canonical("CountryCode", result.CountryCode)
+	result.PostalZIPCode = canonical("PostalZIPCode", result.PostalZIPCode)
+	result.EmailAddress = canonical("EmailAddress", result.EmailAddress)
+	result.PhoneNumber = canonical("PhoneNumber", result.PhoneNumber)
+	result.DomainName = canonical("DomainName", result.DomainName)
+	result.PaymailHandle = canonical("PaymailHandle", result.PaymailHandle)
+
+	for _, admin := range result.Administration {
+		admin.Name = canonical("AdministratorName", admin.Name)
+	}
+	for _, manager := range result.Management {
+		manager.Name = canonical("ManagerName", manager.Name)
+	}
+
+	return result
+}
+
+// canonicalText converts text to Unicode NFC and collapses all whitespace to single spaces.
+func canonicalText(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// looseText case folds text and removes punctuation.
+func looseText(s string) string {
+	s = cases.Fold().String(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// canonicalPhoneNumber converts a phone number to E.164 format. National numbers are converted
+// using the calling code of the specified country. Numbers that can't be converted are returned
+// as digits only.
+func canonicalPhoneNumber(s, countryCode string) string {
+	s = strings.TrimSpace(s)
+
+	international := false
+	if strings.HasPrefix(s, "+") {
+		international = true
+	} else if strings.HasPrefix(s, "00") {
+		international = true
+		s = s[2:]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+
+	if len(digits) == 0 {
+		return ""
+	}
+
+	if international {
+		return "+" + digits
+	}
+
+	c, ok := lookupCountry(countryCode)
+	if !ok {
+		return digits
+	}
+
+	// Drop the national trunk prefix.
+	return "+" + c.CallingCode + strings.TrimPrefix(digits, "0")
+}
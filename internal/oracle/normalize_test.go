@@ -0,0 +1,173 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/tokenized/specification/dist/golang/actions"
+)
+
+func TestNormalizerEqual(t *testing.T) {
+	normalizer, err := NewNormalizer(nil)
+	if err != nil {
+		t.Fatalf("Failed to create normalizer : %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		field    string
+		a, b     string
+		country  string
+		expected bool
+	}{
+		{
+			name:     "Name punctuation",
+			field:    "Name",
+			a:        "ACME Pty Ltd",
+			b:        "Acme  Pty. Ltd.",
+			expected: true,
+		},
+		{
+			name:     "Name different",
+			field:    "Name",
+			a:        "ACME Pty Ltd",
+			b:        "ACME Holdings Pty Ltd",
+			expected: false,
+		},
+		{
+			name:     "Phone national",
+			field:    "PhoneNumber",
+			a:        "+61 2 9999 0000",
+			b:        "(02) 9999-0000",
+			country:  "AUS",
+			expected: true,
+		},
+		{
+			name:     "Phone international prefix",
+			field:    "PhoneNumber",
+			a:        "+61 2 9999 0000",
+			b:        "0061299990000",
+			country:  "AUS",
+			expected: true,
+		},
+		{
+			name:     "Phone different",
+			field:    "PhoneNumber",
+			a:        "+61 2 9999 0000",
+			b:        "+61 2 9999 0001",
+			country:  "AUS",
+			expected: false,
+		},
+		{
+			name:     "Email case",
+			field:    "EmailAddress",
+			a:        "Info@Tokenized.com",
+			b:        "info@tokenized.com ",
+			expected: true,
+		},
+		{
+			name:     "Country alpha-2",
+			field:    "CountryCode",
+			a:        "AU",
+			b:        "AUS",
+			expected: true,
+		},
+		{
+			name:     "Country different",
+			field:    "CountryCode",
+			a:        "AU",
+			b:        "AUT",
+			expected: false,
+		},
+		{
+			name:     "Type exact",
+			field:    "Type",
+			a:        "P",
+			b:        "p",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := normalizer.Equal(tt.field, tt.a, tt.country, tt.b, tt.country)
+			if result != tt.expected {
+				t.Errorf("Wrong result : got %t, want %t", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizerOverrides(t *testing.T) {
+	normalizer, err := NewNormalizer(map[string]string{"Name": "exact"})
+	if err != nil {
+		t.Fatalf("Failed to create normalizer : %s", err)
+	}
+
+	if normalizer.Equal("Name", "ACME Pty Ltd", "", "Acme Pty. Ltd.", "") {
+		t.Errorf("Exact name should not match")
+	}
+
+	if _, err := NewNormalizer(map[string]string{"Nickname": "loose"}); err == nil {
+		t.Errorf("Unknown field should fail")
+	}
+
+	if _, err := NewNormalizer(map[string]string{"Name": "fuzzy"}); err == nil {
+		t.Errorf("Unknown strictness should fail")
+	}
+}
+
+func TestCompareEntityNormalized(t *testing.T) {
+	normalizer, err := NewNormalizer(nil)
+	if err != nil {
+		t.Fatalf("Failed to create normalizer : %s", err)
+	}
+
+	sub := &actions.EntityField{
+		Name:         "Acme Pty. Ltd.",
+		CountryCode:  "AU",
+		PhoneNumber:  "02 9999 0000",
+		EmailAddress: "INFO@ACME.COM",
+		Administration: []*actions.AdministratorField{
+			&actions.AdministratorField{
+				Type: 5,
+				Name: "john  smith",
+			},
+		},
+	}
+
+	full := &actions.EntityField{
+		Name:         "ACME Pty Ltd",
+		CountryCode:  "AUS",
+		PhoneNumber:  "+61299990000",
+		EmailAddress: "info@acme.com",
+		Administration: []*actions.AdministratorField{
+			&actions.AdministratorField{
+				Type: 5,
+				Name: "John Smith",
+			},
+		},
+	}
+
+	if mismatches := CompareEntity(sub, full, normalizer); len(mismatches) != 0 {
+		t.Fatalf("Normalized entities should match : %s", mismatches.Error())
+	}
+
+	if mismatches := CompareEntity(sub, full, nil); len(mismatches) == 0 {
+		t.Fatalf("Exact entities should not match")
+	}
+
+	canonical := normalizer.CanonicalEntity(sub)
+	if canonical.CountryCode != "AUS" {
+		t.Errorf("Wrong canonical country code : got %s, want %s", canonical.CountryCode, "AUS")
+	}
+	if canonical.PhoneNumber != "+61299990000" {
+		t.Errorf("Wrong canonical phone number : got %s, want %s", canonical.PhoneNumber,
+			"+61299990000")
+	}
+	if canonical.EmailAddress != "info@acme.com" {
+		t.Errorf("Wrong canonical email : got %s, want %s", canonical.EmailAddress, "info@acme.com")
+	}
+	if sub.CountryCode != "AU" {
+		t.Errorf("Original entity modified")
+	}
+}
@@ -1,6 +1,7 @@
 package oracle
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -10,11 +11,12 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 func TestUsers(t *testing.T) {
 	ctx := tests.Context()
-	test := tests.New()
+	store := NewMemoryStore()
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
@@ -40,11 +42,11 @@ func TestUsers(t *testing.T) {
 		IsDeleted:    false,
 	}
 
-	if err := CreateUser(ctx, test.MasterDB, user); err != nil {
+	if err := CreateUser(ctx, store, user); err != nil {
 		t.Fatalf("Failed to create user : %s", err)
 	}
 
-	fuser, err := FetchUser(ctx, test.MasterDB, user.ID)
+	fuser, err := FetchUser(ctx, store, user.ID)
 	if err != nil {
 		t.Fatalf("Failed to fetch user : %s", err)
 	}
@@ -56,7 +58,7 @@ func TestUsers(t *testing.T) {
 
 func TestXPub(t *testing.T) {
 	ctx := tests.Context()
-	test := tests.New()
+	store := NewMemoryStore()
 
 	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
 	if err != nil {
@@ -82,7 +84,7 @@ func TestXPub(t *testing.T) {
 		IsDeleted:    false,
 	}
 
-	if err := CreateUser(ctx, test.MasterDB, user); err != nil {
+	if err := CreateUser(ctx, store, user); err != nil {
 		t.Fatalf("Failed to create user : %s", err)
 	}
 
@@ -100,11 +102,11 @@ func TestXPub(t *testing.T) {
 		DateCreated:     time.Now(),
 	}
 
-	if err := CreateXPub(ctx, test.MasterDB, xpub); err != nil {
+	if err := CreateXPub(ctx, store, xpub); err != nil {
 		t.Fatalf("Failed to create Xpub : %s", err)
 	}
 
-	fxpub, err := FetchXPubByXPub(ctx, test.MasterDB, xpubs)
+	fxpub, err := FetchXPubByXPub(ctx, store, xpubs)
 	if err != nil {
 		t.Fatalf("Failed to fetch xpub : %s", err)
 	}
@@ -113,7 +115,7 @@ func TestXPub(t *testing.T) {
 		t.Fatalf("Invalid fetched xpubs")
 	}
 
-	userid, err := FetchUserIDByXPub(ctx, test.MasterDB, xpubs)
+	userid, err := FetchUserIDByXPub(ctx, store, xpubs)
 	if err != nil {
 		t.Fatalf("Failed to fetch xpub user id : %s", err)
 	}
@@ -122,7 +124,7 @@ func TestXPub(t *testing.T) {
 		t.Fatalf("Invalid xpub user id")
 	}
 
-	fuser, err := FetchUserByXPub(ctx, test.MasterDB, xpubs)
+	fuser, err := FetchUserByXPub(ctx, store, xpubs)
 	if err != nil {
 		t.Fatalf("Failed to fetch user by xpubs : %s", err)
 	}
@@ -131,3 +133,84 @@ func TestXPub(t *testing.T) {
 		t.Fatalf("Invalid user id")
 	}
 }
+
+func TestRotatePublicKey(t *testing.T) {
+	ctx := tests.Context()
+	store := NewMemoryStore()
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entity := actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+	}
+
+	entityBytes, err := proto.Marshal(&entity)
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	user := &User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+		IsDeleted:    false,
+	}
+
+	if err := CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	beforeRotate := time.Now()
+
+	newKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate new user key : %s", err)
+	}
+
+	if err := RotatePublicKey(ctx, store, user, newKey.PublicKey(), "test"); err != nil {
+		t.Fatalf("Failed to rotate public key : %s", err)
+	}
+
+	fuser, err := FetchUser(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user : %s", err)
+	}
+
+	if !bytes.Equal(fuser.PublicKey.Bytes(), newKey.PublicKey().Bytes()) {
+		t.Fatalf("User public key not rotated")
+	}
+
+	keys, err := FetchPublicKeys(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch public keys : %s", err)
+	}
+
+	if len(keys) != 2 {
+		t.Fatalf("Wrong public key count : got %d, want %d", len(keys), 2)
+	}
+
+	if keys[0].DateRevoked == nil {
+		t.Fatalf("Old public key not revoked")
+	}
+
+	old, err := FetchPublicKeyAt(ctx, store, user.ID, beforeRotate)
+	if err != nil {
+		t.Fatalf("Failed to fetch old public key : %s", err)
+	}
+
+	if !bytes.Equal(old.PublicKey.Bytes(), key.PublicKey().Bytes()) {
+		t.Fatalf("Wrong public key before rotation")
+	}
+
+	// Revoked keys can't be used again.
+	err = RotatePublicKey(ctx, store, user, key.PublicKey(), "test")
+	if errors.Cause(err) != ErrPublicKeyReused {
+		t.Fatalf("Rotating to a previous key should fail : %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/paymail"
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// VerifyPaymail resolves the paymail handle's identity public key and records the handle as
+// verified if the key is the user's public key or the public key of one of the user's xpubs. The
+// verification expires according to validity.
+func VerifyPaymail(ctx context.Context, store Store, resolver paymail.Resolver,
+	validity *Validity, user *User, handle string) (*FieldVerification, error) {
+
+	publicKey, err := resolver.PublicKey(ctx, handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve paymail")
+	}
+
+	userKeys := []bitcoin.PublicKey{user.PublicKey}
+
+	xpubs, err := FetchXPubsByUser(ctx, store, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch xpubs")
+	}
+
+	for _, xpub := range xpubs {
+		for _, key := range xpub.XPub {
+			userKeys = append(userKeys, key.PublicKey())
+		}
+	}
+
+	found := false
+	for _, key := range userKeys {
+		if bytes.Equal(key.Bytes(), publicKey.Bytes()) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, errors.Wrap(ErrPaymailKeyMismatch, handle)
+	}
+
+	return createVerifiedField(ctx, store, validity, user.ID, "PaymailHandle",
+		paymailValue(handle))
+}
+
+// FetchUserIDByPaymail returns the id of the user that has verified the paymail handle, whose
+// verification hasn't expired, and that still has it in their identity.
+func FetchUserIDByPaymail(ctx context.Context, store Store, handle string) (string, error) {
+	verifications, err := store.FetchFieldVerificationsByValue(ctx, "", "PaymailHandle",
+		verificationValueHash(store, "PaymailHandle", paymailValue(handle)))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var valid []*FieldVerification
+	for _, verification := range verifications {
+		if verification.isValid(now) {
+			valid = append(valid, verification)
+		}
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].DateVerified.After(*valid[j].DateVerified)
+	})
+
+	// A handle can move between users so only a user that still claims it is returned.
+	for _, verification := range valid {
+		entity, err := fetchUserEntity(ctx, store, verification.UserID)
+		if err != nil {
+			if errors.Cause(err) == ErrUserNotFound {
+				continue
+			}
+			return "", errors.Wrap(err, "fetch user entity")
+		}
+
+		if paymailValue(entity.PaymailHandle) == paymailValue(handle) {
+			return verification.UserID, nil
+		}
+	}
+
+	return "", errors.Wrap(ErrUserNotFound, handle)
+}
+
+// createVerifiedField records a field value that was verified without a code being sent to the
+// user.
+func createVerifiedField(ctx context.Context, store Store, validity *Validity, userID, field,
+	value string) (*FieldVerification, error) {
+
+	// The code is never used, but a random one is stored so the row can't be confirmed by a code.
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, errors.Wrap(err, "generate code")
+	}
+
+	now := time.Now()
+	result := &FieldVerification{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Field:        field,
+		ValueHash:    verificationValueHash(store, field, value),
+		DateCreated:  now,
+		DateExpires:  now,
+		DateVerified: &now,
+	}
+	result.DateValidUntil = validity.FieldExpiry(field, now)
+	result.CodeHash = verificationCodeHash(result.ID, hex.EncodeToString(random))
+
+	if err := store.InsertFieldVerification(ctx, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// paymailValue returns the form of a paymail handle that is verified. Handles are case
+// insensitive.
+func paymailValue(handle string) string {
+	return strings.ToLower(strings.TrimSpace(handle))
+}
@@ -0,0 +1,54 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// FetchPublicKeys returns all public keys that have been used by a user, oldest first.
+func FetchPublicKeys(ctx context.Context, store Store, userID string) ([]*PublicKey, error) {
+	return store.FetchPublicKeys(ctx, userID)
+}
+
+// FetchPublicKeyAt returns the public key that was active for a user at the specified time.
+func FetchPublicKeyAt(ctx context.Context, store Store, userID string,
+	at time.Time) (*PublicKey, error) {
+
+	return store.FetchPublicKeyAt(ctx, userID, at)
+}
+
+// RotatePublicKey replaces the user's authentication key with a new key. The old key is kept in
+// the key history, marked as revoked, so signatures previously made with it stay attributable.
+// Keys in the history can't be used again since revoked keys may be compromised.
+func RotatePublicKey(ctx context.Context, store Store, user *User,
+	newKey bitcoin.PublicKey, reason string) error {
+
+	if bytes.Equal(newKey.Bytes(), user.PublicKey.Bytes()) {
+		return errors.Wrap(ErrPublicKeyUnchanged, user.ID)
+	}
+
+	history, err := store.FetchPublicKeys(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "fetch public keys")
+	}
+
+	for _, publicKey := range history {
+		if bytes.Equal(newKey.Bytes(), publicKey.PublicKey.Bytes()) {
+			return errors.Wrap(ErrPublicKeyReused, user.ID)
+		}
+	}
+
+	now := time.Now()
+	if err := store.RotatePublicKey(ctx, user, newKey, reason, now); err != nil {
+		return err
+	}
+
+	user.PublicKey = newKey
+	user.DateModified = now
+	return nil
+}
@@ -0,0 +1,147 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/pkg/errors"
+)
+
+// Requirements are the conditions, beyond matching the registered identity, that must be met
+// before the oracle approves an entity.
+type Requirements struct {
+	// VerifiedFields are the entity fields that must be verified by the user when they are
+	// included in an entity being approved. For example "EmailAddress".
+	VerifiedFields []string
+
+	// TransferVerifiedFields are the entity fields that must be verified by a user before
+	// transfers to them are approved.
+	TransferVerifiedFields []string
+}
+
+// NewRequirements creates requirements, checking that the verified fields can be verified.
+func NewRequirements(verifiedFields, transferVerifiedFields []string) (*Requirements, error) {
+	for _, fields := range [][]string{verifiedFields, transferVerifiedFields} {
+		for _, field := range fields {
+			if !isVerifiableField(field) {
+				return nil, errors.Wrap(ErrUnknownField, field)
+			}
+		}
+	}
+
+	return &Requirements{
+		VerifiedFields:         verifiedFields,
+		TransferVerifiedFields: transferVerifiedFields,
+	}, nil
+}
+
+// Requires returns true if the field must be verified for identity verifications or transfers.
+func (r *Requirements) Requires(field string) bool {
+	if r == nil {
+		return false
+	}
+
+	for _, fields := range [][]string{r.VerifiedFields, r.TransferVerifiedFields} {
+		for _, required := range fields {
+			if required == field {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Check returns a mismatch for each requirement the entity doesn't meet. userEntity is the
+// identity registered to the user. A nil Requirements has no requirements.
+func (r *Requirements) Check(ctx context.Context, store Store, userID string,
+	entity, userEntity *actions.EntityField) (EntityMismatches, error) {
+
+	if r == nil {
+		return nil, nil
+	}
+
+	var result EntityMismatches
+	for _, field := range r.VerifiedFields {
+		if len(VerifiableFieldValue(entity, field)) == 0 {
+			continue // not included so it doesn't need to be verified
+		}
+
+		verified, err := IsFieldVerified(ctx, store, userID, field,
+			VerifiableFieldValue(userEntity, field))
+		if err != nil {
+			return nil, errors.Wrapf(err, "check verified %s", field)
+		}
+
+		if !verified {
+			result = append(result, EntityMismatch{
+				Field:       field,
+				Code:        MismatchNotVerified,
+				Description: fmt.Sprintf("%s not verified", field),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// verifiableFields are the entity fields that a user can prove they control.
+var verifiableFields = []string{"EmailAddress", "PhoneNumber", "DomainName", "PaymailHandle"}
+
+// CheckTransfer returns a description of the first requirement the user doesn't meet to receive
+// transfers, or an empty string if all requirements are met. userEntity is the identity registered
+// to the user.
+func (r *Requirements) CheckTransfer(ctx context.Context, store Store, userID string,
+	userEntity *actions.EntityField) (string, error) {
+
+	if r == nil {
+		return "", nil
+	}
+
+	for _, field := range r.TransferVerifiedFields {
+		value := VerifiableFieldValue(userEntity, field)
+		if len(value) == 0 {
+			return fmt.Sprintf("%s not registered", field), nil
+		}
+
+		verified, err := IsFieldVerified(ctx, store, userID, field, value)
+		if err != nil {
+			return "", errors.Wrapf(err, "check verified %s", field)
+		}
+
+		if !verified {
+			return fmt.Sprintf("%s not verified", field), nil
+		}
+	}
+
+	return "", nil
+}
+
+func isVerifiableField(field string) bool {
+	for _, verifiable := range verifiableFields {
+		if field == verifiable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifiableFieldValue returns the value of an entity field that can be verified, or an empty
+// string if the field can't be verified.
+func VerifiableFieldValue(entity *actions.EntityField, field string) string {
+	switch field {
+	case "EmailAddress":
+		return entity.EmailAddress
+	case "PhoneNumber":
+		return entity.PhoneNumber
+	case "DomainName":
+		return entity.DomainName
+	case "PaymailHandle":
+		return paymailValue(entity.PaymailHandle)
+	}
+
+	return ""
+}
@@ -0,0 +1,335 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/sanctions"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Screening hit statuses. Pending hits are waiting for the operator to review them.
+	ScreeningPending   = "pending"
+	ScreeningConfirmed = "confirmed"
+	ScreeningDismissed = "dismissed"
+)
+
+// screenedName is a name in an entity that is screened against sanctions lists.
+type screenedName struct {
+	field string
+	name  string
+}
+
+// screenedNames returns the names of the entity, its administrators, and its managers.
+func screenedNames(entity *actions.EntityField) []screenedName {
+	var result []screenedName
+	if len(entity.Name) != 0 {
+		result = append(result, screenedName{field: "Name", name: entity.Name})
+	}
+
+	for i, admin := range entity.Administration {
+		if len(admin.Name) != 0 {
+			result = append(result, screenedName{
+				field: fmt.Sprintf("Administration[%d]", i),
+				name:  admin.Name,
+			})
+		}
+	}
+
+	for i, manager := range entity.Management {
+		if len(manager.Name) != 0 {
+			result = append(result, screenedName{
+				field: fmt.Sprintf("Management[%d]", i),
+				name:  manager.Name,
+			})
+		}
+	}
+
+	return result
+}
+
+// ScreenEntity screens the names in a user's entity against the sanctions lists and records a
+// pending hit for each match that hasn't already been recorded. A hit that has been reviewed
+// keeps its status. Returns the recorded hit of each match found, with its current status.
+func ScreenEntity(ctx context.Context, store Store, screener *sanctions.Screener, userID string,
+	entity *actions.EntityField) ([]*ScreeningHit, error) {
+
+	hits, _, err := screenEntity(ctx, store, screener, userID, entity)
+	return hits, err
+}
+
+// screenEntity screens an entity like ScreenEntity and also returns the number of matches that
+// were new.
+func screenEntity(ctx context.Context, store Store, screener *sanctions.Screener, userID string,
+	entity *actions.EntityField) ([]*ScreeningHit, int, error) {
+
+	var result []*ScreeningHit
+	inserted := 0
+	now := time.Now()
+	for _, screened := range screenedNames(entity) {
+		for _, match := range screener.Screen(screened.name) {
+			hit, isNew, err := store.InsertScreeningHit(ctx, &ScreeningHit{
+				ID:           uuid.New().String(),
+				UserID:       userID,
+				Field:        screened.field,
+				ScreenedName: screened.name,
+				ListName:     match.Entry.List,
+				EntryID:      match.Entry.ID,
+				EntryName:    match.Entry.Name,
+				MatchedName:  match.MatchedName,
+				Score:        match.Score,
+				Status:       ScreeningPending,
+				DateCreated:  now,
+			})
+			if err != nil {
+				return nil, inserted, errors.Wrap(err, "insert hit")
+			}
+
+			// Known matches were already reported when they were first found.
+			if isNew {
+				logger.WarnWithFields(ctx, []logger.Field{
+					logger.String("user_id", userID),
+					logger.String("field", screened.field),
+					logger.String("list", match.Entry.List),
+					logger.String("entry_id", match.Entry.ID),
+				}, "Sanctions screening match")
+				inserted++
+			}
+
+			result = append(result, hit)
+		}
+	}
+
+	return result, inserted, nil
+}
+
+// ScreenAllUsers screens every user's current identity against the sanctions lists. It is run
+// periodically so users are screened against list updates. Returns the number of new matches.
+func ScreenAllUsers(ctx context.Context, store Store,
+	screener *sanctions.Screener) (int, error) {
+
+	userIDs, err := store.FetchUserIDs(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "fetch users")
+	}
+
+	count := 0
+	for _, userID := range userIDs {
+		entity, err := fetchUserEntity(ctx, store, userID)
+		if err != nil {
+			return count, errors.Wrapf(err, "fetch user entity %s", userID)
+		}
+
+		_, inserted, err := screenEntity(ctx, store, screener, userID, entity)
+		if err != nil {
+			return count, errors.Wrapf(err, "screen user %s", userID)
+		}
+
+		count += inserted
+	}
+
+	return count, nil
+}
+
+// FetchScreeningHits returns screening hits, oldest first. Empty userID or status return hits
+// for all users or statuses.
+func FetchScreeningHits(ctx context.Context, store Store, userID,
+	status string) ([]*ScreeningHit, error) {
+
+	return store.FetchScreeningHits(ctx, userID, status)
+}
+
+// ReviewScreeningHit records the operator's decision on a screening hit. status must be
+// "confirmed" or "dismissed".
+func ReviewScreeningHit(ctx context.Context, store Store, id, status,
+	note string) (*ScreeningHit, error) {
+
+	if status != ScreeningConfirmed && status != ScreeningDismissed {
+		return nil, errors.Wrap(ErrInvalidReviewStatus, status)
+	}
+
+	result, err := store.FetchScreeningHit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result.Status = status
+	result.Note = note
+	result.DateReviewed = &now
+
+	if err := store.UpdateScreeningHit(ctx, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// screeningDenial returns a description of why a user with the hits is denied, or an empty string
+// if they aren't. Pending hits only deny when includePending is true.
+func screeningDenial(hits []*ScreeningHit, includePending bool) string {
+	pending := false
+	for _, hit := range hits {
+		switch hit.Status {
+		case ScreeningConfirmed:
+			return "sanctions screening match confirmed"
+		case ScreeningPending:
+			pending = true
+		}
+	}
+
+	if pending && includePending {
+		return "pending sanctions screening review"
+	}
+
+	return ""
+}
+
+// ScreeningApprover screens identities against sanctions lists before passing approvals to the
+// next approver. Matches are recorded as pending hits for the operator to review. Identity
+// approvals are denied while a user has pending or confirmed hits and transfers to users with
+// confirmed hits are denied.
+type ScreeningApprover struct {
+	Store    Store
+	Screener *sanctions.Screener
+	Next     ApproverInterface
+}
+
+// screeningVerificationApprover is a ScreeningApprover whose next approver sets verification
+// levels.
+type screeningVerificationApprover struct {
+	*ScreeningApprover
+	VerificationApproverInterface
+}
+
+// NewScreeningApprover creates a screening approver. next can be nil. The result implements
+// VerificationApproverInterface when next does.
+func NewScreeningApprover(store Store, screener *sanctions.Screener,
+	next ApproverInterface) ApproverInterface {
+
+	result := &ScreeningApprover{
+		Store:    store,
+		Screener: screener,
+		Next:     next,
+	}
+
+	if verificationApprover, ok := next.(VerificationApproverInterface); ok {
+		return &screeningVerificationApprover{
+			ScreeningApprover:             result,
+			VerificationApproverInterface: verificationApprover,
+		}
+	}
+
+	return result
+}
+
+func (a *ScreeningApprover) ApproveRegistration(ctx context.Context, userID string,
+	entity actions.EntityField, publicKey bitcoin.PublicKey) (bool, string, error) {
+
+	if a.Next != nil {
+		approved, description, err := a.Next.ApproveRegistration(ctx, userID, entity, publicKey)
+		if err != nil || !approved {
+			return approved, description, err
+		}
+	}
+
+	return a.screen(ctx, userID, &entity)
+}
+
+func (a *ScreeningApprover) UpdateIdentity(ctx context.Context, userID string,
+	entity actions.EntityField) (bool, string, error) {
+
+	if a.Next != nil {
+		approved, description, err := a.Next.UpdateIdentity(ctx, userID, entity)
+		if err != nil || !approved {
+			return approved, description, err
+		}
+	}
+
+	return a.screen(ctx, userID, &entity)
+}
+
+// IdentityReverified returns whether the next approver verified the identity again. Screening
+// doesn't verify identities so it is false when the next approver doesn't report re-verification.
+func (a *ScreeningApprover) IdentityReverified(ctx context.Context, userID string,
+	entity actions.EntityField) (bool, error) {
+
+	return IdentityReverified(ctx, a.Next, userID, entity)
+}
+
+func (a *ScreeningApprover) ApproveIdentity(ctx context.Context,
+	userID string) (bool, string, error) {
+
+	if a.Next != nil {
+		approved, description, err := a.Next.ApproveIdentity(ctx, userID)
+		if err != nil || !approved {
+			return approved, description, err
+		}
+	}
+
+	return a.check(ctx, userID, true)
+}
+
+func (a *ScreeningApprover) ApproveTransfer(ctx context.Context, contract, instrumentID string,
+	userID string) (bool, string, error) {
+
+	if a.Next != nil {
+		approved, description, err := a.Next.ApproveTransfer(ctx, contract, instrumentID, userID)
+		if err != nil || !approved {
+			return approved, description, err
+		}
+	}
+
+	return a.check(ctx, userID, false)
+}
+
+// HealthCheck checks the next approver.
+func (a *ScreeningApprover) HealthCheck(ctx context.Context) error {
+	return CheckApproverHealth(ctx, a.Next)
+}
+
+// screen records hits for the entity. The identity is still accepted so that it can be reviewed,
+// but it isn't approved for use until its hits are dismissed.
+func (a *ScreeningApprover) screen(ctx context.Context, userID string,
+	entity *actions.EntityField) (bool, string, error) {
+
+	hits, err := ScreenEntity(ctx, a.Store, a.Screener, userID, entity)
+	if err != nil {
+		return false, "", errors.Wrap(err, "screen entity")
+	}
+
+	// Confirmed hits deny the identity and dismissed hits don't affect the user.
+	if description := screeningDenial(hits, false); len(description) != 0 {
+		return false, description, nil
+	}
+
+	for _, hit := range hits {
+		if hit.Status == ScreeningPending {
+			return true, "pending sanctions screening review", nil
+		}
+	}
+
+	return true, "", nil
+}
+
+func (a *ScreeningApprover) check(ctx context.Context, userID string,
+	includePending bool) (bool, string, error) {
+
+	hits, err := FetchScreeningHits(ctx, a.Store, userID, "")
+	if err != nil {
+		return false, "", errors.Wrap(err, "fetch screening hits")
+	}
+
+	if description := screeningDenial(hits, includePending); len(description) != 0 {
+		return false, description, nil
+	}
+
+	return true, "", nil
+}
@@ -0,0 +1,198 @@
+package oracle
+
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+// Store persists users and the data related to them. Entities and document content are passed to
+// and from a store in plaintext so a store that keeps them at rest is responsible for encrypting
+// them. Values that are looked up by hash are hashed by HashValue so a store can key the hashes.
+// Fetches of a single record return the matching oracle not found error, and fetches of lists
+// return nil when nothing matches.
+type Store interface {
+	// HashValue returns the hash of a value that is stored so that it can be looked up, like a
+	// fingerprint, without storing the value itself.
+	HashValue(value []byte) []byte
+
+	// PreviousValueHashes returns the hashes a value could have been given by HashValue before
+	// the store's current key was configured.
+	PreviousValueHashes(value []byte) [][]byte
+
+	// InsertUser inserts a user and adds their public key to their key history. Either both or
+	// neither are inserted.
+	InsertUser(ctx context.Context, user *User) error
+
+	// FetchUser returns a user that hasn't been deleted.
+	FetchUser(ctx context.Context, id string) (*User, error)
+
+	// FetchUserByXPub returns the user that hasn't been deleted and registered the xpub.
+	FetchUserByXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*User, error)
+
+	// FetchUserIDs returns the ids of the users that haven't been deleted.
+	FetchUserIDs(ctx context.Context) ([]string, error)
+
+	// FetchUsersExpiringBefore returns the users whose identities expire before the time specified
+	// and who haven't been notified since their identity was last renewed.
+	FetchUsersExpiringBefore(ctx context.Context, before time.Time) ([]*User, error)
+
+	// UpdateUser updates the entity, modified date, and identity expiry of a user.
+	UpdateUser(ctx context.Context, user *User) error
+
+	// SetVerificationLevel sets the KYC tier of a user.
+	SetVerificationLevel(ctx context.Context, userID string, level int, now time.Time) error
+
+	// SetIdentityExpiry sets when a user's identity expires and clears whether they were notified.
+	SetIdentityExpiry(ctx context.Context, userID string, expires *time.Time) error
+
+	// SetExpiryNotified records when a user was notified that their identity is expiring.
+	SetExpiryNotified(ctx context.Context, userID string, notified time.Time) error
+
+	// FetchPublicKeys returns a user's key history, oldest first.
+	FetchPublicKeys(ctx context.Context, userID string) ([]*PublicKey, error)
+
+	// FetchPublicKeyAt returns the public key that was active for a user at the time specified.
+	FetchPublicKeyAt(ctx context.Context, userID string, at time.Time) (*PublicKey, error)
+
+	// RotatePublicKey revokes the user's current public key, adds newKey to their key history,
+	// and makes it their public key. Either all or none of the changes are made.
+	RotatePublicKey(ctx context.Context, user *User, newKey bitcoin.PublicKey, reason string,
+		now time.Time) error
+
+	// InsertUserEntity inserts an identity submission into a user's identity history.
+	InsertUserEntity(ctx context.Context, userEntity *UserEntity) error
+
+	// FetchUserEntities returns a user's identity history, oldest first.
+	FetchUserEntities(ctx context.Context, userID string) ([]*UserEntity, error)
+
+	// FetchUserEntityAt returns the latest approved identity of a user created at or before the
+	// time specified.
+	FetchUserEntityAt(ctx context.Context, userID string, at time.Time) (*UserEntity, error)
+
+	// InsertXPub inserts an xpub unless the user already registered it.
+	InsertXPub(ctx context.Context, xpub *XPub) error
+
+	// FetchXPub returns a registered xpub.
+	FetchXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*XPub, error)
+
+	// FetchXPubsByUser returns the xpubs registered by a user, oldest first.
+	FetchXPubsByUser(ctx context.Context, userID string) ([]*XPub, error)
+
+	// InsertFieldVerification inserts a field verification.
+	InsertFieldVerification(ctx context.Context, verification *FieldVerification) error
+
+	// FetchFieldVerifications returns a user's field verifications, oldest first.
+	FetchFieldVerifications(ctx context.Context, userID string) ([]*FieldVerification, error)
+
+	// FetchFieldVerificationsByValue returns the verifications of a field value, newest first. An
+	// empty userID returns the verifications of all users.
+	FetchFieldVerificationsByValue(ctx context.Context, userID, field string,
+		valueHash []byte) ([]*FieldVerification, error)
+
+	// SetVerificationValueHash replaces the value hash of a field verification.
+	SetVerificationValueHash(ctx context.Context, id string, valueHash []byte) error
+
+	// IncrementVerificationAttempts records a code entered for a field verification unless it
+	// already has maxAttempts. Returns false when it does. The check and increment are atomic so
+	// concurrent requests can't make more than maxAttempts.
+	IncrementVerificationAttempts(ctx context.Context, id string, maxAttempts int) (bool, error)
+
+	// MarkFieldVerified records when a field verification was completed and when it expires.
+	MarkFieldVerified(ctx context.Context, id string, verified time.Time,
+		validUntil *time.Time) error
+
+	// SetUserAttribute sets an attribute on a user, replacing it if it is already set.
+	SetUserAttribute(ctx context.Context, attribute *UserAttribute) error
+
+	// RemoveUserAttribute removes an attribute from a user.
+	RemoveUserAttribute(ctx context.Context, userID, attribute string) error
+
+	// FetchUserAttributes returns the attributes of a user that haven't expired at the time
+	// specified, ordered by attribute.
+	FetchUserAttributes(ctx context.Context, userID string,
+		now time.Time) ([]*UserAttribute, error)
+
+	// SetInstrumentRequirement sets the requirement of an instrument, replacing any existing one.
+	SetInstrumentRequirement(ctx context.Context, requirement *InstrumentRequirement) error
+
+	// FetchInstrumentRequirement returns the requirement of an instrument, or nil if there is
+	// none.
+	FetchInstrumentRequirement(ctx context.Context, contract,
+		instrumentID string) (*InstrumentRequirement, error)
+
+	// InsertDocument writes a document's content and inserts its metadata.
+	InsertDocument(ctx context.Context, document *Document, content []byte) error
+
+	// FetchDocument returns the metadata of a document.
+	FetchDocument(ctx context.Context, id string) (*Document, error)
+
+	// FetchDocuments returns the metadata of a user's documents, oldest first.
+	FetchDocuments(ctx context.Context, userID string) ([]*Document, error)
+
+	// ReadDocumentContent returns the content of a document.
+	ReadDocumentContent(ctx context.Context, document *Document) ([]byte, error)
+
+	// InsertScreeningHit inserts a screening hit unless the match was already recorded for the
+	// user. Returns the recorded hit, which is the existing one with its review status when the
+	// match was already recorded, and whether it was inserted.
+	InsertScreeningHit(ctx context.Context, hit *ScreeningHit) (*ScreeningHit, bool, error)
+
+	// FetchScreeningHits returns screening hits, oldest first. Empty userID or status return hits
+	// for all users or statuses.
+	FetchScreeningHits(ctx context.Context, userID, status string) ([]*ScreeningHit, error)
+
+	// FetchScreeningHit returns a screening hit.
+	FetchScreeningHit(ctx context.Context, id string) (*ScreeningHit, error)
+
+	// UpdateScreeningHit updates the status, note, and review date of a screening hit.
+	UpdateScreeningHit(ctx context.Context, hit *ScreeningHit) error
+
+	// SetFingerprints replaces the fingerprints of a user by kind.
+	SetFingerprints(ctx context.Context, userID string, fingerprints map[string][]byte,
+		now time.Time) error
+
+	// FetchFingerprintUserIDs returns the ids of users, other than excludeUserID, that haven't
+	// been deleted and have the fingerprint, oldest user first.
+	FetchFingerprintUserIDs(ctx context.Context, kind string, fingerprint []byte,
+		excludeUserID string) ([]string, error)
+
+	// InsertDuplicate inserts a duplicate unless it was already recorded.
+	InsertDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error
+
+	// FetchDuplicates returns duplicates, oldest first. A non-empty userID only returns
+	// duplicates involving that user and a non-empty status only returns duplicates with that
+	// status.
+	FetchDuplicates(ctx context.Context, userID, status string) ([]*DuplicateIdentity, error)
+
+	// FetchDuplicate returns a duplicate.
+	FetchDuplicate(ctx context.Context, id string) (*DuplicateIdentity, error)
+
+	// UpdateDuplicate updates the status, note, and review date of a duplicate.
+	UpdateDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error
+
+	// MergeUsers moves the xpubs of mergeUserID that keepUserID doesn't have to keepUserID,
+	// deletes mergeUserID and its fingerprints, and updates the duplicate. Either all or none of
+	// the changes are made.
+	MergeUsers(ctx context.Context, keepUserID, mergeUserID string,
+		duplicate *DuplicateIdentity) error
+
+	// InsertAPIKey inserts an API key with the hash of its key.
+	InsertAPIKey(ctx context.Context, apiKey *APIKey, keyHash []byte) error
+
+	// FetchAPIKeyByHash returns the API key that hasn't been revoked with the key hash.
+	FetchAPIKeyByHash(ctx context.Context, keyHash []byte) (*APIKey, error)
+
+	// FetchAPIKey returns the API key that hasn't been revoked with the id.
+	FetchAPIKey(ctx context.Context, id string) (*APIKey, error)
+
+	// FetchAPIKeys returns all API keys, including revoked keys, oldest first.
+	FetchAPIKeys(ctx context.Context) ([]*APIKey, error)
+
+	// SetAPIKeyLastUsed records when an API key was last used.
+	SetAPIKeyLastUsed(ctx context.Context, id string, used time.Time) error
+
+	// RevokeAPIKey stops an API key from being returned by FetchAPIKeyByHash.
+	RevokeAPIKey(ctx context.Context, id string, revoked time.Time) error
+}
@@ -0,0 +1,1479 @@
+package oracle
+
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	UserColumns = `
+		u.id,
+		u.entity,
+		u.public_key,
+		u.date_created,
+		u.date_modified,
+		u.is_deleted,
+		u.verification_level,
+		u.date_identity_expires,
+		u.date_expiry_notified`
+
+	PublicKeyColumns = `
+		pk.user_id,
+		pk.public_key,
+		pk.date_created,
+		pk.date_revoked,
+		pk.revoke_reason`
+
+	UserEntityColumns = `
+		ue.id,
+		ue.user_id,
+		ue.entity,
+		ue.signature,
+		ue.approved,
+		ue.description,
+		ue.date_created`
+
+	XPubColumns = `
+		xp.id,
+		xp.user_id,
+		xp.xpub,
+		xp.required_signers,
+		xp.date_created
+	`
+
+	FieldVerificationColumns = `
+		fv.id,
+		fv.user_id,
+		fv.field,
+		fv.value_hash,
+		fv.code_hash,
+		fv.attempts,
+		fv.date_created,
+		fv.date_expires,
+		fv.date_verified,
+		fv.date_valid_until`
+
+	UserAttributeColumns = `
+		ua.user_id,
+		ua.attribute,
+		ua.date_expires,
+		ua.set_by,
+		ua.date_created`
+
+	InstrumentRequirementColumns = `
+		ir.contract,
+		ir.instrument_id,
+		ir.min_verification_level,
+		ir.required_attributes,
+		ir.date_modified`
+
+	DocumentColumns = `
+		ud.id,
+		ud.user_id,
+		ud.document_type,
+		ud.file_name,
+		ud.content_type,
+		ud.size,
+		ud.content_hash,
+		ud.storage_key,
+		ud.signature,
+		ud.date_created`
+
+	ScreeningHitColumns = `
+		sh.id,
+		sh.user_id,
+		sh.field,
+		sh.screened_name,
+		sh.list_name,
+		sh.entry_id,
+		sh.entry_name,
+		sh.matched_name,
+		sh.score,
+		sh.status,
+		sh.note,
+		sh.date_created,
+		sh.date_reviewed`
+
+	DuplicateIdentityColumns = `
+		di.id,
+		di.user_id,
+		di.duplicate_user_id,
+		di.kind,
+		di.status,
+		di.note,
+		di.date_created,
+		di.date_reviewed`
+
+	APIKeyColumns = `
+		k.id,
+		k.name,
+		k.prefix,
+		k.scopes,
+		k.date_created,
+		k.date_last_used,
+		k.date_revoked`
+)
+
+// DBStore is a Store in a SQL database. Entities and document content are encrypted with the
+// database's master keys, value hashes are keyed by them, and document content is kept in the
+// database's storage.
+type DBStore struct {
+	MasterDB *db.DB
+}
+
+// NewDBStore creates a store in the database.
+func NewDBStore(masterDB *db.DB) *DBStore {
+	return &DBStore{
+		MasterDB: masterDB,
+	}
+}
+
+func (s *DBStore) HashValue(value []byte) []byte {
+	return s.MasterDB.Hash(value)
+}
+
+func (s *DBStore) PreviousValueHashes(value []byte) [][]byte {
+	return s.MasterDB.PreviousHashes(value)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Users
+
+func (s *DBStore) InsertUser(ctx context.Context, user *User) error {
+	tx := s.MasterDB.Copy()
+	defer tx.Close()
+
+	sql := `INSERT
+		INTO users (
+			id,
+			entity,
+			public_key,
+			date_created,
+			date_modified,
+			is_deleted,
+			date_identity_expires
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	encryptedEntity, err := tx.Encrypt(user.Entity)
+	if err != nil {
+		return errors.Wrap(err, "encrypt entity")
+	}
+
+	if err := tx.Begin(); err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	if err := tx.Execute(ctx, sql,
+		user.ID,
+		encryptedEntity,
+		user.PublicKey,
+		user.DateCreated,
+		user.DateModified,
+		user.IsDeleted,
+		user.DateIdentityExpires); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "insert user")
+	}
+
+	if err := insertPublicKey(ctx, tx, &PublicKey{
+		UserID:      user.ID,
+		PublicKey:   user.PublicKey,
+		DateCreated: user.DateCreated,
+	}); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "insert public key")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit")
+	}
+
+	return nil
+}
+
+func (s *DBStore) FetchUser(ctx context.Context, id string) (*User, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserColumns + ` FROM users u WHERE u.id=? AND u.is_deleted=false`
+
+	user := &User{}
+	if err := dbConn.Get(ctx, user, sql, id); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrUserNotFound, id)
+		}
+		return nil, err
+	}
+
+	if err := decryptUser(dbConn, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *DBStore) FetchUserByXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*User,
+	error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserColumns + `
+		FROM
+			users u,
+			xpubs
+		WHERE
+			xpubs.xpub = ?
+			AND xpubs.user_id=u.id
+			AND u.is_deleted=false`
+
+	user := &User{}
+	if err := dbConn.Get(ctx, user, sql, xpubs); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrXPubNotFound, xpubs.String())
+		}
+		return nil, err
+	}
+
+	if err := decryptUser(dbConn, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *DBStore) FetchUserIDs(ctx context.Context) ([]string, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	var result []string
+	if err := dbConn.Select(ctx, &result,
+		`SELECT id FROM users WHERE is_deleted=false`); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchUsersExpiringBefore(ctx context.Context, before time.Time) ([]*User,
+	error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserColumns + `
+		FROM
+			users u
+		WHERE
+			u.is_deleted = false
+			AND u.date_identity_expires IS NOT NULL
+			AND u.date_identity_expires < ?
+			AND u.date_expiry_notified IS NULL`
+
+	var result []*User
+	if err := dbConn.Select(ctx, &result, sql, before); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, user := range result {
+		if err := decryptUser(dbConn, user); err != nil {
+			return nil, errors.Wrap(err, user.ID)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) UpdateUser(ctx context.Context, user *User) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE users
+		SET entity=?, date_modified=?, date_identity_expires=?, date_expiry_notified=?
+		WHERE id=?`
+
+	encryptedEntity, err := dbConn.Encrypt(user.Entity)
+	if err != nil {
+		return errors.Wrap(err, "encrypt entity")
+	}
+
+	return dbConn.Execute(ctx, sql,
+		encryptedEntity,
+		user.DateModified,
+		user.DateIdentityExpires,
+		user.DateExpiryNotified,
+		user.ID)
+}
+
+func (s *DBStore) SetVerificationLevel(ctx context.Context, userID string, level int,
+	now time.Time) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE users
+		SET verification_level = ?, date_modified = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, level, now, userID)
+}
+
+func (s *DBStore) SetIdentityExpiry(ctx context.Context, userID string,
+	expires *time.Time) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE users
+		SET date_identity_expires = ?, date_expiry_notified = NULL
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, expires, userID)
+}
+
+func (s *DBStore) SetExpiryNotified(ctx context.Context, userID string,
+	notified time.Time) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE users
+		SET date_expiry_notified = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, notified, userID)
+}
+
+// EncryptEntities encrypts any stored entities that are in plaintext or are encrypted with a
+// master key other than the current one. It is used to encrypt existing data when encryption is
+// first enabled and to re-encrypt data after the master key is rotated.
+// Returns the number of values that were encrypted.
+func (s *DBStore) EncryptEntities(ctx context.Context) (int, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	count := 0
+
+	tables := []string{"users", "user_entities"}
+	for _, table := range tables {
+		var rows []struct {
+			ID     string `db:"id"`
+			Entity []byte `db:"entity"`
+		}
+
+		if err := dbConn.Select(ctx, &rows, `SELECT id, entity FROM `+table); err != nil {
+			if errors.Cause(err) == db.ErrNotFound {
+				continue
+			}
+			return count, errors.Wrapf(err, "select %s", table)
+		}
+
+		for _, row := range rows {
+			if !dbConn.NeedsEncrypt(row.Entity) {
+				continue
+			}
+
+			plaintext, err := dbConn.Decrypt(row.Entity)
+			if err != nil {
+				return count, errors.Wrapf(err, "decrypt %s %s", table, row.ID)
+			}
+
+			encrypted, err := dbConn.Encrypt(plaintext)
+			if err != nil {
+				return count, errors.Wrapf(err, "encrypt %s %s", table, row.ID)
+			}
+
+			if err := dbConn.Execute(ctx, `UPDATE `+table+` SET entity=? WHERE id=?`, encrypted,
+				row.ID); err != nil {
+				return count, errors.Wrapf(err, "update %s %s", table, row.ID)
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// decryptUser replaces the stored form of the user's entity with the plaintext.
+func decryptUser(dbConn *db.DB, user *User) error {
+	entity, err := dbConn.Decrypt(user.Entity)
+	if err != nil {
+		return errors.Wrap(err, "decrypt entity")
+	}
+
+	user.Entity = entity
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Public Keys
+
+func insertPublicKey(ctx context.Context, dbConn *db.DB, publicKey *PublicKey) error {
+	sql := `INSERT
+		INTO public_keys (
+			user_id,
+			public_key,
+			date_created,
+			date_revoked,
+			revoke_reason
+		)
+		VALUES (?, ?, ?, ?, ?)`
+
+	return dbConn.Execute(ctx, sql,
+		publicKey.UserID,
+		publicKey.PublicKey,
+		publicKey.DateCreated,
+		publicKey.DateRevoked,
+		publicKey.RevokeReason)
+}
+
+func (s *DBStore) FetchPublicKeys(ctx context.Context, userID string) ([]*PublicKey, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + PublicKeyColumns + `
+		FROM
+			public_keys pk
+		WHERE
+			pk.user_id = ?
+		ORDER BY pk.date_created`
+
+	var result []*PublicKey
+	if err := dbConn.Select(ctx, &result, sql, userID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *DBStore) FetchPublicKeyAt(ctx context.Context, userID string,
+	at time.Time) (*PublicKey, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + PublicKeyColumns + `
+		FROM
+			public_keys pk
+		WHERE
+			pk.user_id = ?
+			AND pk.date_created <= ?
+			AND (pk.date_revoked IS NULL OR pk.date_revoked > ?)
+		ORDER BY pk.date_created DESC
+		LIMIT 1`
+
+	result := &PublicKey{}
+	if err := dbConn.Get(ctx, result, sql, userID, at, at); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrPublicKeyNotFound, userID)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *DBStore) RotatePublicKey(ctx context.Context, user *User, newKey bitcoin.PublicKey,
+	reason string, now time.Time) error {
+
+	tx := s.MasterDB.Copy()
+	defer tx.Close()
+
+	if err := tx.Begin(); err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	revokeSQL := `UPDATE public_keys
+		SET date_revoked=?, revoke_reason=?
+		WHERE user_id=? AND public_key=? AND date_revoked IS NULL`
+
+	if err := tx.Execute(ctx, revokeSQL, now, reason, user.ID, user.PublicKey); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "revoke public key")
+	}
+
+	if err := insertPublicKey(ctx, tx, &PublicKey{
+		UserID:      user.ID,
+		PublicKey:   newKey,
+		DateCreated: now,
+	}); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "create public key")
+	}
+
+	userSQL := `UPDATE users SET public_key=?, date_modified=? WHERE id=?`
+
+	if err := tx.Execute(ctx, userSQL, newKey, now, user.ID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "update user")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit")
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// User Entities
+
+func (s *DBStore) InsertUserEntity(ctx context.Context, userEntity *UserEntity) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO user_entities (
+			id,
+			user_id,
+			entity,
+			signature,
+			approved,
+			description,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	encryptedEntity, err := dbConn.Encrypt(userEntity.Entity)
+	if err != nil {
+		return errors.Wrap(err, "encrypt entity")
+	}
+
+	return dbConn.Execute(ctx, sql,
+		userEntity.ID,
+		userEntity.UserID,
+		encryptedEntity,
+		userEntity.Signature,
+		userEntity.Approved,
+		userEntity.Description,
+		userEntity.DateCreated)
+}
+
+func (s *DBStore) FetchUserEntities(ctx context.Context, userID string) ([]*UserEntity, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserEntityColumns + `
+		FROM
+			user_entities ue
+		WHERE
+			ue.user_id = ?
+		ORDER BY ue.date_created`
+
+	var result []*UserEntity
+	if err := dbConn.Select(ctx, &result, sql, userID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, userEntity := range result {
+		entity, err := dbConn.Decrypt(userEntity.Entity)
+		if err != nil {
+			return nil, errors.Wrap(err, "decrypt entity")
+		}
+		userEntity.Entity = entity
+	}
+	return result, nil
+}
+
+func (s *DBStore) FetchUserEntityAt(ctx context.Context, userID string,
+	at time.Time) (*UserEntity, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserEntityColumns + `
+		FROM
+			user_entities ue
+		WHERE
+			ue.user_id = ?
+			AND ue.approved = true
+			AND ue.date_created <= ?
+		ORDER BY ue.date_created DESC
+		LIMIT 1`
+
+	result := &UserEntity{}
+	if err := dbConn.Get(ctx, result, sql, userID, at); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrUserEntityNotFound, userID)
+		}
+		return nil, err
+	}
+
+	entity, err := dbConn.Decrypt(result.Entity)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt entity")
+	}
+	result.Entity = entity
+	return result, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// XPubs
+
+func (s *DBStore) InsertXPub(ctx context.Context, xpub *XPub) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO xpubs (
+			id,
+			user_id,
+			xpub,
+			required_signers,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, xpub) DO NOTHING`
+
+	return dbConn.Execute(ctx, sql,
+		xpub.ID,
+		xpub.UserID,
+		xpub.XPub,
+		xpub.RequiredSigners,
+		xpub.DateCreated)
+}
+
+func (s *DBStore) FetchXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*XPub, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + XPubColumns + `
+		FROM
+			xpubs xp
+		WHERE
+			xp.xpub = ?`
+
+	result := &XPub{}
+	if err := dbConn.Get(ctx, result, sql, xpubs); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrXPubNotFound, xpubs.String())
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *DBStore) FetchXPubsByUser(ctx context.Context, userID string) ([]*XPub, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + XPubColumns + `
+		FROM
+			xpubs xp
+		WHERE
+			xp.user_id = ?
+		ORDER BY xp.date_created`
+
+	var result []*XPub
+	if err := dbConn.Select(ctx, &result, sql, userID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Field Verifications
+
+func (s *DBStore) InsertFieldVerification(ctx context.Context,
+	verification *FieldVerification) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO field_verifications (
+			id,
+			user_id,
+			field,
+			value_hash,
+			code_hash,
+			attempts,
+			date_created,
+			date_expires,
+			date_verified,
+			date_valid_until
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	return dbConn.Execute(ctx, sql,
+		verification.ID,
+		verification.UserID,
+		verification.Field,
+		verification.ValueHash,
+		verification.CodeHash,
+		verification.Attempts,
+		verification.DateCreated,
+		verification.DateExpires,
+		verification.DateVerified,
+		verification.DateValidUntil)
+}
+
+func (s *DBStore) FetchFieldVerifications(ctx context.Context,
+	userID string) ([]*FieldVerification, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + FieldVerificationColumns + `
+		FROM
+			field_verifications fv
+		WHERE
+			fv.user_id = ?
+		ORDER BY fv.date_created`
+
+	var result []*FieldVerification
+	if err := dbConn.Select(ctx, &result, sql, userID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchFieldVerificationsByValue(ctx context.Context, userID, field string,
+	valueHash []byte) ([]*FieldVerification, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + FieldVerificationColumns + `
+		FROM
+			field_verifications fv
+		WHERE
+			(? = '' OR CAST(fv.user_id AS TEXT) = ?)
+			AND fv.field = ?
+			AND fv.value_hash = ?
+		ORDER BY fv.date_created DESC`
+
+	var result []*FieldVerification
+	if err := dbConn.Select(ctx, &result, sql, userID, userID, field, valueHash); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) SetVerificationValueHash(ctx context.Context, id string,
+	valueHash []byte) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE field_verifications
+		SET value_hash = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, valueHash, id)
+}
+
+func (s *DBStore) IncrementVerificationAttempts(ctx context.Context, id string,
+	maxAttempts int) (bool, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE field_verifications
+		SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ?`
+
+	count, err := dbConn.ExecuteCount(ctx, sql, id, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (s *DBStore) MarkFieldVerified(ctx context.Context, id string, verified time.Time,
+	validUntil *time.Time) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE field_verifications
+		SET date_verified = ?, date_valid_until = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, verified, validUntil, id)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Attributes and Requirements
+
+func (s *DBStore) SetUserAttribute(ctx context.Context, attribute *UserAttribute) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO user_attributes (
+			user_id,
+			attribute,
+			date_expires,
+			set_by,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, attribute) DO UPDATE
+		SET date_expires = EXCLUDED.date_expires,
+			set_by = EXCLUDED.set_by,
+			date_created = EXCLUDED.date_created`
+
+	return dbConn.Execute(ctx, sql,
+		attribute.UserID,
+		attribute.Attribute,
+		attribute.DateExpires,
+		attribute.SetBy,
+		attribute.DateCreated)
+}
+
+func (s *DBStore) RemoveUserAttribute(ctx context.Context, userID, attribute string) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `DELETE FROM user_attributes
+		WHERE user_id = ? AND attribute = ?`
+
+	return dbConn.Execute(ctx, sql, userID, attribute)
+}
+
+func (s *DBStore) FetchUserAttributes(ctx context.Context, userID string,
+	now time.Time) ([]*UserAttribute, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + UserAttributeColumns + `
+		FROM
+			user_attributes ua
+		WHERE
+			ua.user_id = ?
+			AND (ua.date_expires IS NULL OR ua.date_expires > ?)
+		ORDER BY ua.attribute`
+
+	var result []*UserAttribute
+	if err := dbConn.Select(ctx, &result, sql, userID, now); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) SetInstrumentRequirement(ctx context.Context,
+	requirement *InstrumentRequirement) error {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO instrument_requirements (
+			contract,
+			instrument_id,
+			min_verification_level,
+			required_attributes,
+			date_modified
+		)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (contract, instrument_id) DO UPDATE
+		SET min_verification_level = EXCLUDED.min_verification_level,
+			required_attributes = EXCLUDED.required_attributes,
+			date_modified = EXCLUDED.date_modified`
+
+	return dbConn.Execute(ctx, sql,
+		requirement.Contract,
+		requirement.InstrumentID,
+		requirement.MinVerificationLevel,
+		requirement.RequiredAttributes,
+		requirement.DateModified)
+}
+
+func (s *DBStore) FetchInstrumentRequirement(ctx context.Context, contract,
+	instrumentID string) (*InstrumentRequirement, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + InstrumentRequirementColumns + `
+		FROM
+			instrument_requirements ir
+		WHERE
+			ir.contract = ?
+			AND ir.instrument_id = ?`
+
+	result := &InstrumentRequirement{}
+	if err := dbConn.Get(ctx, result, sql, contract, instrumentID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Documents
+
+func (s *DBStore) InsertDocument(ctx context.Context, document *Document, content []byte) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	encrypted, err := dbConn.Encrypt(content)
+	if err != nil {
+		return errors.Wrap(err, "encrypt content")
+	}
+
+	if err := dbConn.Put(ctx, document.StorageKey, encrypted); err != nil {
+		return errors.Wrap(err, "put content")
+	}
+
+	sql := `INSERT
+		INTO user_documents (
+			id,
+			user_id,
+			document_type,
+			file_name,
+			content_type,
+			size,
+			content_hash,
+			storage_key,
+			signature,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	return dbConn.Execute(ctx, sql,
+		document.ID,
+		document.UserID,
+		document.DocumentType,
+		document.FileName,
+		document.ContentType,
+		document.Size,
+		document.ContentHash,
+		document.StorageKey,
+		document.Signature,
+		document.DateCreated)
+}
+
+func (s *DBStore) FetchDocument(ctx context.Context, id string) (*Document, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + DocumentColumns + `
+		FROM
+			user_documents ud
+		WHERE
+			ud.id = ?`
+
+	result := &Document{}
+	if err := dbConn.Get(ctx, result, sql, id); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrDocumentNotFound, id)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchDocuments(ctx context.Context, userID string) ([]*Document, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + DocumentColumns + `
+		FROM
+			user_documents ud
+		WHERE
+			ud.user_id = ?
+		ORDER BY ud.date_created`
+
+	var result []*Document
+	if err := dbConn.Select(ctx, &result, sql, userID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) ReadDocumentContent(ctx context.Context, document *Document) ([]byte, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	encrypted, err := dbConn.Fetch(ctx, document.StorageKey)
+	if err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrDocumentNotFound, document.StorageKey)
+		}
+		return nil, errors.Wrap(err, "fetch content")
+	}
+
+	content, err := dbConn.Decrypt(encrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt content")
+	}
+
+	return content, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Screening
+
+func (s *DBStore) InsertScreeningHit(ctx context.Context,
+	hit *ScreeningHit) (*ScreeningHit, bool, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO screening_hits (
+			id,
+			user_id,
+			field,
+			screened_name,
+			list_name,
+			entry_id,
+			entry_name,
+			matched_name,
+			score,
+			status,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, screened_name, list_name, entry_id) DO NOTHING`
+
+	count, err := dbConn.ExecuteCount(ctx, sql,
+		hit.ID,
+		hit.UserID,
+		hit.Field,
+		hit.ScreenedName,
+		hit.ListName,
+		hit.EntryID,
+		hit.EntryName,
+		hit.MatchedName,
+		hit.Score,
+		hit.Status,
+		hit.DateCreated)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if count != 0 {
+		result := *hit
+		return &result, true, nil
+	}
+
+	existingSQL := `SELECT ` + ScreeningHitColumns + `
+		FROM
+			screening_hits sh
+		WHERE
+			CAST(sh.user_id AS TEXT) = ?
+			AND sh.screened_name = ?
+			AND sh.list_name = ?
+			AND sh.entry_id = ?`
+
+	result := &ScreeningHit{}
+	if err := dbConn.Get(ctx, result, existingSQL, hit.UserID, hit.ScreenedName, hit.ListName,
+		hit.EntryID); err != nil {
+		return nil, false, errors.Wrap(err, "fetch existing")
+	}
+
+	return result, false, nil
+}
+
+func (s *DBStore) FetchScreeningHits(ctx context.Context, userID,
+	status string) ([]*ScreeningHit, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + ScreeningHitColumns + `
+		FROM
+			screening_hits sh
+		WHERE
+			(? = '' OR CAST(sh.user_id AS TEXT) = ?)
+			AND (? = '' OR sh.status = ?)
+		ORDER BY sh.date_created`
+
+	var result []*ScreeningHit
+	if err := dbConn.Select(ctx, &result, sql, userID, userID, status, status); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchScreeningHit(ctx context.Context, id string) (*ScreeningHit, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + ScreeningHitColumns + `
+		FROM
+			screening_hits sh
+		WHERE
+			sh.id = ?`
+
+	result := &ScreeningHit{}
+	if err := dbConn.Get(ctx, result, sql, id); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrScreeningHitNotFound, id)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) UpdateScreeningHit(ctx context.Context, hit *ScreeningHit) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `UPDATE screening_hits
+		SET status = ?, note = ?, date_reviewed = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, hit.Status, hit.Note, hit.DateReviewed, hit.ID)
+}
+
+// -------------------------------------------------------------------------------------------------
+// Duplicates
+
+func (s *DBStore) SetFingerprints(ctx context.Context, userID string,
+	fingerprints map[string][]byte, now time.Time) error {
+
+	tx := s.MasterDB.Copy()
+	defer tx.Close()
+
+	if err := tx.Begin(); err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	if err := tx.Execute(ctx, `DELETE FROM user_fingerprints WHERE user_id = ?`,
+		userID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "delete fingerprints")
+	}
+
+	sql := `INSERT
+		INTO user_fingerprints (
+			user_id,
+			kind,
+			fingerprint,
+			date_created
+		)
+		VALUES (?, ?, ?, ?)`
+
+	for kind, fingerprint := range fingerprints {
+		if err := tx.Execute(ctx, sql, userID, kind, fingerprint, now); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "insert %s fingerprint", kind)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit")
+	}
+
+	return nil
+}
+
+func (s *DBStore) FetchFingerprintUserIDs(ctx context.Context, kind string, fingerprint []byte,
+	excludeUserID string) ([]string, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT uf.user_id
+		FROM
+			user_fingerprints uf,
+			users u
+		WHERE
+			uf.kind = ?
+			AND uf.fingerprint = ?
+			AND uf.user_id <> ?
+			AND u.id = uf.user_id
+			AND u.is_deleted = false
+		ORDER BY u.date_created`
+
+	var result []string
+	if err := dbConn.Select(ctx, &result, sql, kind, fingerprint, excludeUserID); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) InsertDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO duplicate_identities (
+			id,
+			user_id,
+			duplicate_user_id,
+			kind,
+			status,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, duplicate_user_id, kind) DO NOTHING`
+
+	return dbConn.Execute(ctx, sql,
+		duplicate.ID,
+		duplicate.UserID,
+		duplicate.DuplicateUserID,
+		duplicate.Kind,
+		duplicate.Status,
+		duplicate.DateCreated)
+}
+
+func (s *DBStore) FetchDuplicates(ctx context.Context, userID,
+	status string) ([]*DuplicateIdentity, error) {
+
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + DuplicateIdentityColumns + `
+		FROM
+			duplicate_identities di
+		WHERE
+			(? = '' OR CAST(di.user_id AS TEXT) = ? OR CAST(di.duplicate_user_id AS TEXT) = ?)
+			AND (? = '' OR di.status = ?)
+		ORDER BY di.date_created`
+
+	var result []*DuplicateIdentity
+	if err := dbConn.Select(ctx, &result, sql, userID, userID, userID, status,
+		status); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchDuplicate(ctx context.Context, id string) (*DuplicateIdentity, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + DuplicateIdentityColumns + `
+		FROM
+			duplicate_identities di
+		WHERE
+			di.id = ?`
+
+	result := &DuplicateIdentity{}
+	if err := dbConn.Get(ctx, result, sql, id); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrDuplicateNotFound, id)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) UpdateDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	return updateDuplicate(ctx, dbConn, duplicate)
+}
+
+func updateDuplicate(ctx context.Context, dbConn *db.DB, duplicate *DuplicateIdentity) error {
+	sql := `UPDATE duplicate_identities
+		SET status = ?, note = ?, date_reviewed = ?
+		WHERE id = ?`
+
+	return dbConn.Execute(ctx, sql, duplicate.Status, duplicate.Note, duplicate.DateReviewed,
+		duplicate.ID)
+}
+
+func (s *DBStore) MergeUsers(ctx context.Context, keepUserID, mergeUserID string,
+	duplicate *DuplicateIdentity) error {
+
+	tx := s.MasterDB.Copy()
+	defer tx.Close()
+
+	if err := tx.Begin(); err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	// Xpubs the kept user already has are left with the merged user.
+	xpubSQL := `UPDATE xpubs
+		SET user_id = ?
+		WHERE user_id = ?
+			AND xpub NOT IN (SELECT xpub FROM xpubs WHERE user_id = ?)`
+
+	if err := tx.Execute(ctx, xpubSQL, keepUserID, mergeUserID, keepUserID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "move xpubs")
+	}
+
+	userSQL := `UPDATE users
+		SET is_deleted = true, merged_into = ?, date_modified = ?
+		WHERE id = ?`
+
+	if err := tx.Execute(ctx, userSQL, keepUserID, duplicate.DateReviewed,
+		mergeUserID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "delete merged user")
+	}
+
+	if err := tx.Execute(ctx, `DELETE FROM user_fingerprints WHERE user_id = ?`,
+		mergeUserID); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "delete fingerprints")
+	}
+
+	if err := updateDuplicate(ctx, tx, duplicate); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "update duplicate")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit")
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// API Keys
+
+func (s *DBStore) InsertAPIKey(ctx context.Context, apiKey *APIKey, keyHash []byte) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `INSERT
+		INTO api_keys (
+			id,
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			date_created
+		)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	return dbConn.Execute(ctx, sql,
+		apiKey.ID,
+		apiKey.Name,
+		apiKey.Prefix,
+		keyHash,
+		apiKey.Scopes,
+		apiKey.DateCreated)
+}
+
+func (s *DBStore) FetchAPIKeyByHash(ctx context.Context, keyHash []byte) (*APIKey, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + APIKeyColumns + `
+		FROM
+			api_keys k
+		WHERE
+			k.key_hash = ?
+			AND k.date_revoked IS NULL`
+
+	result := &APIKey{}
+	if err := dbConn.Get(ctx, result, sql, keyHash); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + APIKeyColumns + `
+		FROM
+			api_keys k
+		WHERE
+			k.id = ?
+			AND k.date_revoked IS NULL`
+
+	result := &APIKey{}
+	if err := dbConn.Get(ctx, result, sql, id); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, errors.Wrap(ErrAPIKeyNotFound, id)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) FetchAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	sql := `SELECT ` + APIKeyColumns + `
+		FROM
+			api_keys k
+		ORDER BY k.date_created`
+
+	var result []*APIKey
+	if err := dbConn.Select(ctx, &result, sql); err != nil {
+		if errors.Cause(err) == db.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *DBStore) SetAPIKeyLastUsed(ctx context.Context, id string, used time.Time) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	return dbConn.Execute(ctx, `UPDATE api_keys SET date_last_used = ? WHERE id = ?`, used, id)
+}
+
+func (s *DBStore) RevokeAPIKey(ctx context.Context, id string, revoked time.Time) error {
+	dbConn := s.MasterDB.Copy()
+	defer dbConn.Close()
+
+	return dbConn.Execute(ctx, `UPDATE api_keys SET date_revoked = ? WHERE id = ?`, revoked, id)
+}
@@ -0,0 +1,350 @@
+package oracle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/identity-oracle/internal/platform/encryption"
+	"github.com/tokenized/identity-oracle/internal/platform/migrate"
+	"github.com/tokenized/identity-oracle/internal/platform/tests"
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// TestDBStoreSQLite checks the database store's SQL against an in-memory SQLite database.
+func TestDBStoreSQLite(t *testing.T) {
+	ctx := tests.Context()
+
+	masterDB, err := db.New(&db.DBConfig{
+		Driver: db.DriverSQLite,
+		URL:    ":memory:",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open database : %s", err)
+	}
+	defer masterDB.Close()
+
+	if _, err := migrate.Up(ctx, masterDB); err != nil {
+		t.Fatalf("Failed to migrate database : %s", err)
+	}
+
+	if err := migrate.Check(ctx, masterDB); err != nil {
+		t.Fatalf("Migrations should be applied : %s", err)
+	}
+
+	store := NewDBStore(masterDB)
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:        "Test Entity Name",
+		CountryCode: "AUS",
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  now,
+		DateModified: now,
+	}
+
+	if err := CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	expires := now.Add(time.Hour)
+	user.DateIdentityExpires = &expires
+	if err := UpdateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to update user : %s", err)
+	}
+
+	fuser, err := FetchUser(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user : %s", err)
+	}
+
+	if fuser.DateIdentityExpires == nil || !fuser.DateIdentityExpires.Equal(expires) {
+		t.Fatalf("Wrong identity expiry : got %v, want %v", fuser.DateIdentityExpires, expires)
+	}
+
+	expiring, err := store.FetchUsersExpiringBefore(ctx, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to fetch expiring users : %s", err)
+	}
+
+	if len(expiring) != 1 || expiring[0].ID != user.ID {
+		t.Fatalf("User should be expiring : %d", len(expiring))
+	}
+
+	xp, err := bitcoin.GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to create xpub : %s", err)
+	}
+
+	xpubs := bitcoin.ExtendedKeys{xp}
+
+	// The second insert of the same xpub is ignored.
+	for i := 0; i < 2; i++ {
+		if err := CreateXPub(ctx, store, &XPub{
+			UserID:          user.ID,
+			XPub:            xpubs,
+			RequiredSigners: 1,
+			DateCreated:     now,
+		}); err != nil {
+			t.Fatalf("Failed to create xpub : %s", err)
+		}
+	}
+
+	userXPubs, err := store.FetchXPubsByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user xpubs : %s", err)
+	}
+
+	if len(userXPubs) != 1 {
+		t.Fatalf("Wrong xpub count : got %d, want 1", len(userXPubs))
+	}
+
+	fuser, err = FetchUserByXPub(ctx, store, xpubs)
+	if err != nil {
+		t.Fatalf("Failed to fetch user by xpubs : %s", err)
+	}
+
+	if fuser.ID != user.ID {
+		t.Fatalf("Invalid user id")
+	}
+
+	newKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate new key : %s", err)
+	}
+
+	if err := RotatePublicKey(ctx, store, user, newKey.PublicKey(), "test"); err != nil {
+		t.Fatalf("Failed to rotate public key : %s", err)
+	}
+
+	keys, err := FetchPublicKeys(ctx, store, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch public keys : %s", err)
+	}
+
+	if len(keys) != 2 {
+		t.Fatalf("Wrong public key count : got %d, want %d", len(keys), 2)
+	}
+
+	if keys[0].DateRevoked == nil {
+		t.Fatalf("Old public key not revoked")
+	}
+
+	if !bytes.Equal(keys[1].PublicKey.Bytes(), newKey.PublicKey().Bytes()) {
+		t.Fatalf("Wrong public key after rotation")
+	}
+
+	// A match that was already recorded returns the stored hit with its review status.
+	hit := &ScreeningHit{
+		ID:           uuid.New().String(),
+		UserID:       user.ID,
+		Field:        "Name",
+		ScreenedName: "Test Entity Name",
+		ListName:     "test",
+		EntryID:      "1",
+		EntryName:    "Test Entity",
+		MatchedName:  "Test Entity",
+		Score:        0.9,
+		Status:       ScreeningPending,
+		DateCreated:  now,
+	}
+
+	if _, inserted, err := store.InsertScreeningHit(ctx, hit); err != nil || !inserted {
+		t.Fatalf("Failed to insert screening hit : %t %v", inserted, err)
+	}
+
+	if _, err := ReviewScreeningHit(ctx, store, hit.ID, ScreeningDismissed, "test"); err != nil {
+		t.Fatalf("Failed to review screening hit : %s", err)
+	}
+
+	again := *hit
+	again.ID = uuid.New().String()
+	existing, inserted, err := store.InsertScreeningHit(ctx, &again)
+	if err != nil {
+		t.Fatalf("Failed to insert screening hit again : %s", err)
+	}
+
+	if inserted || existing.ID != hit.ID || existing.Status != ScreeningDismissed {
+		t.Fatalf("Wrong existing screening hit : inserted %t, %+v", inserted, existing)
+	}
+}
+
+// TestRehashFieldVerifications checks that verifications hashed before a master key was configured
+// are still found after they are rehashed.
+func TestRehashFieldVerifications(t *testing.T) {
+	ctx := tests.Context()
+
+	masterDB, err := db.New(&db.DBConfig{
+		Driver: db.DriverSQLite,
+		URL:    ":memory:",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open database : %s", err)
+	}
+	defer masterDB.Close()
+
+	if _, err := migrate.Up(ctx, masterDB); err != nil {
+		t.Fatalf("Failed to migrate database : %s", err)
+	}
+
+	store := NewDBStore(masterDB)
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	email := "test@tokenized.com"
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:         "Test Entity Name",
+		EmailAddress: email,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  now,
+		DateModified: now,
+	}
+
+	if err := CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	code, err := CreateFieldVerification(ctx, store, user.ID, "EmailAddress", email, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create verification : %s", err)
+	}
+
+	if _, err := ConfirmFieldVerification(ctx, store, nil, user.ID, "EmailAddress", email,
+		code); err != nil {
+		t.Fatalf("Failed to confirm verification : %s", err)
+	}
+
+	b := make([]byte, 32)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	masterKeys, err := encryption.NewMasterKeys(hex.EncodeToString(b), nil)
+	if err != nil {
+		t.Fatalf("Failed to create master keys : %s", err)
+	}
+	masterDB.SetMasterKeys(masterKeys)
+
+	verified, err := IsFieldVerified(ctx, store, user.ID, "EmailAddress", email)
+	if err != nil {
+		t.Fatalf("Failed to check verification : %s", err)
+	}
+
+	if verified {
+		t.Fatalf("Unkeyed hash should not match before rehash")
+	}
+
+	count, err := RehashFieldVerifications(ctx, store)
+	if err != nil {
+		t.Fatalf("Failed to rehash verifications : %s", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("Wrong rehash count : got %d, want 1", count)
+	}
+
+	verified, err = IsFieldVerified(ctx, store, user.ID, "EmailAddress", email)
+	if err != nil {
+		t.Fatalf("Failed to check verification : %s", err)
+	}
+
+	if !verified {
+		t.Fatalf("Email should be verified after rehash")
+	}
+}
+
+// TestVerificationAttempts checks that a verification can't be confirmed once its attempts are used
+// up, even with the right code.
+func TestVerificationAttempts(t *testing.T) {
+	ctx := tests.Context()
+
+	masterDB, err := db.New(&db.DBConfig{
+		Driver: db.DriverSQLite,
+		URL:    ":memory:",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to open database : %s", err)
+	}
+	defer masterDB.Close()
+
+	if _, err := migrate.Up(ctx, masterDB); err != nil {
+		t.Fatalf("Failed to migrate database : %s", err)
+	}
+
+	store := NewDBStore(masterDB)
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user key : %s", err)
+	}
+
+	phone := "+61299990000"
+	entityBytes, err := proto.Marshal(&actions.EntityField{
+		Name:        "Test Entity Name",
+		PhoneNumber: phone,
+	})
+	if err != nil {
+		t.Fatalf("Failed to serialize user entity : %s", err)
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:           uuid.New().String(),
+		Entity:       entityBytes,
+		PublicKey:    key.PublicKey(),
+		DateCreated:  now,
+		DateModified: now,
+	}
+
+	if err := CreateUser(ctx, store, user); err != nil {
+		t.Fatalf("Failed to create user : %s", err)
+	}
+
+	code, err := CreateFieldVerification(ctx, store, user.ID, "PhoneNumber", phone, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create verification : %s", err)
+	}
+
+	for i := 0; i < MaxVerificationAttempts; i++ {
+		if _, err := ConfirmFieldVerification(ctx, store, nil, user.ID, "PhoneNumber", phone,
+			"wrong"); errors.Cause(err) != ErrInvalidVerificationCode {
+			t.Fatalf("Wrong code should be invalid : %v", err)
+		}
+	}
+
+	if _, err := ConfirmFieldVerification(ctx, store, nil, user.ID, "PhoneNumber", phone,
+		code); errors.Cause(err) != ErrVerificationAttempts {
+		t.Fatalf("Code should be rejected after too many attempts : %v", err)
+	}
+}
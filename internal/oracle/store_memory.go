@@ -0,0 +1,991 @@
+package oracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryStore is a Store that keeps everything in memory. It is for tests and development since
+// nothing is kept when the process exits.
+type MemoryStore struct {
+	users              map[string]*User
+	publicKeys         []*PublicKey
+	userEntities       []*UserEntity
+	xpubs              []*XPub
+	fieldVerifications []*FieldVerification
+	attributes         []*UserAttribute
+	requirements       []*InstrumentRequirement
+	documents          []*Document
+	documentContent    map[string][]byte
+	screeningHits      []*ScreeningHit
+	fingerprints       map[string]map[string][]byte
+	duplicates         []*DuplicateIdentity
+	apiKeys            []*memoryAPIKey
+
+	lock sync.Mutex
+}
+
+type memoryAPIKey struct {
+	apiKey  *APIKey
+	keyHash []byte
+}
+
+// NewMemoryStore creates an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:           make(map[string]*User),
+		documentContent: make(map[string][]byte),
+		fingerprints:    make(map[string]map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) HashValue(value []byte) []byte {
+	hash := sha256.Sum256(value)
+	return hash[:]
+}
+
+func (s *MemoryStore) PreviousValueHashes(value []byte) [][]byte {
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Users
+
+func (s *MemoryStore) InsertUser(ctx context.Context, user *User) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, exists := s.users[user.ID]; exists {
+		return errors.Errorf("duplicate user id %s", user.ID)
+	}
+
+	c := *user
+	s.users[user.ID] = &c
+	s.publicKeys = append(s.publicKeys, &PublicKey{
+		UserID:      user.ID,
+		PublicKey:   user.PublicKey,
+		DateCreated: user.DateCreated,
+	})
+	return nil
+}
+
+func (s *MemoryStore) FetchUser(ctx context.Context, id string) (*User, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	user, exists := s.users[id]
+	if !exists || user.IsDeleted {
+		return nil, errors.Wrap(ErrUserNotFound, id)
+	}
+
+	c := *user
+	return &c, nil
+}
+
+func (s *MemoryStore) FetchUserByXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*User,
+	error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, xpub := range s.xpubs {
+		if !xpub.XPub.Equal(xpubs) {
+			continue
+		}
+
+		user, exists := s.users[xpub.UserID]
+		if !exists || user.IsDeleted {
+			continue
+		}
+
+		c := *user
+		return &c, nil
+	}
+
+	return nil, errors.Wrap(ErrXPubNotFound, xpubs.String())
+}
+
+func (s *MemoryStore) FetchUserIDs(ctx context.Context) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []string
+	for _, user := range s.sortedUsers() {
+		result = append(result, user.ID)
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchUsersExpiringBefore(ctx context.Context, before time.Time) ([]*User,
+	error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*User
+	for _, user := range s.sortedUsers() {
+		if user.DateIdentityExpires == nil || !user.DateIdentityExpires.Before(before) ||
+			user.DateExpiryNotified != nil {
+			continue
+		}
+
+		c := *user
+		result = append(result, &c)
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, user *User) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing, exists := s.users[user.ID]; exists {
+		existing.Entity = user.Entity
+		existing.DateModified = user.DateModified
+		existing.DateIdentityExpires = user.DateIdentityExpires
+		existing.DateExpiryNotified = user.DateExpiryNotified
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetVerificationLevel(ctx context.Context, userID string, level int,
+	now time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if user, exists := s.users[userID]; exists {
+		user.VerificationLevel = level
+		user.DateModified = now
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetIdentityExpiry(ctx context.Context, userID string,
+	expires *time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if user, exists := s.users[userID]; exists {
+		user.DateIdentityExpires = expires
+		user.DateExpiryNotified = nil
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetExpiryNotified(ctx context.Context, userID string,
+	notified time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if user, exists := s.users[userID]; exists {
+		user.DateExpiryNotified = &notified
+	}
+
+	return nil
+}
+
+// sortedUsers returns the users that haven't been deleted, oldest first. The lock must be held.
+func (s *MemoryStore) sortedUsers() []*User {
+	var result []*User
+	for _, user := range s.users {
+		if !user.IsDeleted {
+			result = append(result, user)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DateCreated.Equal(result[j].DateCreated) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result
+}
+
+// -------------------------------------------------------------------------------------------------
+// Public Keys
+
+func (s *MemoryStore) FetchPublicKeys(ctx context.Context, userID string) ([]*PublicKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*PublicKey
+	for _, publicKey := range s.publicKeys {
+		if publicKey.UserID == userID {
+			c := *publicKey
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchPublicKeyAt(ctx context.Context, userID string,
+	at time.Time) (*PublicKey, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result *PublicKey
+	for _, publicKey := range s.publicKeys {
+		if publicKey.UserID != userID || publicKey.DateCreated.After(at) ||
+			(publicKey.DateRevoked != nil && !publicKey.DateRevoked.After(at)) {
+			continue
+		}
+
+		if result == nil || publicKey.DateCreated.After(result.DateCreated) {
+			result = publicKey
+		}
+	}
+
+	if result == nil {
+		return nil, errors.Wrap(ErrPublicKeyNotFound, userID)
+	}
+
+	c := *result
+	return &c, nil
+}
+
+func (s *MemoryStore) RotatePublicKey(ctx context.Context, user *User, newKey bitcoin.PublicKey,
+	reason string, now time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, publicKey := range s.publicKeys {
+		if publicKey.UserID == user.ID && publicKey.DateRevoked == nil &&
+			bytes.Equal(publicKey.PublicKey.Bytes(), user.PublicKey.Bytes()) {
+			revoked := now
+			publicKey.DateRevoked = &revoked
+			publicKey.RevokeReason = reason
+		}
+	}
+
+	s.publicKeys = append(s.publicKeys, &PublicKey{
+		UserID:      user.ID,
+		PublicKey:   newKey,
+		DateCreated: now,
+	})
+
+	if existing, exists := s.users[user.ID]; exists {
+		existing.PublicKey = newKey
+		existing.DateModified = now
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// User Entities
+
+func (s *MemoryStore) InsertUserEntity(ctx context.Context, userEntity *UserEntity) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := *userEntity
+	s.userEntities = append(s.userEntities, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchUserEntities(ctx context.Context, userID string) ([]*UserEntity,
+	error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*UserEntity
+	for _, userEntity := range s.userEntities {
+		if userEntity.UserID == userID {
+			c := *userEntity
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchUserEntityAt(ctx context.Context, userID string,
+	at time.Time) (*UserEntity, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result *UserEntity
+	for _, userEntity := range s.userEntities {
+		if userEntity.UserID != userID || !userEntity.Approved ||
+			userEntity.DateCreated.After(at) {
+			continue
+		}
+
+		if result == nil || !userEntity.DateCreated.Before(result.DateCreated) {
+			result = userEntity
+		}
+	}
+
+	if result == nil {
+		return nil, errors.Wrap(ErrUserEntityNotFound, userID)
+	}
+
+	c := *result
+	return &c, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// XPubs
+
+func (s *MemoryStore) InsertXPub(ctx context.Context, xpub *XPub) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.xpubs {
+		if existing.UserID == xpub.UserID && existing.XPub.Equal(xpub.XPub) {
+			return nil
+		}
+	}
+
+	c := *xpub
+	s.xpubs = append(s.xpubs, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchXPub(ctx context.Context, xpubs bitcoin.ExtendedKeys) (*XPub, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, xpub := range s.xpubs {
+		if xpub.XPub.Equal(xpubs) {
+			c := *xpub
+			return &c, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrXPubNotFound, xpubs.String())
+}
+
+func (s *MemoryStore) FetchXPubsByUser(ctx context.Context, userID string) ([]*XPub, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*XPub
+	for _, xpub := range s.xpubs {
+		if xpub.UserID == userID {
+			c := *xpub
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Field Verifications
+
+func (s *MemoryStore) InsertFieldVerification(ctx context.Context,
+	verification *FieldVerification) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := *verification
+	s.fieldVerifications = append(s.fieldVerifications, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchFieldVerifications(ctx context.Context,
+	userID string) ([]*FieldVerification, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*FieldVerification
+	for _, verification := range s.fieldVerifications {
+		if verification.UserID == userID {
+			c := *verification
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchFieldVerificationsByValue(ctx context.Context, userID, field string,
+	valueHash []byte) ([]*FieldVerification, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*FieldVerification
+	for _, verification := range s.fieldVerifications {
+		if (len(userID) == 0 || verification.UserID == userID) && verification.Field == field &&
+			bytes.Equal(verification.ValueHash, valueHash) {
+			c := *verification
+			result = append(result, &c)
+		}
+	}
+
+	// Newest first. Verifications inserted at the same time keep the latest inserted first.
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.After(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) SetVerificationValueHash(ctx context.Context, id string,
+	valueHash []byte) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, verification := range s.fieldVerifications {
+		if verification.ID == id {
+			verification.ValueHash = append([]byte(nil), valueHash...)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) IncrementVerificationAttempts(ctx context.Context, id string,
+	maxAttempts int) (bool, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, verification := range s.fieldVerifications {
+		if verification.ID == id && verification.Attempts < maxAttempts {
+			verification.Attempts++
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *MemoryStore) MarkFieldVerified(ctx context.Context, id string, verified time.Time,
+	validUntil *time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, verification := range s.fieldVerifications {
+		if verification.ID == id {
+			verification.DateVerified = &verified
+			verification.DateValidUntil = validUntil
+		}
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Attributes and Requirements
+
+func (s *MemoryStore) SetUserAttribute(ctx context.Context, attribute *UserAttribute) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := *attribute
+	for i, existing := range s.attributes {
+		if existing.UserID == attribute.UserID && existing.Attribute == attribute.Attribute {
+			s.attributes[i] = &c
+			return nil
+		}
+	}
+
+	s.attributes = append(s.attributes, &c)
+	return nil
+}
+
+func (s *MemoryStore) RemoveUserAttribute(ctx context.Context, userID, attribute string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, existing := range s.attributes {
+		if existing.UserID == userID && existing.Attribute == attribute {
+			s.attributes = append(s.attributes[:i], s.attributes[i+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) FetchUserAttributes(ctx context.Context, userID string,
+	now time.Time) ([]*UserAttribute, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*UserAttribute
+	for _, attribute := range s.attributes {
+		if attribute.UserID == userID &&
+			(attribute.DateExpires == nil || attribute.DateExpires.After(now)) {
+			c := *attribute
+			result = append(result, &c)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Attribute < result[j].Attribute
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) SetInstrumentRequirement(ctx context.Context,
+	requirement *InstrumentRequirement) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := *requirement
+	for i, existing := range s.requirements {
+		if existing.Contract == requirement.Contract &&
+			existing.InstrumentID == requirement.InstrumentID {
+			s.requirements[i] = &c
+			return nil
+		}
+	}
+
+	s.requirements = append(s.requirements, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchInstrumentRequirement(ctx context.Context, contract,
+	instrumentID string) (*InstrumentRequirement, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, requirement := range s.requirements {
+		if requirement.Contract == contract && requirement.InstrumentID == instrumentID {
+			c := *requirement
+			return &c, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Documents
+
+func (s *MemoryStore) InsertDocument(ctx context.Context, document *Document,
+	content []byte) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.documentContent[document.StorageKey] = append([]byte(nil), content...)
+
+	c := *document
+	s.documents = append(s.documents, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchDocument(ctx context.Context, id string) (*Document, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, document := range s.documents {
+		if document.ID == id {
+			c := *document
+			return &c, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrDocumentNotFound, id)
+}
+
+func (s *MemoryStore) FetchDocuments(ctx context.Context, userID string) ([]*Document, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*Document
+	for _, document := range s.documents {
+		if document.UserID == userID {
+			c := *document
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) ReadDocumentContent(ctx context.Context, document *Document) ([]byte,
+	error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	content, exists := s.documentContent[document.StorageKey]
+	if !exists {
+		return nil, errors.Wrap(ErrDocumentNotFound, document.StorageKey)
+	}
+
+	return append([]byte(nil), content...), nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Screening
+
+func (s *MemoryStore) InsertScreeningHit(ctx context.Context,
+	hit *ScreeningHit) (*ScreeningHit, bool, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.screeningHits {
+		if existing.UserID == hit.UserID && existing.ScreenedName == hit.ScreenedName &&
+			existing.ListName == hit.ListName && existing.EntryID == hit.EntryID {
+			c := *existing
+			return &c, false, nil
+		}
+	}
+
+	c := *hit
+	s.screeningHits = append(s.screeningHits, &c)
+
+	result := *hit
+	return &result, true, nil
+}
+
+func (s *MemoryStore) FetchScreeningHits(ctx context.Context, userID,
+	status string) ([]*ScreeningHit, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*ScreeningHit
+	for _, hit := range s.screeningHits {
+		if (len(userID) == 0 || hit.UserID == userID) &&
+			(len(status) == 0 || hit.Status == status) {
+			c := *hit
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchScreeningHit(ctx context.Context, id string) (*ScreeningHit, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, hit := range s.screeningHits {
+		if hit.ID == id {
+			c := *hit
+			return &c, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrScreeningHitNotFound, id)
+}
+
+func (s *MemoryStore) UpdateScreeningHit(ctx context.Context, hit *ScreeningHit) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.screeningHits {
+		if existing.ID == hit.ID {
+			existing.Status = hit.Status
+			existing.Note = hit.Note
+			existing.DateReviewed = hit.DateReviewed
+		}
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// Duplicates
+
+func (s *MemoryStore) SetFingerprints(ctx context.Context, userID string,
+	fingerprints map[string][]byte, now time.Time) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := make(map[string][]byte)
+	for kind, fingerprint := range fingerprints {
+		c[kind] = fingerprint
+	}
+	s.fingerprints[userID] = c
+	return nil
+}
+
+func (s *MemoryStore) FetchFingerprintUserIDs(ctx context.Context, kind string,
+	fingerprint []byte, excludeUserID string) ([]string, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []string
+	for _, user := range s.sortedUsers() {
+		if user.ID == excludeUserID {
+			continue
+		}
+
+		if bytes.Equal(s.fingerprints[user.ID][kind], fingerprint) {
+			result = append(result, user.ID)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryStore) InsertDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.duplicates {
+		if existing.UserID == duplicate.UserID &&
+			existing.DuplicateUserID == duplicate.DuplicateUserID &&
+			existing.Kind == duplicate.Kind {
+			return nil
+		}
+	}
+
+	c := *duplicate
+	s.duplicates = append(s.duplicates, &c)
+	return nil
+}
+
+func (s *MemoryStore) FetchDuplicates(ctx context.Context, userID,
+	status string) ([]*DuplicateIdentity, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*DuplicateIdentity
+	for _, duplicate := range s.duplicates {
+		if (len(userID) == 0 || duplicate.UserID == userID ||
+			duplicate.DuplicateUserID == userID) &&
+			(len(status) == 0 || duplicate.Status == status) {
+			c := *duplicate
+			result = append(result, &c)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) FetchDuplicate(ctx context.Context, id string) (*DuplicateIdentity,
+	error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, duplicate := range s.duplicates {
+		if duplicate.ID == id {
+			c := *duplicate
+			return &c, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrDuplicateNotFound, id)
+}
+
+func (s *MemoryStore) UpdateDuplicate(ctx context.Context, duplicate *DuplicateIdentity) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.updateDuplicate(duplicate)
+	return nil
+}
+
+// updateDuplicate updates the review of a duplicate. The lock must be held.
+func (s *MemoryStore) updateDuplicate(duplicate *DuplicateIdentity) {
+	for _, existing := range s.duplicates {
+		if existing.ID == duplicate.ID {
+			existing.Status = duplicate.Status
+			existing.Note = duplicate.Note
+			existing.DateReviewed = duplicate.DateReviewed
+		}
+	}
+}
+
+func (s *MemoryStore) MergeUsers(ctx context.Context, keepUserID, mergeUserID string,
+	duplicate *DuplicateIdentity) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var kept []bitcoin.ExtendedKeys
+	for _, xpub := range s.xpubs {
+		if xpub.UserID == keepUserID {
+			kept = append(kept, xpub.XPub)
+		}
+	}
+
+	// Xpubs the kept user already has are left with the merged user.
+	for _, xpub := range s.xpubs {
+		if xpub.UserID != mergeUserID {
+			continue
+		}
+
+		found := false
+		for _, keptXPub := range kept {
+			if keptXPub.Equal(xpub.XPub) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			xpub.UserID = keepUserID
+		}
+	}
+
+	if user, exists := s.users[mergeUserID]; exists {
+		user.IsDeleted = true
+		if duplicate.DateReviewed != nil {
+			user.DateModified = *duplicate.DateReviewed
+		}
+	}
+
+	delete(s.fingerprints, mergeUserID)
+	s.updateDuplicate(duplicate)
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------------
+// API Keys
+
+func (s *MemoryStore) InsertAPIKey(ctx context.Context, apiKey *APIKey, keyHash []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if bytes.Equal(existing.keyHash, keyHash) {
+			return errors.Errorf("duplicate api key hash")
+		}
+	}
+
+	c := *apiKey
+	s.apiKeys = append(s.apiKeys, &memoryAPIKey{
+		apiKey:  &c,
+		keyHash: keyHash,
+	})
+	return nil
+}
+
+func (s *MemoryStore) FetchAPIKeyByHash(ctx context.Context, keyHash []byte) (*APIKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.apiKey.DateRevoked == nil && bytes.Equal(existing.keyHash, keyHash) {
+			c := *existing.apiKey
+			return &c, nil
+		}
+	}
+
+	return nil, ErrAPIKeyNotFound
+}
+
+func (s *MemoryStore) FetchAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.apiKey.DateRevoked == nil && existing.apiKey.ID == id {
+			c := *existing.apiKey
+			return &c, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrAPIKeyNotFound, id)
+}
+
+func (s *MemoryStore) FetchAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []*APIKey
+	for _, existing := range s.apiKeys {
+		c := *existing.apiKey
+		result = append(result, &c)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DateCreated.Before(result[j].DateCreated)
+	})
+
+	return result, nil
+}
+
+func (s *MemoryStore) SetAPIKeyLastUsed(ctx context.Context, id string, used time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.apiKey.ID == id {
+			existing.apiKey.DateLastUsed = &used
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) RevokeAPIKey(ctx context.Context, id string, revoked time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.apiKey.ID == id {
+			existing.apiKey.DateRevoked = &revoked
+		}
+	}
+
+	return nil
+}
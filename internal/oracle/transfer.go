@@ -3,7 +3,6 @@ package oracle
 import (
 	"context"
 
-	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/logger"
 	"github.com/tokenized/specification/dist/golang/protocol"
@@ -17,7 +16,7 @@ import (
 //   uint32 - block height of block hash included in signature hash
 //   bitcoin.Hash32 - block hash included in signature hash
 //   bool - true if transfer is approved
-func CreateReceiveSignature(ctx context.Context, dbConn *db.DB, headers Headers,
+func CreateReceiveSignature(ctx context.Context, store Store, headers Headers,
 	net bitcoin.Network, contract, instrument string, xpubs bitcoin.ExtendedKeys, index uint32,
 	expiration uint64, approved bool) (*bitcoin.Hash32, uint32, bitcoin.Hash32, error) {
 
@@ -28,7 +27,7 @@ func CreateReceiveSignature(ctx context.Context, dbConn *db.DB, headers Headers,
 
 	// TODO Get contract and instrument
 
-	xpubData, err := FetchXPubByXPub(ctx, dbConn, xpubs)
+	xpubData, err := FetchXPubByXPub(ctx, store, xpubs)
 	if err != nil {
 		return nil, 0, bitcoin.Hash32{}, errors.Wrap(err, "fetch xpub")
 	}
@@ -0,0 +1,32 @@
+package oracle
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateUserEntity inserts an identity submission into a user's identity history. Rejected
+// submissions are recorded too so the history shows everything the user has claimed.
+func CreateUserEntity(ctx context.Context, store Store, userEntity *UserEntity) error {
+	userEntity.ID = uuid.New().String()
+	if userEntity.DateCreated.IsZero() {
+		userEntity.DateCreated = time.Now()
+	}
+
+	return store.InsertUserEntity(ctx, userEntity)
+}
+
+// FetchUserEntities returns the identity history of a user, oldest first.
+func FetchUserEntities(ctx context.Context, store Store, userID string) ([]*UserEntity, error) {
+	return store.FetchUserEntities(ctx, userID)
+}
+
+// FetchUserEntityAt returns the approved identity the oracle held for a user at the specified
+// time.
+func FetchUserEntityAt(ctx context.Context, store Store, userID string,
+	at time.Time) (*UserEntity, error) {
+
+	return store.FetchUserEntityAt(ctx, userID, at)
+}
@@ -0,0 +1,210 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/tokenized/specification/dist/golang/actions"
+)
+
+// InvalidField is a field with an invalid format.
+type InvalidField struct {
+	Field  string
+	Reason string
+}
+
+// InvalidFields is the error returned when fields have invalid formats.
+type InvalidFields []InvalidField
+
+// Error implements the error interface for InvalidFields.
+func (err InvalidFields) Error() string {
+	var str string
+	for _, v := range err {
+		str = fmt.Sprintf("%s,{%s:%s}", str, v.Field, v.Reason)
+	}
+	return str
+}
+
+// ValidateEntity checks the format of the entity's fields. It returns InvalidFields listing each
+// invalid field, or nil if all of the fields are valid. Empty fields are not checked.
+func ValidateEntity(entity *actions.EntityField) error {
+	var result InvalidFields
+
+	invalid := func(field, reason string) {
+		result = append(result, InvalidField{Field: "entity." + field, Reason: reason})
+	}
+
+	if len(entity.Type) != 0 && actions.EntitiesData(entity.Type) == nil {
+		invalid("Type", "unknown entity type")
+	}
+
+	if len(entity.LEI) != 0 && !IsValidLEI(entity.LEI) {
+		invalid("LEI", "invalid ISO 17442 legal entity identifier")
+	}
+
+	entityCountry, countryOk := lookupCountry(entity.CountryCode)
+	if len(entity.CountryCode) != 0 && !countryOk {
+		invalid("CountryCode", "unknown ISO 3166 country code")
+	}
+
+	if len(entity.TerritoryStateProvinceCode) != 0 &&
+		!isValidSubdivision(entity.TerritoryStateProvinceCode, entityCountry) {
+		invalid("TerritoryStateProvinceCode", "invalid ISO 3166-2 subdivision code")
+	}
+
+	if len(entity.EmailAddress) != 0 && !IsValidEmailAddress(entity.EmailAddress) {
+		invalid("EmailAddress", "invalid email address")
+	}
+
+	if len(entity.PhoneNumber) != 0 && !IsValidPhoneNumber(entity.PhoneNumber) {
+		invalid("PhoneNumber", "invalid phone number")
+	}
+
+	if len(entity.DomainName) != 0 && !IsValidDomainName(entity.DomainName) {
+		invalid("DomainName", "invalid domain name")
+	}
+
+	if len(result) != 0 {
+		return result
+	}
+
+	return nil
+}
+
+// IsValidLEI returns true if the value is a 20 character legal entity identifier with valid
+// ISO 17442 (ISO 7064 MOD 97-10) check digits.
+func IsValidLEI(lei string) bool {
+	lei = strings.ToUpper(strings.TrimSpace(lei))
+	if len(lei) != 20 {
+		return false
+	}
+
+	var digits strings.Builder
+	for i, r := range lei {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z' && i < 18:
+			// Letters are converted to two digit numbers. A = 10, B = 11, ...
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		default:
+			return false // check digits must be numeric
+		}
+	}
+
+	value, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return false
+	}
+
+	return new(big.Int).Mod(value, big.NewInt(97)).Int64() == 1
+}
+
+// IsValidEmailAddress returns true if the value is a single bare email address with a domain.
+func IsValidEmailAddress(email string) bool {
+	email = strings.TrimSpace(email)
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return false
+	}
+
+	at := strings.LastIndex(email, "@")
+	return at > 0 && IsValidDomainName(email[at+1:])
+}
+
+// IsValidPhoneNumber returns true if the value contains only phone number characters and between
+// 6 and 15 digits, the maximum length of an E.164 number.
+func IsValidPhoneNumber(phone string) bool {
+	phone = strings.TrimSpace(phone)
+
+	digitCount := 0
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digitCount++
+		case r == '+' && i == 0:
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+		default:
+			return false
+		}
+	}
+
+	return digitCount >= 6 && digitCount <= 15
+}
+
+// IsValidDomainName returns true if the value is a fully qualified host name with at least two
+// labels of letters, digits and hyphens.
+func IsValidDomainName(domain string) bool {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if len(domain) == 0 || len(domain) > 253 {
+		return false
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') &&
+				r != '-' {
+				return false
+			}
+		}
+	}
+
+	// The top level domain can't be all numeric.
+	for _, r := range labels[len(labels)-1] {
+		if r < '0' || r > '9' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isValidSubdivision returns true if the value has the format of an ISO 3166-2 subdivision code.
+// The code can be the full code ("AU-NSW") or just the subdivision part ("NSW"). When the country
+// is known the country part of a full code must match it.
+func isValidSubdivision(code string, entityCountry *country) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	subdivision := code
+	if dash := strings.Index(code, "-"); dash != -1 {
+		c, ok := lookupCountry(code[:dash])
+		if !ok || dash != 2 {
+			return false
+		}
+
+		if entityCountry != nil && c.Alpha2 != entityCountry.Alpha2 {
+			return false
+		}
+
+		subdivision = code[dash+1:]
+	}
+
+	if len(subdivision) == 0 || len(subdivision) > 3 {
+		return false
+	}
+
+	for _, r := range subdivision {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,101 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/tokenized/specification/dist/golang/actions"
+)
+
+func TestValidateEntity(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity *actions.EntityField
+		fields []string
+	}{
+		{
+			name: "Valid",
+			entity: &actions.EntityField{
+				Name:                       "Tokenized",
+				LEI:                        "5493001KJTIIGC8Y1R12",
+				CountryCode:                "AUS",
+				TerritoryStateProvinceCode: "AU-NSW",
+				EmailAddress:               "info@tokenized.com",
+				PhoneNumber:                "+61 2 9999 0000",
+				DomainName:                 "tokenized.com",
+			},
+		},
+		{
+			name: "Empty",
+			entity: &actions.EntityField{
+				Name: "Tokenized",
+			},
+		},
+		{
+			name: "Invalid LEI check digits",
+			entity: &actions.EntityField{
+				LEI: "5493001KJTIIGC8Y1R13",
+			},
+			fields: []string{"entity.LEI"},
+		},
+		{
+			name: "Invalid country",
+			entity: &actions.EntityField{
+				CountryCode: "XYZ",
+			},
+			fields: []string{"entity.CountryCode"},
+		},
+		{
+			name: "Subdivision wrong country",
+			entity: &actions.EntityField{
+				CountryCode:                "AUS",
+				TerritoryStateProvinceCode: "US-CA",
+			},
+			fields: []string{"entity.TerritoryStateProvinceCode"},
+		},
+		{
+			name: "Invalid contact",
+			entity: &actions.EntityField{
+				EmailAddress: "Tokenized <info@tokenized.com>",
+				PhoneNumber:  "call me",
+				DomainName:   "localhost",
+			},
+			fields: []string{"entity.EmailAddress", "entity.PhoneNumber", "entity.DomainName"},
+		},
+		{
+			name: "Unknown type",
+			entity: &actions.EntityField{
+				Type: "?",
+			},
+			fields: []string{"entity.Type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEntity(tt.entity)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Failed to validate entity : %s", err)
+				}
+				return
+			}
+
+			invalid, ok := errors.Cause(err).(InvalidFields)
+			if !ok {
+				t.Fatalf("Wrong error type : %v", err)
+			}
+
+			if len(invalid) != len(tt.fields) {
+				t.Fatalf("Wrong invalid field count : got %d, want %d : %s", len(invalid),
+					len(tt.fields), invalid.Error())
+			}
+
+			for i, field := range tt.fields {
+				if invalid[i].Field != field {
+					t.Errorf("Wrong invalid field %d : got %s, want %s", i, invalid[i].Field, field)
+				}
+			}
+		})
+	}
+}
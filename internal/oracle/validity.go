@@ -0,0 +1,164 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/mail"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/specification/dist/golang/actions"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// Validity is how long identities and field verifications stay valid before the user must be
+// verified again. A zero duration never expires. A nil Validity never expires anything.
+type Validity struct {
+	// Identity is how long an approved identity is valid.
+	Identity time.Duration
+
+	// Fields is how long a verification of each field, like "EmailAddress", is valid.
+	Fields map[string]time.Duration
+}
+
+// NewValidity creates a validity from the configured durations. fields is keyed by verifiable
+// field name with values parsed by time.ParseDuration.
+func NewValidity(identity time.Duration, fields map[string]string) (*Validity, error) {
+	result := &Validity{
+		Identity: identity,
+		Fields:   make(map[string]time.Duration),
+	}
+
+	for field, s := range fields {
+		if !isVerifiableField(field) {
+			return nil, errors.Wrap(ErrUnknownField, field)
+		}
+
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errors.Wrap(err, field)
+		}
+
+		result.Fields[field] = duration
+	}
+
+	return result, nil
+}
+
+// IdentityExpiry returns when an identity approved at the time specified expires, or nil if it
+// doesn't expire.
+func (v *Validity) IdentityExpiry(now time.Time) *time.Time {
+	if v == nil || v.Identity == 0 {
+		return nil
+	}
+
+	result := now.Add(v.Identity)
+	return &result
+}
+
+// FieldExpiry returns when a verification of the field completed at the time specified expires,
+// or nil if it doesn't expire.
+func (v *Validity) FieldExpiry(field string, now time.Time) *time.Time {
+	if v == nil {
+		return nil
+	}
+
+	duration, exists := v.Fields[field]
+	if !exists || duration == 0 {
+		return nil
+	}
+
+	result := now.Add(duration)
+	return &result
+}
+
+// CheckIdentityExpiry returns a mismatch if the user's identity has expired and must be verified
+// again.
+func CheckIdentityExpiry(user *User, now time.Time) EntityMismatches {
+	if user.DateIdentityExpires == nil || now.Before(*user.DateIdentityExpires) {
+		return nil
+	}
+
+	return EntityMismatches{
+		EntityMismatch{
+			Code: MismatchExpired,
+			Description: fmt.Sprintf("identity expired %s, re-verification required",
+				user.DateIdentityExpires.UTC().Format(time.RFC3339)),
+		},
+	}
+}
+
+// RenewIdentity restarts the validity period of a user's identity after they have been verified
+// again.
+func RenewIdentity(ctx context.Context, store Store, validity *Validity, userID string,
+	now time.Time) (*time.Time, error) {
+
+	if _, err := FetchUser(ctx, store, userID); err != nil {
+		return nil, errors.Wrap(err, "fetch user")
+	}
+
+	expires := validity.IdentityExpiry(now)
+	if err := store.SetIdentityExpiry(ctx, userID, expires); err != nil {
+		return nil, err
+	}
+
+	return expires, nil
+}
+
+// FetchUsersExpiringBefore returns the users whose identities expire before the time specified and
+// who haven't been notified of it since their identity was last renewed.
+func FetchUsersExpiringBefore(ctx context.Context, store Store,
+	before time.Time) ([]*User, error) {
+
+	return store.FetchUsersExpiringBefore(ctx, before)
+}
+
+// NotifyExpiringIdentities emails each user whose identity expires within the window so they can
+// be verified again before transfers to them are denied. Users without an email address are only
+// logged. Returns the number of users notified.
+func NotifyExpiringIdentities(ctx context.Context, store Store, mailer mail.Mailer,
+	window time.Duration) (int, error) {
+
+	now := time.Now()
+	users, err := FetchUsersExpiringBefore(ctx, store, now.Add(window))
+	if err != nil {
+		return 0, errors.Wrap(err, "fetch expiring users")
+	}
+
+	count := 0
+	for _, user := range users {
+		entity := &actions.EntityField{}
+		if err := proto.Unmarshal(user.Entity, entity); err != nil {
+			return count, errors.Wrap(err, "unmarshal user entity")
+		}
+
+		logger.InfoWithFields(ctx, []logger.Field{
+			logger.String("user_id", user.ID),
+			logger.String("expires", user.DateIdentityExpires.UTC().Format(time.RFC3339)),
+		}, "Identity expiring")
+
+		if mailer != nil && len(entity.EmailAddress) != 0 {
+			body := fmt.Sprintf("Your identity verification with the identity oracle expires "+
+				"on %s.\n\nPlease contact the identity oracle's operator before then to have "+
+				"your identity verified again so you can continue receiving transfers.\n",
+				user.DateIdentityExpires.UTC().Format("2 January 2006"))
+
+			if err := mailer.Send(ctx, entity.EmailAddress, "Identity verification expiring",
+				body); err != nil {
+				logger.Error(ctx, "Failed to send expiry notification to user %s : %s", user.ID,
+					err)
+				continue
+			}
+		}
+
+		if err := store.SetExpiryNotified(ctx, user.ID, now); err != nil {
+			return count, errors.Wrap(err, "update notified")
+		}
+
+		count++
+	}
+
+	return count, nil
+}
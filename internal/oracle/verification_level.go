@@ -0,0 +1,172 @@
+package oracle
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SetByAdmin and SetByApprover record who set a verification level or attribute.
+	SetByAdmin    = "admin"
+	SetByApprover = "approver"
+)
+
+// AttributeList is a list of attribute names stored as a comma separated string.
+type AttributeList []string
+
+// Value implements driver.Valuer.
+func (l AttributeList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *AttributeList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unsupported attribute list type %T", src)
+	}
+
+	*l = nil
+	for _, attribute := range strings.Split(s, ",") {
+		if len(attribute) != 0 {
+			*l = append(*l, attribute)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAttribute checks that an attribute name is lower case letters, digits, and underscores.
+func ValidateAttribute(attribute string) error {
+	if len(attribute) == 0 || len(attribute) > 64 {
+		return errors.Wrap(ErrInvalidAttribute, attribute)
+	}
+
+	for _, c := range attribute {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return errors.Wrap(ErrInvalidAttribute, attribute)
+		}
+	}
+
+	return nil
+}
+
+// SetVerificationLevel sets the KYC tier of a user.
+func SetVerificationLevel(ctx context.Context, store Store, userID string, level int) error {
+	if _, err := FetchUser(ctx, store, userID); err != nil {
+		return errors.Wrap(err, "fetch user")
+	}
+
+	return store.SetVerificationLevel(ctx, userID, level, time.Now())
+}
+
+// SetUserAttribute sets an attribute flag on a user, replacing its expiry if it is already set.
+func SetUserAttribute(ctx context.Context, store Store, attribute *UserAttribute) error {
+	if err := ValidateAttribute(attribute.Attribute); err != nil {
+		return err
+	}
+
+	if _, err := FetchUser(ctx, store, attribute.UserID); err != nil {
+		return errors.Wrap(err, "fetch user")
+	}
+
+	if attribute.DateCreated.IsZero() {
+		attribute.DateCreated = time.Now()
+	}
+
+	return store.SetUserAttribute(ctx, attribute)
+}
+
+// RemoveUserAttribute removes an attribute flag from a user.
+func RemoveUserAttribute(ctx context.Context, store Store, userID, attribute string) error {
+	return store.RemoveUserAttribute(ctx, userID, attribute)
+}
+
+// FetchUserAttributes returns the attribute flags of a user that haven't expired at the time
+// specified.
+func FetchUserAttributes(ctx context.Context, store Store, userID string,
+	now time.Time) ([]*UserAttribute, error) {
+
+	return store.FetchUserAttributes(ctx, userID, now)
+}
+
+// SetInstrumentRequirement sets the verification a user needs to receive an instrument.
+func SetInstrumentRequirement(ctx context.Context, store Store,
+	requirement *InstrumentRequirement) error {
+
+	for _, attribute := range requirement.RequiredAttributes {
+		if err := ValidateAttribute(attribute); err != nil {
+			return err
+		}
+	}
+
+	requirement.DateModified = time.Now()
+
+	return store.SetInstrumentRequirement(ctx, requirement)
+}
+
+// FetchInstrumentRequirement returns the verification a user needs to receive an instrument, or
+// nil if there is none.
+func FetchInstrumentRequirement(ctx context.Context, store Store, contract,
+	instrumentID string) (*InstrumentRequirement, error) {
+
+	return store.FetchInstrumentRequirement(ctx, contract, instrumentID)
+}
+
+// Check returns a description of the first part of the requirement the user doesn't meet, or an
+// empty string if it is met. attributes are the user's active attributes.
+func (r *InstrumentRequirement) Check(user *User, attributes []*UserAttribute) string {
+	if user.VerificationLevel < r.MinVerificationLevel {
+		return fmt.Sprintf("verification level %d below required %d", user.VerificationLevel,
+			r.MinVerificationLevel)
+	}
+
+	for _, required := range r.RequiredAttributes {
+		found := false
+		for _, attribute := range attributes {
+			if attribute.Attribute == required {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Sprintf("attribute %s required", required)
+		}
+	}
+
+	return ""
+}
+
+// CheckInstrumentRequirement returns a description of the first part of the instrument's
+// requirement the user doesn't meet, or an empty string if the user can receive the instrument.
+func CheckInstrumentRequirement(ctx context.Context, store Store, user *User, contract,
+	instrumentID string) (string, error) {
+
+	requirement, err := FetchInstrumentRequirement(ctx, store, contract, instrumentID)
+	if err != nil {
+		return "", errors.Wrap(err, "fetch requirement")
+	}
+
+	if requirement == nil {
+		return "", nil
+	}
+
+	attributes, err := FetchUserAttributes(ctx, store, user.ID, time.Now())
+	if err != nil {
+		return "", errors.Wrap(err, "fetch attributes")
+	}
+
+	return requirement.Check(user, attributes), nil
+}
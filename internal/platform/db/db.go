@@ -2,17 +2,21 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	sqldb "database/sql"
 	"fmt"
 	"reflect"
 	"strings"
 	"time"
 
+	"github.com/tokenized/identity-oracle/internal/platform/encryption"
+	"github.com/tokenized/identity-oracle/internal/platform/metrics"
 	"github.com/tokenized/pkg/storage"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 )
@@ -26,15 +30,33 @@ var (
 	ErrNotFound = errors.New("Entity not found")
 )
 
-// DB is a collection of support for different DB technologies. Currently
-// only PgSql has been implemented. We want to be able to access the raw
-// database support for the given DB so an interface does not work. Each
-// database is too different.
+const (
+	// DriverPostgres is the driver of PostgreSQL databases.
+	DriverPostgres = "postgres"
+
+	// DriverSQLite is the driver of SQLite databases, which are a local file. The URL is the file
+	// path, or a "file:" URI with options such as "?_foreign_keys=on&_busy_timeout=5000".
+	DriverSQLite = "sqlite3"
+)
+
+var (
+	queryDuration = metrics.NewHistogram("identity_oracle_db_query_duration_seconds",
+		"Database query latency by operation.", metrics.DefaultBuckets, "operation")
+	queryErrors = metrics.NewCounter("identity_oracle_db_query_errors_total",
+		"Failed database queries by operation.", "operation")
+)
+
+// DB is a collection of support for different DB technologies. PgSql and
+// SQLite are supported. We want to be able to access the raw database
+// support for the given DB so an interface does not work. Each database is
+// too different.
 type DB struct {
-	database  Database
-	storage   storage.Storage
-	session   Database
-	sessionTx DatabaseTx
+	driver     string
+	database   Database
+	storage    storage.Storage
+	session    Database
+	sessionTx  DatabaseTx
+	masterKeys *encryption.MasterKeys
 }
 
 // StorageConfig is geared towards "bucket" style storage, where you have a
@@ -50,23 +72,37 @@ type DBConfig struct {
 	URL    string
 }
 
-// New returns a new DB value for use with PgSql based on a registered
-// master session.
+// New returns a new DB value for use with PgSql or SQLite based on a
+// registered master session.
 func New(dbc *DBConfig, sc *StorageConfig) (*DB, error) {
 
-	// PostgreSQL Database
+	// Relational Database
 	var newDB Database
+	var driver string
 	if dbc != nil {
-		pgsql, err := sqlx.Connect(dbc.Driver, dbc.URL)
+		switch dbc.Driver {
+		case DriverPostgres, DriverSQLite:
+		default:
+			return nil, errors.Errorf("unsupported database driver %s", dbc.Driver)
+		}
+
+		sqlDB, err := sqlx.Connect(dbc.Driver, dbc.URL)
 		if err != nil {
 			return nil, err
 		}
 
-		if err = pgsql.Ping(); err != nil {
+		if dbc.Driver == DriverSQLite {
+			// SQLite allows one writer at a time so share one connection rather than have
+			// concurrent writes fail with "database is locked".
+			sqlDB.SetMaxOpenConns(1)
+		}
+
+		if err = sqlDB.Ping(); err != nil {
 			return nil, err
 		}
 
-		newDB = &db{pgsql}
+		newDB = &db{sqlDB}
+		driver = dbc.Driver
 	}
 
 	// S3 Storage
@@ -81,6 +117,7 @@ func New(dbc *DBConfig, sc *StorageConfig) (*DB, error) {
 	}
 
 	db := DB{
+		driver:    driver,
 		database:  newDB,
 		storage:   store,
 		session:   nil,
@@ -95,12 +132,26 @@ func (db *DB) StatusCheck(ctx context.Context) error {
 	ctx, span := trace.StartSpan(ctx, "platform.DB.StatusCheck")
 	defer span.End()
 
+	if err := db.DatabaseStatusCheck(ctx); err != nil {
+		return err
+	}
+
+	return db.StorageStatusCheck(ctx)
+}
+
+// DatabaseStatusCheck validates the database connection.
+func (db *DB) DatabaseStatusCheck(ctx context.Context) error {
 	if db.database != nil {
 		if err := db.database.Ping(); err != nil {
 			return err
 		}
 	}
 
+	return nil
+}
+
+// StorageStatusCheck validates the storage is reachable.
+func (db *DB) StorageStatusCheck(ctx context.Context) error {
 	if db.storage != nil {
 		// Generate a random key that is almost certain not to exist.
 		uid, _ := uuid.NewRandom()
@@ -135,10 +186,12 @@ func (db *DB) Close() {
 // set up the interface to allow support any generic database type.
 func (db *DB) Copy() *DB {
 	newDB := DB{
-		database:  db.database,
-		storage:   db.storage,
-		session:   db.database,
-		sessionTx: nil,
+		driver:     db.driver,
+		database:   db.database,
+		storage:    db.storage,
+		session:    db.database,
+		sessionTx:  nil,
+		masterKeys: db.masterKeys,
 	}
 
 	return &newDB
@@ -162,11 +215,75 @@ func (db *DB) SetStorage(storage storage.Storage) {
 	db.storage = storage
 }
 
+// Driver returns the driver of the database, or an empty string when there isn't a database.
+func (db *DB) Driver() string {
+	return db.driver
+}
+
 // GetStorage returns the storage value.
 func (db *DB) GetStorage() storage.Storage {
 	return db.storage
 }
 
+// SetMasterKeys sets the keys used to encrypt sensitive values at rest. When no keys are set
+// values are stored in plaintext.
+func (db *DB) SetMasterKeys(masterKeys *encryption.MasterKeys) {
+	db.masterKeys = masterKeys
+}
+
+// Encrypt encrypts a sensitive value before it is written to the database.
+func (db *DB) Encrypt(b []byte) ([]byte, error) {
+	if db.masterKeys == nil {
+		return b, nil
+	}
+
+	return db.masterKeys.Encrypt(b)
+}
+
+// Decrypt decrypts a sensitive value read from the database. Plaintext values are returned
+// unchanged.
+func (db *DB) Decrypt(b []byte) ([]byte, error) {
+	if db.masterKeys == nil {
+		if encryption.IsEncrypted(b) {
+			return nil, errors.Wrap(encryption.ErrUnknownKey, "no master keys")
+		}
+		return b, nil
+	}
+
+	return db.masterKeys.Decrypt(b)
+}
+
+// NeedsEncrypt returns true if the value is not encrypted with the current master key.
+func (db *DB) NeedsEncrypt(b []byte) bool {
+	if db.masterKeys == nil {
+		return false
+	}
+
+	return db.masterKeys.NeedsEncrypt(b)
+}
+
+// Hash returns the hash used to look up a sensitive value without storing it. With master keys it
+// is keyed by the current master key, otherwise it is the SHA256 of the value.
+func (db *DB) Hash(b []byte) []byte {
+	if db.masterKeys == nil {
+		hash := sha256.Sum256(b)
+		return hash[:]
+	}
+
+	return db.masterKeys.Hash(b)
+}
+
+// PreviousHashes returns the hashes a value could have been stored with before the current master
+// key was configured. They include the unkeyed SHA256 from before keys were configured.
+func (db *DB) PreviousHashes(b []byte) [][]byte {
+	if db.masterKeys == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256(b)
+	return append(db.masterKeys.PreviousHashes(b), hash[:])
+}
+
 // -------------------------------------------------------------------------
 // Database
 
@@ -175,8 +292,9 @@ type binaryInterface interface {
 	Bytes() []byte
 }
 
-// prepareArguments checks if arguments should be converted to binary.
-func prepareArguments(args []interface{}) []interface{} {
+// prepareArguments checks if arguments should be converted to binary. SQLite stores times as text
+// so they are converted to UTC to keep comparisons between them in time order.
+func (db *DB) prepareArguments(args []interface{}) []interface{} {
 	result := make([]interface{}, 0, len(args))
 
 	// Convert any "binary" values and check for nil
@@ -193,6 +311,15 @@ func prepareArguments(args []interface{}) []interface{} {
 			continue
 		}
 
+		if db.driver == DriverSQLite {
+			switch t := arg.(type) {
+			case time.Time:
+				arg = t.UTC()
+			case *time.Time:
+				arg = t.UTC()
+			}
+		}
+
 		result = append(result, arg)
 	}
 
@@ -200,29 +327,48 @@ func prepareArguments(args []interface{}) []interface{} {
 }
 
 // Execute is used to execute PgSql commands.
-func (db *DB) Execute(ctx context.Context, sql string, args ...interface{}) error {
+func (db *DB) Execute(ctx context.Context, sql string, args ...interface{}) (err error) {
 	ctx, span := trace.StartSpan(ctx, "platform.DB.Execute")
 	defer span.End()
+	defer observeQuery("execute", time.Now(), &err)
+
+	_, err = db.execute(sql, args)
+	return err
+}
+
+// ExecuteCount executes a command and returns the number of rows it affected.
+func (db *DB) ExecuteCount(ctx context.Context, sql string,
+	args ...interface{}) (count int64, err error) {
+
+	ctx, span := trace.StartSpan(ctx, "platform.DB.ExecuteCount")
+	defer span.End()
+	defer observeQuery("execute", time.Now(), &err)
+
+	result, err := db.execute(sql, args)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
 
+func (db *DB) execute(sql string, args []interface{}) (sqldb.Result, error) {
 	activeDB := db.GetActiveDB()
 	if activeDB == nil {
-		return errors.Wrap(ErrInvalidDBProvided, "database == nil")
+		return nil, errors.Wrap(ErrInvalidDBProvided, "database == nil")
 	}
 
 	stmt, err := activeDB.Prepare(activeDB.Rebind(sql))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(args) == 0 {
 		// cannot pass empty args to Exec.
-		_, err = stmt.Exec()
-	} else {
-		pargs := prepareArguments(args)
-		_, err = stmt.Exec(pargs...)
+		return stmt.Exec()
 	}
 
-	return err
+	return stmt.Exec(db.prepareArguments(args)...)
 }
 
 // Query provides a string version of the value
@@ -235,7 +381,7 @@ func (db *DB) Query(ctx context.Context, sql string, args ...interface{}) (*sqlx
 		return nil, errors.Wrap(ErrInvalidDBProvided, "database == nil")
 	}
 
-	pargs := prepareArguments(args)
+	pargs := db.prepareArguments(args)
 	rows, err := activeDB.Queryx(activeDB.Rebind(sql), pargs...)
 	if err != nil {
 		return nil, err
@@ -245,16 +391,18 @@ func (db *DB) Query(ctx context.Context, sql string, args ...interface{}) (*sqlx
 }
 
 // Select using this DB. Any placeholder parameters are replaced with supplied args.
-func (db *DB) Select(ctx context.Context, model interface{}, sql string, args ...interface{}) error {
+func (db *DB) Select(ctx context.Context, model interface{}, sql string,
+	args ...interface{}) (err error) {
 	ctx, span := trace.StartSpan(ctx, "platform.DB.Select")
 	defer span.End()
+	defer observeQuery("select", time.Now(), &err)
 
 	activeDB := db.GetActiveDB()
 	if activeDB == nil {
 		return errors.Wrap(ErrInvalidDBProvided, "database == nil")
 	}
 
-	pargs := prepareArguments(args)
+	pargs := db.prepareArguments(args)
 	if err := activeDB.Select(model, activeDB.Rebind(sql), pargs...); err != nil {
 		if err == sqldb.ErrNoRows {
 			err = ErrNotFound
@@ -267,16 +415,18 @@ func (db *DB) Select(ctx context.Context, model interface{}, sql string, args ..
 }
 
 // SelectIn using a WHERE IN style query with this db.
-func (db *DB) SelectIn(ctx context.Context, model interface{}, sql string, args ...interface{}) error {
+func (db *DB) SelectIn(ctx context.Context, model interface{}, sql string,
+	args ...interface{}) (err error) {
 	ctx, span := trace.StartSpan(ctx, "platform.DB.Select")
 	defer span.End()
+	defer observeQuery("select", time.Now(), &err)
 
 	activeDB := db.GetActiveDB()
 	if activeDB == nil {
 		return errors.Wrap(ErrInvalidDBProvided, "database == nil")
 	}
 
-	pargs := prepareArguments(args)
+	pargs := db.prepareArguments(args)
 	inSql, inArgs, err := sqlx.In(sql, pargs...)
 	if err != nil {
 		return err
@@ -294,16 +444,18 @@ func (db *DB) SelectIn(ctx context.Context, model interface{}, sql string, args
 }
 
 // Get using this DB. Any placeholder parameters are replaced with supplied args. An error is returned if the result set is empty.
-func (db *DB) Get(ctx context.Context, model interface{}, sql string, args ...interface{}) error {
+func (db *DB) Get(ctx context.Context, model interface{}, sql string,
+	args ...interface{}) (err error) {
 	ctx, span := trace.StartSpan(ctx, "platform.DB.Get")
 	defer span.End()
+	defer observeQuery("get", time.Now(), &err)
 
 	activeDB := db.GetActiveDB()
 	if activeDB == nil {
 		return errors.Wrap(ErrInvalidDBProvided, "database == nil")
 	}
 
-	pargs := prepareArguments(args)
+	pargs := db.prepareArguments(args)
 	if err := activeDB.Get(model, activeDB.Rebind(sql), pargs...); err != nil {
 		if err == sqldb.ErrNoRows {
 			err = ErrNotFound
@@ -315,6 +467,15 @@ func (db *DB) Get(ctx context.Context, model interface{}, sql string, args ...in
 	return nil
 }
 
+// observeQuery records the latency of a query, and whether it failed, for metrics. Not found
+// isn't a failure.
+func observeQuery(operation string, start time.Time, err *error) {
+	queryDuration.ObserveSince(start, operation)
+	if *err != nil && *err != ErrNotFound {
+		queryErrors.Inc(operation)
+	}
+}
+
 // -------------------------------------------------------------------------
 // Database Transactions
 
@@ -332,6 +493,22 @@ func (db *DB) BeginTransaction() {
 	db.sessionTx = tx
 }
 
+// Begin starts a new database transaction. Unlike BeginTransaction it returns an error when the
+// transaction can't be started, so a database failure doesn't panic the caller.
+func (db *DB) Begin() error {
+	if db.session == nil {
+		return errors.Wrap(ErrInvalidDBProvided, "transaction on master instance")
+	}
+
+	tx, err := db.session.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin")
+	}
+
+	db.sessionTx = tx
+	return nil
+}
+
 // Commit the pending transaction to the database.
 func (db *DB) Commit() error {
 	err := db.sessionTx.Commit()
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/publichttp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// WellKnownPath is where a domain publishes tokens for the identity oracle.
+	WellKnownPath = "/.well-known/tokenized-identity"
+
+	// maxWellKnownSize is the maximum number of bytes read from a well known file.
+	maxWellKnownSize = 64 * 1024
+)
+
+// Resolver retrieves the verification tokens a domain has published.
+type Resolver interface {
+	// WellKnown returns the lines of https://<domain>/.well-known/tokenized-identity.
+	WellKnown(ctx context.Context, domain string) ([]string, error)
+
+	// TXT returns the DNS TXT records of the domain.
+	TXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// NetResolver retrieves tokens over HTTPS and DNS.
+type NetResolver struct {
+	client   *http.Client
+	resolver *net.Resolver
+}
+
+func NewNetResolver(timeout time.Duration) *NetResolver {
+	return &NetResolver{
+		// Redirects are not followed so the token has to be served by the domain itself, and only
+		// public addresses are connected to since the domain is chosen by the user.
+		client:   publichttp.NewClient(timeout),
+		resolver: &net.Resolver{},
+	}
+}
+
+func (r *NetResolver) WellKnown(ctx context.Context, domain string) ([]string, error) {
+	request, err := http.NewRequest("GET", "https://"+domain+WellKnownPath, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+
+	response, err := r.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "get")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status %d", response.StatusCode)
+	}
+
+	var result []string
+	scanner := bufio.NewScanner(io.LimitReader(response.Body, maxWellKnownSize))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) != 0 {
+			result = append(result, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read")
+	}
+
+	return result, nil
+}
+
+func (r *NetResolver) TXT(ctx context.Context, domain string) ([]string, error) {
+	result, err := r.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "lookup txt")
+	}
+
+	return result, nil
+}
+
+// MockResolver returns tokens set in memory. It is used for tests.
+type MockResolver struct {
+	wellKnown map[string][]string
+	txt       map[string][]string
+	lock      sync.Mutex
+}
+
+func NewMockResolver() *MockResolver {
+	return &MockResolver{
+		wellKnown: make(map[string][]string),
+		txt:       make(map[string][]string),
+	}
+}
+
+// SetWellKnown sets the lines of the domain's well known file.
+func (r *MockResolver) SetWellKnown(domain string, lines ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.wellKnown[domain] = lines
+}
+
+// SetTXT sets the domain's TXT records.
+func (r *MockResolver) SetTXT(domain string, records ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.txt[domain] = records
+}
+
+func (r *MockResolver) WellKnown(ctx context.Context, domain string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.wellKnown[domain], nil
+}
+
+func (r *MockResolver) TXT(ctx context.Context, domain string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.txt[domain], nil
+}
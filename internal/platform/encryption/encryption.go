@@ -0,0 +1,276 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// Values are envelope encrypted. Each value is encrypted with a random data key using AES-GCM and
+// the data key is encrypted with the master key, also using AES-GCM. The encrypted value is
+// serialized as :
+//   magic (4 bytes)
+//   version (1 byte)
+//   master key id (4 bytes)
+//   data key nonce (12 bytes)
+//   encrypted data key (48 bytes)
+//   value nonce (12 bytes)
+//   encrypted value
+// The header, everything before the encrypted value, is authenticated as additional data.
+//
+// Values that are looked up by hash are hashed with HMAC-SHA256, keyed by a hash key derived from
+// the master key, so that the hashes can't be reversed by hashing guesses without the master key.
+
+const (
+	version = uint8(1)
+
+	keySize   = 32
+	keyIDSize = 4
+	nonceSize = 12
+
+	wrappedKeySize = keySize + 16 // GCM tag
+
+	headerSize = 4 + 1 + keyIDSize + nonceSize + wrappedKeySize + nonceSize
+)
+
+var (
+	// hashKeyLabel is hashed with a master key to derive its hash key, so that the master key
+	// itself is only used for encryption.
+	hashKeyLabel = []byte("identity-oracle value hash")
+
+	// magic prefixes encrypted values. It starts with a zero byte, which is never the first byte
+	// of a valid protobuf message since zero is not a valid field number.
+	magic = []byte{0x00, 'E', 'N', 'C'}
+
+	// ErrUnknownKey occurs when a value was encrypted with a master key that isn't configured.
+	ErrUnknownKey = errors.New("Unknown Master Key")
+
+	// ErrInvalidFormat occurs when an encrypted value can't be parsed.
+	ErrInvalidFormat = errors.New("Invalid Encrypted Format")
+)
+
+// KeyID identifies a master key without revealing it.
+type KeyID [keyIDSize]byte
+
+func (id KeyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type masterKey struct {
+	id      KeyID
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// MasterKeys holds the master key used to encrypt new values, and previous master keys that are
+// still accepted for decryption while values are being re-encrypted to a new master key.
+type MasterKeys struct {
+	current *masterKey
+	keys    map[KeyID]*masterKey
+}
+
+// NewMasterKeys creates a set of master keys from hex encoded 32 byte keys.
+func NewMasterKeys(current string, previous []string) (*MasterKeys, error) {
+	result := &MasterKeys{
+		keys: make(map[KeyID]*masterKey),
+	}
+
+	key, err := newMasterKey(current)
+	if err != nil {
+		return nil, errors.Wrap(err, "current key")
+	}
+	result.current = key
+	result.keys[key.id] = key
+
+	for i, s := range previous {
+		if len(s) == 0 {
+			continue
+		}
+
+		key, err := newMasterKey(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "previous key %d", i)
+		}
+		result.keys[key.id] = key
+	}
+
+	return result, nil
+}
+
+func newMasterKey(s string) (*masterKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "hex")
+	}
+
+	if len(b) != keySize {
+		return nil, errors.Errorf("Wrong key size : got %d, want %d", len(b), keySize)
+	}
+
+	aead, err := newAEAD(b)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, b)
+	mac.Write(hashKeyLabel)
+
+	result := &masterKey{
+		aead:    aead,
+		hashKey: mac.Sum(nil),
+	}
+	hash := sha256.Sum256(b)
+	copy(result.id[:], hash[:])
+
+	return result, nil
+}
+
+func (k *masterKey) hash(b []byte) []byte {
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "aes")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "gcm")
+	}
+
+	return aead, nil
+}
+
+// CurrentKeyID returns the id of the master key used to encrypt new values.
+func (k *MasterKeys) CurrentKeyID() KeyID {
+	return k.current.id
+}
+
+// Hash returns the keyed hash of a value with the current master key. Unlike encrypted values,
+// hashes of the same value are always the same so they can be used to look values up.
+func (k *MasterKeys) Hash(b []byte) []byte {
+	return k.current.hash(b)
+}
+
+// PreviousHashes returns the keyed hashes of a value with each of the previous master keys. They
+// are used to find hashes that need to be replaced after the master key is rotated.
+func (k *MasterKeys) PreviousHashes(b []byte) [][]byte {
+	var result [][]byte
+	for id, key := range k.keys {
+		if id != k.current.id {
+			result = append(result, key.hash(b))
+		}
+	}
+	return result
+}
+
+// Encrypt encrypts a value with a new data key protected by the current master key.
+func (k *MasterKeys) Encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errors.Wrap(err, "data key")
+	}
+
+	keyNonce := make([]byte, nonceSize)
+	if _, err := rand.Read(keyNonce); err != nil {
+		return nil, errors.Wrap(err, "key nonce")
+	}
+
+	valueNonce := make([]byte, nonceSize)
+	if _, err := rand.Read(valueNonce); err != nil {
+		return nil, errors.Wrap(err, "value nonce")
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "data key")
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = append(header, k.current.id[:]...)
+	header = append(header, keyNonce...)
+	header = k.current.aead.Seal(header, keyNonce, dataKey, k.current.id[:])
+	header = append(header, valueNonce...)
+
+	result := make([]byte, len(header), len(header)+len(plaintext)+dataAEAD.Overhead())
+	copy(result, header)
+	return dataAEAD.Seal(result, valueNonce, plaintext, header), nil
+}
+
+// Decrypt returns the plaintext of an encrypted value. Values that are not encrypted are returned
+// unchanged so data written before encryption was enabled can still be read.
+func (k *MasterKeys) Decrypt(b []byte) ([]byte, error) {
+	if !IsEncrypted(b) {
+		return b, nil
+	}
+
+	if len(b) < headerSize {
+		return nil, ErrInvalidFormat
+	}
+
+	if b[len(magic)] != version {
+		return nil, errors.Wrapf(ErrInvalidFormat, "version %d", b[len(magic)])
+	}
+
+	offset := len(magic) + 1
+
+	var id KeyID
+	copy(id[:], b[offset:])
+	offset += keyIDSize
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownKey, id.String())
+	}
+
+	keyNonce := b[offset : offset+nonceSize]
+	offset += nonceSize
+
+	dataKey, err := key.aead.Open(nil, keyNonce, b[offset:offset+wrappedKeySize], id[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt data key")
+	}
+	offset += wrappedKeySize
+
+	valueNonce := b[offset : offset+nonceSize]
+	offset += nonceSize
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "data key")
+	}
+
+	result, err := dataAEAD.Open(nil, valueNonce, b[offset:], b[:offset])
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt value")
+	}
+
+	return result, nil
+}
+
+// NeedsEncrypt returns true if the value is not encrypted, or is encrypted with a master key other
+// than the current one.
+func (k *MasterKeys) NeedsEncrypt(b []byte) bool {
+	if !IsEncrypted(b) || len(b) < headerSize {
+		return true
+	}
+
+	return !bytes.Equal(b[len(magic)+1:len(magic)+1+keyIDSize], k.current.id[:])
+}
+
+// IsEncrypted returns true if the value is in the encrypted format.
+func IsEncrypted(b []byte) bool {
+	return bytes.HasPrefix(b, magic)
+}
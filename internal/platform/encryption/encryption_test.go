@@ -0,0 +1,166 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func generateKey(t *testing.T) string {
+	b := make([]byte, keySize)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func TestEncrypt(t *testing.T) {
+	keys, err := NewMasterKeys(generateKey(t), nil)
+	if err != nil {
+		t.Fatalf("Failed to create master keys : %s", err)
+	}
+
+	plaintext := []byte("Satoshi Nakamoto, 1 Main St")
+
+	ciphertext, err := keys.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt : %s", err)
+	}
+
+	if !IsEncrypted(ciphertext) {
+		t.Fatalf("Value not in encrypted format")
+	}
+
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Fatalf("Ciphertext contains plaintext")
+	}
+
+	if keys.NeedsEncrypt(ciphertext) {
+		t.Fatalf("Value encrypted with current key should not need encrypt")
+	}
+
+	decrypted, err := keys.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt : %s", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Wrong plaintext : got %x, want %x", decrypted, plaintext)
+	}
+
+	// Tampering must be detected.
+	ciphertext[len(ciphertext)-1] ^= 0x01
+	if _, err := keys.Decrypt(ciphertext); err == nil {
+		t.Fatalf("Tampered value should not decrypt")
+	}
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	keys, err := NewMasterKeys(generateKey(t), nil)
+	if err != nil {
+		t.Fatalf("Failed to create master keys : %s", err)
+	}
+
+	plaintext := []byte{0x0a, 0x04, 't', 'e', 's', 't'} // protobuf
+
+	if !keys.NeedsEncrypt(plaintext) {
+		t.Fatalf("Plaintext should need encrypt")
+	}
+
+	decrypted, err := keys.Decrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt : %s", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Plaintext not returned unchanged")
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	oldKey := generateKey(t)
+	newKey := generateKey(t)
+
+	oldKeys, err := NewMasterKeys(oldKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to create old master keys : %s", err)
+	}
+
+	plaintext := []byte("test value")
+
+	ciphertext, err := oldKeys.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt : %s", err)
+	}
+
+	newOnlyKeys, err := NewMasterKeys(newKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to create new master keys : %s", err)
+	}
+
+	if _, err := newOnlyKeys.Decrypt(ciphertext); errors.Cause(err) != ErrUnknownKey {
+		t.Fatalf("Wrong error without old key : got %v, want %s", err, ErrUnknownKey)
+	}
+
+	rotateKeys, err := NewMasterKeys(newKey, []string{oldKey})
+	if err != nil {
+		t.Fatalf("Failed to create rotation master keys : %s", err)
+	}
+
+	if !rotateKeys.NeedsEncrypt(ciphertext) {
+		t.Fatalf("Value encrypted with old key should need encrypt")
+	}
+
+	decrypted, err := rotateKeys.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt with previous key : %s", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Wrong plaintext : got %x, want %x", decrypted, plaintext)
+	}
+}
+
+func TestHash(t *testing.T) {
+	oldKey := generateKey(t)
+	newKey := generateKey(t)
+
+	oldKeys, err := NewMasterKeys(oldKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to create old master keys : %s", err)
+	}
+
+	value := []byte("EmailAddress:test@tokenized.com")
+
+	hash := oldKeys.Hash(value)
+	if !bytes.Equal(hash, oldKeys.Hash(value)) {
+		t.Fatalf("Hashes of the same value should match")
+	}
+
+	if bytes.Equal(hash, oldKeys.Hash([]byte("EmailAddress:other@tokenized.com"))) {
+		t.Fatalf("Hashes of different values should not match")
+	}
+
+	unkeyed := sha256.Sum256(value)
+	if bytes.Equal(hash, unkeyed[:]) {
+		t.Fatalf("Hash should be keyed")
+	}
+
+	rotateKeys, err := NewMasterKeys(newKey, []string{oldKey})
+	if err != nil {
+		t.Fatalf("Failed to create rotation master keys : %s", err)
+	}
+
+	if bytes.Equal(rotateKeys.Hash(value), hash) {
+		t.Fatalf("Hash with new key should not match old key")
+	}
+
+	previous := rotateKeys.PreviousHashes(value)
+	if len(previous) != 1 || !bytes.Equal(previous[0], hash) {
+		t.Fatalf("Previous hashes should contain old key hash : %x", previous)
+	}
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// SMTPMailer sends email messages through an SMTP server.
+type SMTPMailer struct {
+	address string
+	host    string
+	auth    smtp.Auth
+	from    string
+}
+
+// NewSMTPMailer creates a mailer that sends through the SMTP server at host:port. Authentication
+// is only used when a username is provided.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	result := &SMTPMailer{
+		address: net.JoinHostPort(host, strconv.Itoa(port)),
+		host:    host,
+		from:    from,
+	}
+
+	if len(username) != 0 {
+		result.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return result
+}
+
+// Send sends a plain text email.
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("header contains new line")
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", m.from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+
+	if err := smtp.SendMail(m.address, m.auth, m.from, []string{to},
+		message.Bytes()); err != nil {
+		return errors.Wrap(err, "smtp send")
+	}
+
+	return nil
+}
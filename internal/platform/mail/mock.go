@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is an email sent through the mock mailer.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MockMailer keeps sent messages in memory. It is used for tests and local development.
+type MockMailer struct {
+	messages []*Message
+	lock     sync.Mutex
+}
+
+func NewMockMailer() *MockMailer {
+	return &MockMailer{}
+}
+
+func (m *MockMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.messages = append(m.messages, &Message{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	})
+	return nil
+}
+
+// Messages returns all messages sent.
+func (m *MockMailer) Messages() []*Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := make([]*Message, len(m.messages))
+	copy(result, m.messages)
+	return result
+}
+
+// LastMessage returns the most recent message sent to the address, or nil if there isn't one.
+func (m *MockMailer) LastMessage(to string) *Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i]
+		}
+	}
+
+	return nil
+}
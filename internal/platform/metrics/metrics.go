@@ -0,0 +1,327 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ContentType is the content type of the Prometheus text exposition format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var (
+	// DefaultBuckets are histogram buckets, in seconds, suited to request and query latencies.
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// Default is the registry that the service's metrics are registered in and that is exposed by
+	// the /metrics route.
+	Default = NewRegistry()
+)
+
+// Registry holds metrics and writes them in the Prometheus text exposition format.
+type Registry struct {
+	metrics []*metric
+	names   map[string]bool
+	lock    sync.Mutex
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+// metric is a named metric and its values for each combination of label values.
+type metric struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+	buckets    []float64
+	value      func() float64 // for gauge functions
+
+	series map[string]*series
+	lock   sync.Mutex
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // histogram bucket counts, not cumulative
+	count       uint64
+}
+
+func (r *Registry) register(m *metric) *metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.names[m.name] {
+		panic(fmt.Sprintf("metric %s registered twice", m.name))
+	}
+
+	m.series = make(map[string]*series)
+	r.names[m.name] = true
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// Counter is a value that only increases, like a number of requests.
+type Counter struct {
+	m *metric
+}
+
+// NewCounter registers a counter with label names.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{m: r.register(&metric{
+		name:       name,
+		help:       help,
+		typ:        typeCounter,
+		labelNames: labelNames,
+	})}
+}
+
+// Inc adds one to the counter with the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.m.update(labelValues, func(s *series) {
+		s.value += v
+	})
+}
+
+// Gauge is a value that can go up and down, like a block height.
+type Gauge struct {
+	m *metric
+}
+
+// NewGauge registers a gauge with label names.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{m: r.register(&metric{
+		name:       name,
+		help:       help,
+		typ:        typeGauge,
+		labelNames: labelNames,
+	})}
+}
+
+// Set sets the gauge with the label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *series) {
+		s.value = v
+	})
+}
+
+// NewGaugeFunc registers a gauge without labels whose value is calculated by value when the
+// metrics are written, for example the time since an event.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&metric{
+		name:  name,
+		help:  help,
+		typ:   typeGauge,
+		value: value,
+	})
+}
+
+// Histogram counts observed values, like latencies, in buckets.
+type Histogram struct {
+	m *metric
+}
+
+// NewHistogram registers a histogram with bucket upper bounds, in increasing order, and label
+// names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64,
+	labelNames ...string) *Histogram {
+
+	return &Histogram{m: r.register(&metric{
+		name:       name,
+		help:       help,
+		typ:        typeHistogram,
+		labelNames: labelNames,
+		buckets:    buckets,
+	})}
+}
+
+// Observe adds a value to the histogram with the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.m.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.m.buckets))
+		}
+
+		for i, bound := range h.m.buckets {
+			if v <= bound {
+				s.counts[i]++
+				break
+			}
+		}
+
+		s.value += v
+		s.count++
+	})
+}
+
+// ObserveSince adds the seconds since start to the histogram with the label values.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// NewCounter registers a counter in the default registry.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return Default.NewCounter(name, help, labelNames...)
+}
+
+// NewGauge registers a gauge in the default registry.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return Default.NewGauge(name, help, labelNames...)
+}
+
+// NewGaugeFunc registers a gauge function in the default registry.
+func NewGaugeFunc(name, help string, value func() float64) {
+	Default.NewGaugeFunc(name, help, value)
+}
+
+// NewHistogram registers a histogram in the default registry.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labelNames...)
+}
+
+func (m *metric) update(labelValues []string, f func(*series)) {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", m.name, len(m.labelNames),
+			len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s, exists := m.series[key]
+	if !exists {
+		s = &series{
+			labelValues: append([]string(nil), labelValues...),
+		}
+		m.series[key] = s
+	}
+
+	f(s)
+}
+
+// Write writes all metrics in the Prometheus text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.lock.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name < metrics[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+
+	if m.value != nil {
+		fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.value()))
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		labels := formatLabels(m.labelNames, s.labelValues)
+
+		if m.typ != typeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, labels, formatFloat(s.value))
+			continue
+		}
+
+		bucketNames := append(append([]string(nil), m.labelNames...), "le")
+		bucketValues := append(append([]string(nil), s.labelValues...), "")
+		le := len(bucketValues) - 1
+
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += s.counts[i]
+			bucketValues[le] = formatFloat(bound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(bucketNames, bucketValues),
+				cumulative)
+		}
+		bucketValues[le] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(bucketNames, bucketValues),
+			s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labels, formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, labels, s.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
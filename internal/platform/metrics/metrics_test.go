@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.NewCounter("test_requests_total", "Requests.", "route", "status")
+	requests.Inc("/a", "200")
+	requests.Inc("/a", "200")
+	requests.Add(3, "/b\"", "500")
+
+	height := registry.NewGauge("test_height", "Block height.")
+	height.Set(650000)
+
+	registry.NewGaugeFunc("test_age_seconds", "Age\nin seconds.", func() float64 {
+		return 12.5
+	})
+
+	latency := registry.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1},
+		"route")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	if err := registry.Write(&buf); err != nil {
+		t.Fatalf("Failed to write metrics : %s", err)
+	}
+
+	want := `# HELP test_age_seconds Age\nin seconds.
+# TYPE test_age_seconds gauge
+test_age_seconds 12.5
+# HELP test_height Block height.
+# TYPE test_height gauge
+test_height 650000
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 2
+test_latency_seconds_bucket{route="/a",le="+Inf"} 3
+test_latency_seconds_sum{route="/a"} 5.55
+test_latency_seconds_count{route="/a"} 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 2
+test_requests_total{route="/b\"",status="500"} 3
+`
+
+	if buf.String() != want {
+		t.Fatalf("Wrong metrics :\ngot:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_total", "Test.")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Registering a metric twice should panic")
+		}
+	}()
+
+	registry.NewCounter("test_total", "Test.")
+}
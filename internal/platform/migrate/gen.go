@@ -0,0 +1,74 @@
+//go:build ignore
+// +build ignore
+
+// gen embeds the SQL migrations from db/master and db/sqlite in sources.go and sources_sqlite.go.
+// Run "go generate" after adding a migration.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dialects are the directory of each database driver's migrations and the file and variable they
+// are embedded in.
+var dialects = []struct {
+	dir  string
+	file string
+	name string
+}{
+	{"../../../db/master", "sources.go", "postgresSources"},
+	{"../../../db/sqlite", "sources_sqlite.go", "sqliteSources"},
+}
+
+func main() {
+	for _, dialect := range dialects {
+		generate(dialect.dir, dialect.file, dialect.name)
+	}
+}
+
+func generate(dir, file, name string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		log.Fatalf("Failed to list migrations : %s", err)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen.go from db/%s. DO NOT EDIT.\n\n", filepath.Base(dir))
+	buf.WriteString("package migrate\n\n")
+	fmt.Fprintf(&buf, "var %s = []source{\n", name)
+
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read migration : %s", err)
+		}
+
+		sql := string(b)
+		quoted := "`" + sql + "`"
+		if strings.Contains(sql, "`") {
+			quoted = strconv.Quote(sql)
+		}
+
+		fmt.Fprintf(&buf, "\t{\n\t\tname: %q,\n\t\tsql: %s,\n\t},\n", filepath.Base(path), quoted)
+	}
+
+	buf.WriteString("}\n")
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("Failed to format sources : %s", err)
+	}
+
+	if err := ioutil.WriteFile(file, formatted, 0644); err != nil {
+		log.Fatalf("Failed to write sources : %s", err)
+	}
+}
@@ -0,0 +1,293 @@
+// Package migrate applies the SQL migrations in db/master, or db/sqlite for SQLite databases, which
+// are embedded in the binary. Each migration has the same version in both. Applied versions are
+// recorded in the same table as the goose tool so databases migrated with goose are recognized.
+package migrate
+
+//go:generate go run gen.go
+
+import (
+	"context"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// lockID is the Postgres advisory lock held while a migration is applied so that instances
+	// starting at the same time don't apply it twice.
+	lockID = 4573629174
+)
+
+var (
+	// ErrBehind is returned when migrations haven't been applied to the database.
+	ErrBehind = errors.New("Database schema behind")
+
+	// ErrNotApplied is returned when rolling back a migration that isn't applied.
+	ErrNotApplied = errors.New("Migration not applied")
+)
+
+// Status is whether a migration is applied to the database.
+type Status struct {
+	Migration *Migration
+	Applied   time.Time // zero when not applied
+}
+
+// Statuses returns the status of each migration in version order.
+func Statuses(ctx context.Context, masterDB *db.DB) ([]*Status, error) {
+	migrations, err := Migrations(masterDB.Driver())
+	if err != nil {
+		return nil, errors.Wrap(err, "migrations")
+	}
+
+	dbConn := masterDB.Copy()
+	defer dbConn.Close()
+
+	applied, err := appliedVersions(ctx, dbConn)
+	if err != nil {
+		return nil, errors.Wrap(err, "applied")
+	}
+
+	result := make([]*Status, 0, len(migrations))
+	for _, migration := range migrations {
+		result = append(result, &Status{
+			Migration: migration,
+			Applied:   applied[migration.Version],
+		})
+	}
+
+	return result, nil
+}
+
+// Pending returns the migrations that haven't been applied in version order.
+func Pending(ctx context.Context, masterDB *db.DB) ([]*Migration, error) {
+	statuses, err := Statuses(ctx, masterDB)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Migration
+	for _, status := range statuses {
+		if status.Applied.IsZero() {
+			result = append(result, status.Migration)
+		}
+	}
+
+	return result, nil
+}
+
+// Check returns ErrBehind when migrations haven't been applied.
+func Check(ctx context.Context, masterDB *db.DB) error {
+	pending, err := Pending(ctx, masterDB)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) != 0 {
+		return errors.Wrapf(ErrBehind, "%d migrations pending from %s", len(pending),
+			pending[0].Name)
+	}
+
+	return nil
+}
+
+// Up applies the migrations that haven't been applied and returns how many were.
+func Up(ctx context.Context, masterDB *db.DB) (int, error) {
+	pending, err := Pending(ctx, masterDB)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range pending {
+		applied, err := apply(ctx, masterDB, migration, true)
+		if err != nil {
+			return count, errors.Wrap(err, migration.Name)
+		}
+
+		if applied {
+			logger.Info(ctx, "Applied migration %s", migration.Name)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Down rolls back the latest applied migration. Returns nil when none are applied.
+func Down(ctx context.Context, masterDB *db.DB) (*Migration, error) {
+	statuses, err := Statuses(ctx, masterDB)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i].Applied.IsZero() {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		rolledBack, err := apply(ctx, masterDB, migration, false)
+		if err != nil {
+			return nil, errors.Wrap(err, migration.Name)
+		}
+
+		if !rolledBack {
+			return nil, errors.Wrap(ErrNotApplied, migration.Name)
+		}
+
+		logger.Info(ctx, "Rolled back migration %s", migration.Name)
+		return migration, nil
+	}
+
+	return nil, nil
+}
+
+// DownTo rolls back the applied migrations with versions after version and returns how many were.
+func DownTo(ctx context.Context, masterDB *db.DB, version int64) (int, error) {
+	count := 0
+	for {
+		statuses, err := Statuses(ctx, masterDB)
+		if err != nil {
+			return count, err
+		}
+
+		latest := int64(0)
+		for _, status := range statuses {
+			if !status.Applied.IsZero() {
+				latest = status.Migration.Version
+			}
+		}
+
+		if latest <= version {
+			return count, nil
+		}
+
+		if _, err := Down(ctx, masterDB); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// apply applies, or rolls back, a migration in a transaction. Returns false when another instance
+// already did.
+func apply(ctx context.Context, masterDB *db.DB, migration *Migration, up bool) (bool, error) {
+	tx := masterDB.Copy()
+	defer tx.Close()
+
+	tx.BeginTransaction()
+
+	// SQLite allows one writer at a time so another instance can't apply the migration as well.
+	if tx.Driver() == db.DriverPostgres {
+		if err := tx.Execute(ctx, `SELECT pg_advisory_xact_lock(?)`, lockID); err != nil {
+			tx.Rollback()
+			return false, errors.Wrap(err, "lock")
+		}
+	}
+
+	// Check again now that the lock is held.
+	applied, err := appliedVersions(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "applied")
+	}
+
+	if _, isApplied := applied[migration.Version]; isApplied == up {
+		tx.Rollback()
+		return false, nil
+	}
+
+	statements := migration.Up
+	if !up {
+		statements = migration.Down
+	}
+
+	for i, statement := range statements {
+		if err := tx.Execute(ctx, statement); err != nil {
+			tx.Rollback()
+			return false, errors.Wrapf(err, "statement %d", i+1)
+		}
+	}
+
+	if up {
+		err = tx.Execute(ctx, `INSERT INTO goose_db_version (version_id, is_applied, tstamp)
+			VALUES (?, true, ?)`, migration.Version, time.Now())
+	} else {
+		err = tx.Execute(ctx, `DELETE FROM goose_db_version WHERE version_id = ?`,
+			migration.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "record version")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "commit")
+	}
+
+	return true, nil
+}
+
+// appliedVersions returns when each applied version was applied. Older versions of goose record
+// roll backs as rows that aren't applied so the latest row of each version is used.
+func appliedVersions(ctx context.Context, dbConn *db.DB) (map[int64]time.Time, error) {
+	if err := createVersionTable(ctx, dbConn); err != nil {
+		return nil, errors.Wrap(err, "create version table")
+	}
+
+	var rows []struct {
+		VersionID int64      `db:"version_id"`
+		IsApplied bool       `db:"is_applied"`
+		Timestamp *time.Time `db:"tstamp"`
+	}
+
+	if err := dbConn.Select(ctx, &rows, `SELECT version_id, is_applied, tstamp
+		FROM goose_db_version
+		ORDER BY id DESC`); err != nil && errors.Cause(err) != db.ErrNotFound {
+		return nil, errors.Wrap(err, "select")
+	}
+
+	seen := make(map[int64]bool)
+	result := make(map[int64]time.Time)
+	for _, row := range rows {
+		if seen[row.VersionID] {
+			continue
+		}
+		seen[row.VersionID] = true
+
+		if !row.IsApplied || row.VersionID == 0 {
+			continue
+		}
+
+		applied := time.Unix(0, 0)
+		if row.Timestamp != nil {
+			applied = *row.Timestamp
+		}
+		result[row.VersionID] = applied
+	}
+
+	return result, nil
+}
+
+// createVersionTable creates the goose version table if it doesn't exist.
+func createVersionTable(ctx context.Context, dbConn *db.DB) error {
+	if dbConn.Driver() == db.DriverSQLite {
+		return dbConn.Execute(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				version_id INTEGER NOT NULL,
+				is_applied INTEGER NOT NULL,
+				tstamp TIMESTAMP DEFAULT (datetime('now'))
+			)`)
+	}
+
+	return dbConn.Execute(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+			id serial NOT NULL,
+			version_id bigint NOT NULL,
+			is_applied boolean NOT NULL,
+			tstamp timestamp NULL default now(),
+			PRIMARY KEY(id)
+		)`)
+}
@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+)
+
+func TestParse(t *testing.T) {
+	sql := `-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+CREATE TABLE users (
+    id uuid NOT NULL
+);
+
+ALTER TABLE ONLY users ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+-- +goose StatementBegin
+CREATE FUNCTION one() RETURNS integer AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION one();
+DROP TABLE IF EXISTS users CASCADE;
+`
+
+	migration, err := parse("20201025090000_create_users.sql", sql)
+	if err != nil {
+		t.Fatalf("Failed to parse migration : %s", err)
+	}
+
+	if migration.Version != 20201025090000 {
+		t.Fatalf("Wrong version : %d", migration.Version)
+	}
+
+	if migration.Name != "20201025090000_create_users" {
+		t.Fatalf("Wrong name : %s", migration.Name)
+	}
+
+	wantUp := []string{
+		"CREATE TABLE users (\n    id uuid NOT NULL\n);",
+		"ALTER TABLE ONLY users ADD CONSTRAINT users_pkey PRIMARY KEY (id);",
+		"CREATE FUNCTION one() RETURNS integer AS $$\nBEGIN\n    RETURN 1;\nEND;\n" +
+			"$$ LANGUAGE plpgsql;",
+	}
+	if !reflect.DeepEqual(migration.Up, wantUp) {
+		t.Fatalf("Wrong up statements :\ngot  %q\nwant %q", migration.Up, wantUp)
+	}
+
+	wantDown := []string{
+		"DROP FUNCTION one();",
+		"DROP TABLE IF EXISTS users CASCADE;",
+	}
+	if !reflect.DeepEqual(migration.Down, wantDown) {
+		t.Fatalf("Wrong down statements :\ngot  %q\nwant %q", migration.Down, wantDown)
+	}
+
+	for _, invalid := range []string{
+		"CREATE TABLE users (id uuid NOT NULL);",                      // no up section
+		"-- +goose Up\nCREATE TABLE users (id uuid NOT NULL)",         // no semicolon
+		"-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE users;", // no statement end
+	} {
+		if _, err := parse("20201025090000_create_users.sql", invalid); err == nil {
+			t.Fatalf("Invalid migration should fail : %s", invalid)
+		}
+	}
+
+	if _, err := parse("create_users.sql", sql); err == nil {
+		t.Fatalf("Migration without a version should fail")
+	}
+}
+
+// TestSources checks that the embedded migrations match db/master and db/sqlite and that each
+// migration has the same version for both drivers.
+func TestSources(t *testing.T) {
+	dialects := []struct {
+		driver  string
+		dir     string
+		sources []source
+	}{
+		{db.DriverPostgres, "../../../db/master", postgresSources},
+		{db.DriverSQLite, "../../../db/sqlite", sqliteSources},
+	}
+
+	var versions []int64
+	for _, dialect := range dialects {
+		paths, err := filepath.Glob(filepath.Join(dialect.dir, "*.sql"))
+		if err != nil {
+			t.Fatalf("Failed to list %s migrations : %s", dialect.driver, err)
+		}
+
+		if len(paths) != len(dialect.sources) {
+			t.Fatalf("Embedded %s migrations out of date, run go generate : got %d, want %d",
+				dialect.driver, len(dialect.sources), len(paths))
+		}
+
+		for i, path := range paths {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read migration : %s", err)
+			}
+
+			if dialect.sources[i].name != filepath.Base(path) ||
+				dialect.sources[i].sql != string(b) {
+				t.Fatalf("Embedded %s migration %s out of date, run go generate", dialect.driver,
+					filepath.Base(path))
+			}
+		}
+
+		migrations, err := Migrations(dialect.driver)
+		if err != nil {
+			t.Fatalf("Failed to parse %s migrations : %s", dialect.driver, err)
+		}
+
+		for i := 1; i < len(migrations); i++ {
+			if migrations[i].Version <= migrations[i-1].Version {
+				t.Fatalf("Migrations out of order : %s", migrations[i].Name)
+			}
+		}
+
+		var dialectVersions []int64
+		for _, migration := range migrations {
+			dialectVersions = append(dialectVersions, migration.Version)
+		}
+
+		if versions == nil {
+			versions = dialectVersions
+		} else if !reflect.DeepEqual(dialectVersions, versions) {
+			t.Fatalf("Migration versions differ for %s :\ngot  %v\nwant %v", dialect.driver,
+				dialectVersions, versions)
+		}
+	}
+
+	if _, err := Migrations("mysql"); err == nil {
+		t.Fatalf("Unsupported driver should fail")
+	}
+}
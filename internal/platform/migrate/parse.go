@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tokenized/identity-oracle/internal/platform/db"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	annotationPrefix = "-- +goose "
+
+	annotationUp             = "Up"
+	annotationDown           = "Down"
+	annotationStatementBegin = "StatementBegin"
+	annotationStatementEnd   = "StatementEnd"
+)
+
+// Migration is a change to the database schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      []string // statements that apply the migration
+	Down    []string // statements that roll the migration back
+}
+
+// source is the SQL of a migration file.
+type source struct {
+	name string
+	sql  string
+}
+
+// Migrations returns the migrations of a database driver embedded in the binary in version order.
+func Migrations(driver string) ([]*Migration, error) {
+	switch driver {
+	case db.DriverPostgres:
+		return parseSources(postgresSources)
+	case db.DriverSQLite:
+		return parseSources(sqliteSources)
+	default:
+		return nil, errors.Errorf("unsupported database driver %s", driver)
+	}
+}
+
+func parseSources(sources []source) ([]*Migration, error) {
+	result := make([]*Migration, 0, len(sources))
+	versions := make(map[int64]string)
+	for _, s := range sources {
+		migration, err := parse(s.name, s.sql)
+		if err != nil {
+			return nil, errors.Wrap(err, s.name)
+		}
+
+		if previous, exists := versions[migration.Version]; exists {
+			return nil, errors.Errorf("%s has the same version as %s", s.name, previous)
+		}
+		versions[migration.Version] = s.name
+
+		result = append(result, migration)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// parse parses a migration in the goose SQL format. The version is the number at the start of
+// the file name. Statements in the "-- +goose Up" and "-- +goose Down" sections end with a
+// semicolon at the end of a line unless they are between "-- +goose StatementBegin" and
+// "-- +goose StatementEnd".
+func parse(name, sql string) (*Migration, error) {
+	underscore := strings.Index(name, "_")
+	if underscore == -1 {
+		return nil, errors.New("name doesn't start with a version")
+	}
+
+	version, err := strconv.ParseInt(name[:underscore], 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "version")
+	}
+
+	result := &Migration{
+		Version: version,
+		Name:    strings.TrimSuffix(name, ".sql"),
+	}
+
+	var section *[]string
+	var statement strings.Builder
+	inBlock := false
+
+	endStatement := func() {
+		s := strings.TrimSpace(statement.String())
+		if len(s) != 0 {
+			*section = append(*section, s)
+		}
+		statement.Reset()
+	}
+
+	for i, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, annotationPrefix) {
+			annotation := strings.TrimSpace(strings.TrimPrefix(trimmed, annotationPrefix))
+			switch annotation {
+			case annotationUp, annotationDown:
+				if inBlock || len(strings.TrimSpace(statement.String())) != 0 {
+					return nil, errors.Errorf("line %d : unterminated statement", i+1)
+				}
+
+				if annotation == annotationUp {
+					section = &result.Up
+				} else {
+					section = &result.Down
+				}
+
+			case annotationStatementBegin:
+				if section == nil || inBlock {
+					return nil, errors.Errorf("line %d : unexpected %s", i+1, annotation)
+				}
+				inBlock = true
+
+			case annotationStatementEnd:
+				if !inBlock {
+					return nil, errors.Errorf("line %d : unexpected %s", i+1, annotation)
+				}
+				inBlock = false
+				endStatement()
+			}
+			continue
+		}
+
+		if section == nil {
+			continue // before the first section
+		}
+
+		// Skip comments and blank lines between statements.
+		if !inBlock && len(strings.TrimSpace(statement.String())) == 0 &&
+			(len(trimmed) == 0 || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+
+		if !inBlock && strings.HasSuffix(trimmed, ";") {
+			endStatement()
+		}
+	}
+
+	if inBlock || len(strings.TrimSpace(statement.String())) != 0 {
+		return nil, errors.New("unterminated statement")
+	}
+
+	if result.Up == nil {
+		return nil, errors.New("no up statements")
+	}
+
+	return result, nil
+}
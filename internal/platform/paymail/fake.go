@@ -0,0 +1,84 @@
+package paymail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+// FakeServer is a local bsvalias service used for tests. It serves every domain.
+type FakeServer struct {
+	server *httptest.Server
+	keys   map[string]bitcoin.PublicKey
+	lock   sync.Mutex
+}
+
+// NewFakeServer starts a fake paymail service. Close must be called when it is no longer needed.
+func NewFakeServer() *FakeServer {
+	result := &FakeServer{
+		keys: make(map[string]bitcoin.PublicKey),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/bsvalias", result.capabilities)
+	mux.HandleFunc("/id/", result.identity)
+	result.server = httptest.NewTLSServer(mux)
+
+	return result
+}
+
+// SetPublicKey sets the identity public key of a handle.
+func (s *FakeServer) SetPublicKey(handle string, publicKey bitcoin.PublicKey) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys[strings.ToLower(handle)] = publicKey
+}
+
+// Resolver returns a resolver that uses the fake server for all domains. It trusts the server's
+// certificate and connects to its local address.
+func (s *FakeServer) Resolver() *HTTPResolver {
+	return &HTTPResolver{
+		client: s.server.Client(),
+		baseURL: func(ctx context.Context, domain string) (string, error) {
+			return s.server.URL, nil
+		},
+	}
+}
+
+func (s *FakeServer) Close() {
+	s.server.Close()
+}
+
+func (s *FakeServer) capabilities(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bsvalias": "1.0",
+		"capabilities": map[string]interface{}{
+			"pki": s.server.URL + "/id/{alias}@{domain.tld}",
+		},
+	})
+}
+
+func (s *FakeServer) identity(w http.ResponseWriter, r *http.Request) {
+	handle := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/id/"))
+
+	s.lock.Lock()
+	publicKey, exists := s.keys[handle]
+	s.lock.Unlock()
+
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bsvalias": "1.0",
+		"handle":   handle,
+		"pubkey":   publicKey.String(),
+	})
+}
@@ -0,0 +1,165 @@
+package paymail
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tokenized/identity-oracle/internal/platform/publichttp"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxResponseSize is the maximum number of bytes read from a paymail service response.
+	maxResponseSize = 64 * 1024
+)
+
+var (
+	ErrInvalidHandle        = errors.New("Invalid Paymail Handle")
+	ErrCapabilityNotFound   = errors.New("Paymail Capability Not Found")
+	ErrPaymailNotFound      = errors.New("Paymail Not Found")
+	ErrInvalidPaymailResult = errors.New("Invalid Paymail Result")
+)
+
+// Resolver retrieves information about paymail handles.
+type Resolver interface {
+	// PublicKey returns the identity public key of the handle from its bsvalias pki capability.
+	PublicKey(ctx context.Context, handle string) (bitcoin.PublicKey, error)
+}
+
+// HTTPResolver resolves paymail handles using bsvalias capability discovery.
+type HTTPResolver struct {
+	client *http.Client
+
+	// baseURL returns the URL of the paymail service for a domain.
+	baseURL func(ctx context.Context, domain string) (string, error)
+}
+
+// NewHTTPResolver creates a resolver that locates the paymail service of a domain with its
+// _bsvalias._tcp SRV record, falling back to the domain itself. Paymail services are chosen by
+// users, so it only connects to public addresses.
+func NewHTTPResolver(timeout time.Duration) *HTTPResolver {
+	return &HTTPResolver{
+		client:  publichttp.NewClient(timeout),
+		baseURL: srvBaseURL,
+	}
+}
+
+// SplitHandle returns the alias and domain of a paymail handle.
+func SplitHandle(handle string) (string, string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(handle)), "@")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", errors.Wrap(ErrInvalidHandle, handle)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func (r *HTTPResolver) PublicKey(ctx context.Context, handle string) (bitcoin.PublicKey, error) {
+	alias, domain, err := SplitHandle(handle)
+	if err != nil {
+		return bitcoin.PublicKey{}, err
+	}
+
+	baseURL, err := r.baseURL(ctx, domain)
+	if err != nil {
+		return bitcoin.PublicKey{}, errors.Wrap(err, "base url")
+	}
+
+	var capabilities struct {
+		BSVAlias     string                 `json:"bsvalias"`
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := r.get(ctx, baseURL+"/.well-known/bsvalias", &capabilities); err != nil {
+		return bitcoin.PublicKey{}, errors.Wrap(err, "capabilities")
+	}
+
+	pki, ok := capabilities.Capabilities["pki"].(string)
+	if !ok || len(pki) == 0 {
+		return bitcoin.PublicKey{}, errors.Wrap(ErrCapabilityNotFound, "pki")
+	}
+
+	pki = strings.Replace(pki, "{alias}", alias, -1)
+	pki = strings.Replace(pki, "{domain.tld}", domain, -1)
+
+	var identity struct {
+		Handle    string `json:"handle"`
+		PublicKey string `json:"pubkey"`
+	}
+	if err := r.get(ctx, pki, &identity); err != nil {
+		return bitcoin.PublicKey{}, errors.Wrap(err, "pki")
+	}
+
+	publicKey, err := bitcoin.PublicKeyFromStr(identity.PublicKey)
+	if err != nil {
+		return bitcoin.PublicKey{}, errors.Wrap(ErrInvalidPaymailResult, err.Error())
+	}
+
+	return publicKey, nil
+}
+
+func (r *HTTPResolver) get(ctx context.Context, rawURL string, result interface{}) error {
+	// The pki URL comes from the capabilities document, so it is checked like the base URL.
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(ErrInvalidPaymailResult, err.Error())
+	}
+	if parsedURL.Scheme != "https" {
+		return errors.Wrapf(ErrInvalidPaymailResult, "not https: %s", rawURL)
+	}
+
+	request, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := r.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "get")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return errors.Wrap(ErrPaymailNotFound, rawURL)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("status %d", response.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).
+		Decode(result); err != nil {
+		return errors.Wrap(ErrInvalidPaymailResult, err.Error())
+	}
+
+	return nil
+}
+
+// srvBaseURL returns the paymail service URL specified by the domain's SRV record, or the domain
+// itself if it doesn't have one.
+func srvBaseURL(ctx context.Context, domain string) (string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "bsvalias", "tcp", domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return "https://" + domain, nil
+		}
+		return "", errors.Wrap(err, "lookup srv")
+	}
+
+	if len(records) == 0 {
+		return "https://" + domain, nil
+	}
+
+	host := strings.TrimSuffix(records[0].Target, ".")
+	return "https://" + net.JoinHostPort(host, strconv.Itoa(int(records[0].Port))), nil
+}
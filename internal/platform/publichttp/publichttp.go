@@ -0,0 +1,100 @@
+package publichttp
+
+import (
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotPublic occurs when a connection is attempted to an address that isn't public, like a
+	// loopback or private network address.
+	ErrNotPublic = errors.New("Address Not Public")
+
+	// nonPublicNetworks are the ranges that IsPublic rejects that the net package doesn't have
+	// checks for.
+	nonPublicNetworks = parseNetworks(
+		"0.0.0.0/8",      // "this" network
+		"10.0.0.0/8",     // private
+		"100.64.0.0/10",  // carrier grade NAT
+		"172.16.0.0/12",  // private
+		"192.0.0.0/24",   // protocol assignments
+		"192.168.0.0/16", // private
+		"198.18.0.0/15",  // benchmarking
+		"240.0.0.0/4",    // reserved
+		"fc00::/7",       // unique local
+	)
+)
+
+// NewClient returns a client for requests to hosts that are chosen by users, like the domain in
+// their identity. It only connects to public addresses, so users can't use it to reach services
+// on the oracle's network, and doesn't follow redirects. Addresses are checked when connecting,
+// after DNS resolution, so a host name can't resolve to a private address either.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: control,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// Proxies aren't used since the proxy's address would be checked instead of the host.
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// IsPublic returns true if the address is routable on the internet.
+func IsPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// control rejects connections to addresses that aren't public. It is called with the resolved
+// address of each connection.
+func control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(ErrNotPublic, address)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublic(ip) {
+		return errors.Wrap(ErrNotPublic, host)
+	}
+
+	return nil
+}
+
+func parseNetworks(values ...string) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, network)
+	}
+	return result
+}
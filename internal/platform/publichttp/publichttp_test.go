@@ -0,0 +1,71 @@
+package publichttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.address)
+		if ip == nil {
+			t.Fatalf("Failed to parse address : %s", tt.address)
+		}
+
+		if IsPublic(ip) != tt.public {
+			t.Fatalf("Wrong result for %s : got %t, want %t", tt.address, !tt.public, tt.public)
+		}
+	}
+}
+
+func TestNewClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request : %s", err)
+	}
+
+	client := NewClient(5 * time.Second)
+	response, err := client.Do(request.WithContext(ctx))
+	if err == nil {
+		response.Body.Close()
+		t.Fatalf("Request to loopback address should fail")
+	}
+
+	// The dial error is wrapped by the net and http packages, which don't support errors.Cause.
+	if !strings.Contains(err.Error(), ErrNotPublic.Error()) {
+		t.Fatalf("Wrong request error : %s", err)
+	}
+}
@@ -0,0 +1,331 @@
+package sanctions
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultThreshold is the minimum similarity, from 0 to 1, of a name to a listed name to be
+	// reported as a match.
+	DefaultThreshold = 0.92
+
+	// minTrigramOverlap is the fraction of a name's trigrams a listed name must share to be
+	// scored.
+	minTrigramOverlap = 0.3
+)
+
+// ignoredTokens are words that don't distinguish names, like company suffixes.
+var ignoredTokens = map[string]bool{
+	"the": true, "and": true, "of": true,
+	"co": true, "company": true, "corp": true, "corporation": true, "inc": true,
+	"incorporated": true, "llc": true, "ltd": true, "limited": true, "plc": true, "pty": true,
+	"sa": true, "ag": true, "gmbh": true, "bv": true, "jsc": true, "ooo": true,
+}
+
+// Match is a listed name that is similar to a screened name.
+type Match struct {
+	Entry       *Entry  `json:"entry"`
+	MatchedName string  `json:"matched_name"`
+	Score       float64 `json:"score"`
+}
+
+type indexedName struct {
+	entry *Entry
+	name  string
+	key   string
+}
+
+// Index finds listed names that are similar to a name. Names are compared after removing case,
+// accents, punctuation, word order, and company suffixes so that "SMITH, John" matches
+// "John Smith Ltd". Similarity is the Jaro-Winkler similarity of the compared forms.
+type Index struct {
+	threshold float64
+	names     []indexedName
+	trigrams  map[string][]int
+}
+
+// NewIndex indexes the names and aliases of the entries.
+func NewIndex(entries []*Entry, threshold float64) *Index {
+	result := &Index{
+		threshold: threshold,
+		trigrams:  make(map[string][]int),
+	}
+
+	for _, entry := range entries {
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			key := NameKey(name)
+			if len(key) == 0 {
+				continue
+			}
+
+			i := len(result.names)
+			result.names = append(result.names, indexedName{
+				entry: entry,
+				name:  name,
+				key:   key,
+			})
+
+			for _, trigram := range trigrams(key) {
+				result.trigrams[trigram] = append(result.trigrams[trigram], i)
+			}
+		}
+	}
+
+	return result
+}
+
+// Size returns the number of names, including aliases, in the index.
+func (x *Index) Size() int {
+	return len(x.names)
+}
+
+// Search returns the best match for each entry with a name similar to the name, best first.
+func (x *Index) Search(name string) []*Match {
+	key := NameKey(name)
+	if len(key) == 0 {
+		return nil
+	}
+
+	queryTrigrams := trigrams(key)
+	counts := make(map[int]int)
+	for _, trigram := range queryTrigrams {
+		for _, i := range x.trigrams[trigram] {
+			counts[i]++
+		}
+	}
+
+	best := make(map[*Entry]*Match)
+	for i, count := range counts {
+		if float64(count) < minTrigramOverlap*float64(len(queryTrigrams)) {
+			continue
+		}
+
+		indexed := x.names[i]
+		score := JaroWinkler(key, indexed.key)
+		if score < x.threshold {
+			continue
+		}
+
+		if match, exists := best[indexed.entry]; exists && match.Score >= score {
+			continue
+		}
+
+		best[indexed.entry] = &Match{
+			Entry:       indexed.entry,
+			MatchedName: indexed.name,
+			Score:       score,
+		}
+	}
+
+	result := make([]*Match, 0, len(best))
+	for _, match := range best {
+		result = append(result, match)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if result[i].Entry.List != result[j].Entry.List {
+			return result[i].Entry.List < result[j].Entry.List
+		}
+		return result[i].Entry.ID < result[j].Entry.ID
+	})
+
+	return result
+}
+
+// NameKey returns the form of a name that is compared. It is the lower case words of the name
+// without accents or punctuation, excluding ignored words, sorted.
+func NameKey(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop accents
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’':
+			// join contractions like "O'Brien"
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	var tokens []string
+	for _, token := range strings.Fields(b.String()) {
+		if !ignoredTokens[token] {
+			tokens = append(tokens, token)
+		}
+	}
+
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+func trigrams(key string) []string {
+	padded := []rune("  " + key + "  ")
+	seen := make(map[string]bool)
+	var result []string
+	for i := 0; i+3 <= len(padded); i++ {
+		trigram := string(padded[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			result = append(result, trigram)
+		}
+	}
+	return result
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings from 0 (different) to 1
+// (equal).
+func JaroWinkler(a, b string) float64 {
+	ra := []rune(a)
+	rb := []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1.0
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0.0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		start := max(0, i-window)
+		end := min(len(rb), i+window+1)
+		for j := start; j < end; j++ {
+			if matchedB[j] || ra[i] != rb[j] {
+				continue
+			}
+			matchedA[i] = true
+			matchedB[j] = true
+			matches++
+			break
+		}
+	}
+
+	if matches == 0 {
+		return 0.0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Screener screens names against sanctions lists loaded from files. The lists can be reloaded
+// while it is in use so that refreshed files are picked up without a restart.
+type Screener struct {
+	path      string
+	threshold float64
+	index     *Index
+	lock      sync.RWMutex
+}
+
+// NewScreener loads the sanctions lists at path, which can be a file or a directory of files.
+func NewScreener(path string, threshold float64) (*Screener, error) {
+	result := &Screener{
+		path:      path,
+		threshold: threshold,
+	}
+
+	if err := result.Reload(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// NewScreenerFromEntries creates a screener from entries that are already loaded. Reload does
+// nothing for it.
+func NewScreenerFromEntries(entries []*Entry, threshold float64) *Screener {
+	return &Screener{
+		threshold: threshold,
+		index:     NewIndex(entries, threshold),
+	}
+}
+
+// Reload loads the sanctions lists again from the files.
+func (s *Screener) Reload() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	entries, err := LoadPath(s.path)
+	if err != nil {
+		return errors.Wrap(err, "load sanctions lists")
+	}
+
+	index := NewIndex(entries, s.threshold)
+
+	s.lock.Lock()
+	s.index = index
+	s.lock.Unlock()
+
+	return nil
+}
+
+// Size returns the number of names, including aliases, being screened against.
+func (s *Screener) Size() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.index.Size()
+}
+
+// Screen returns the listed entries with names similar to the name, best first.
+func (s *Screener) Screen(name string) []*Match {
+	s.lock.RLock()
+	index := s.index
+	s.lock.RUnlock()
+
+	return index.Search(name)
+}
@@ -0,0 +1,350 @@
+package sanctions
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnsupportedFile = errors.New("Unsupported Sanctions File")
+)
+
+// Entry is a sanctioned party from a list.
+type Entry struct {
+	List     string   `json:"list"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type,omitempty"`
+	Programs []string `json:"programs,omitempty"`
+	Aliases  []string `json:"aliases,omitempty"`
+}
+
+// LoadPath loads the sanctions lists in a file or in all .csv and .xml files of a directory.
+//
+// Supported formats are:
+//   - OFAC SDN and consolidated XML (sdn.xml, consolidated.xml).
+//   - OFAC SDN and consolidated CSV (sdn.csv, cons_prim.csv) with their aliases (alt.csv,
+//     cons_alt.csv) in the same directory.
+//   - CSV with a header row containing "id" and "name" columns and optional "type", "programs",
+//     and "aliases" columns. Programs and aliases are separated by ";".
+func LoadPath(path string) ([]*Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "stat")
+	}
+
+	var files []string
+	if info.IsDir() {
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "read dir")
+		}
+
+		for _, fileInfo := range infos {
+			if fileInfo.IsDir() {
+				continue
+			}
+
+			switch strings.ToLower(filepath.Ext(fileInfo.Name())) {
+			case ".csv", ".xml":
+				files = append(files, filepath.Join(path, fileInfo.Name()))
+			}
+		}
+	} else {
+		files = []string{path}
+	}
+
+	// Alias files refer to entries in their primary file so they are applied after all lists are
+	// loaded.
+	var result []*Entry
+	lists := make(map[string]map[string]*Entry)
+	var aliasFiles []string
+	for _, file := range files {
+		if _, isAlias := aliasList(file); isAlias {
+			aliasFiles = append(aliasFiles, file)
+			continue
+		}
+
+		entries, err := loadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, filepath.Base(file))
+		}
+
+		for _, entry := range entries {
+			byID, exists := lists[entry.List]
+			if !exists {
+				byID = make(map[string]*Entry)
+				lists[entry.List] = byID
+			}
+			byID[entry.ID] = entry
+		}
+
+		result = append(result, entries...)
+	}
+
+	for _, file := range aliasFiles {
+		list, _ := aliasList(file)
+		if err := loadOFACAliases(file, lists[list]); err != nil {
+			return nil, errors.Wrap(err, filepath.Base(file))
+		}
+	}
+
+	return result, nil
+}
+
+// listName returns the name of the list in a file, which is the file name without extension.
+func listName(file string) string {
+	base := filepath.Base(file)
+	return strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// aliasList returns the list that an OFAC alias file applies to.
+func aliasList(file string) (string, bool) {
+	name := listName(file)
+	switch {
+	case name == "alt":
+		return "sdn", true
+	case strings.HasSuffix(name, "_alt"):
+		return strings.TrimSuffix(name, "_alt") + "_prim", true
+	}
+
+	return "", false
+}
+
+func loadFile(file string) ([]*Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "open")
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xml":
+		return parseOFACXML(f, listName(file))
+	case ".csv":
+		return parseCSV(f, listName(file))
+	}
+
+	return nil, errors.Wrap(ErrUnsupportedFile, file)
+}
+
+// ofacNull is how OFAC CSV files represent an empty value.
+const ofacNull = "-0-"
+
+func ofacValue(s string) string {
+	s = strings.TrimSpace(s)
+	if s == ofacNull {
+		return ""
+	}
+	return s
+}
+
+// parseCSV parses a CSV sanctions list. Files with a header row containing "name" are generic
+// lists, otherwise they are OFAC format (ent_num, SDN_Name, SDN_Type, Program, ...).
+func parseCSV(r io.Reader, list string) ([]*Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "read csv")
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	if _, hasName := columns["name"]; hasName {
+		return parseGenericCSV(records[1:], columns, list), nil
+	}
+
+	var result []*Entry
+	for _, record := range records {
+		if len(record) < 2 || len(ofacValue(record[1])) == 0 {
+			continue
+		}
+
+		entry := &Entry{
+			List: list,
+			ID:   ofacValue(record[0]),
+			Name: ofacValue(record[1]),
+		}
+
+		if len(record) > 2 {
+			entry.Type = ofacValue(record[2])
+		}
+
+		if len(record) > 3 && len(ofacValue(record[3])) != 0 {
+			entry.Programs = splitList(strings.Trim(ofacValue(record[3]), "[]"), "] [")
+		}
+
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+func parseGenericCSV(records [][]string, columns map[string]int, list string) []*Entry {
+	value := func(record []string, column string) string {
+		i, exists := columns[column]
+		if !exists || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var result []*Entry
+	for _, record := range records {
+		name := value(record, "name")
+		if len(name) == 0 {
+			continue
+		}
+
+		result = append(result, &Entry{
+			List:     list,
+			ID:       value(record, "id"),
+			Name:     name,
+			Type:     value(record, "type"),
+			Programs: splitList(value(record, "programs"), ";"),
+			Aliases:  splitList(value(record, "aliases"), ";"),
+		})
+	}
+
+	return result
+}
+
+// loadOFACAliases adds the names in an OFAC alias file (ent_num, alt_num, alt_type, alt_name,
+// alt_remarks) to the entries of its list.
+func loadOFACAliases(file string, entries map[string]*Entry) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrap(err, "open")
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return errors.Wrap(err, "read csv")
+	}
+
+	for _, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+
+		entry, exists := entries[ofacValue(record[0])]
+		if !exists {
+			continue
+		}
+
+		if alias := ofacValue(record[3]); len(alias) != 0 {
+			entry.Aliases = append(entry.Aliases, alias)
+		}
+	}
+
+	return nil
+}
+
+// ofacName is the name format of OFAC XML files.
+type ofacName struct {
+	FirstName string `xml:"firstName"`
+	LastName  string `xml:"lastName"`
+}
+
+// String returns the name in the same "LAST, First" form as OFAC CSV files.
+func (n ofacName) String() string {
+	first := strings.TrimSpace(n.FirstName)
+	last := strings.TrimSpace(n.LastName)
+	if len(first) == 0 {
+		return last
+	}
+	if len(last) == 0 {
+		return first
+	}
+	return last + ", " + first
+}
+
+type ofacEntry struct {
+	UID      string   `xml:"uid"`
+	Type     string   `xml:"sdnType"`
+	Programs []string `xml:"programList>program"`
+	ofacName
+	Akas []struct {
+		ofacName
+	} `xml:"akaList>aka"`
+}
+
+// parseOFACXML parses the sdnEntry elements of an OFAC XML list.
+func parseOFACXML(r io.Reader, list string) ([]*Entry, error) {
+	decoder := xml.NewDecoder(r)
+
+	var result []*Entry
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read xml")
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "sdnEntry" {
+			continue
+		}
+
+		var e ofacEntry
+		if err := decoder.DecodeElement(&e, &start); err != nil {
+			return nil, errors.Wrap(err, "decode entry")
+		}
+
+		entry := &Entry{
+			List:     list,
+			ID:       strings.TrimSpace(e.UID),
+			Name:     e.ofacName.String(),
+			Type:     strings.TrimSpace(e.Type),
+			Programs: e.Programs,
+		}
+
+		if len(entry.Name) == 0 {
+			continue
+		}
+
+		for _, aka := range e.Akas {
+			if alias := aka.ofacName.String(); len(alias) != 0 {
+				entry.Aliases = append(entry.Aliases, alias)
+			}
+		}
+
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+func splitList(s, separator string) []string {
+	var result []string
+	for _, item := range strings.Split(s, separator) {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			result = append(result, item)
+		}
+	}
+	return result
+}
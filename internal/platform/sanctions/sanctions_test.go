@@ -0,0 +1,179 @@
+package sanctions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testSDNCSV = `36,"AEROCARIBBEAN AIRLINES",-0- ,"CUBA",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,"Havana, Cuba."
+173,"ANGLO-CARIBBEAN CO., LTD.",-0- ,"CUBA",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0-
+2674,"ABU MARZOOK, Mousa Mohammed","individual","SDGT] [SDT",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,"DOB 09 Feb 1951."
+`
+
+	testAltCSV = `2674,1234,"aka","ABU-'UMAR",-0-
+2674,1235,"aka","MARZOUK, Musa Abu",-0-
+`
+
+	testSDNXML = `<?xml version="1.0" standalone="yes"?>
+<sdnList xmlns="http://tempuri.org/sdnList.xsd">
+  <sdnEntry>
+    <uid>7157</uid>
+    <firstName>Joaquín</firstName>
+    <lastName>GUZMÁN LOERA</lastName>
+    <sdnType>Individual</sdnType>
+    <programList>
+      <program>SDNTK</program>
+    </programList>
+    <akaList>
+      <aka>
+        <uid>7159</uid>
+        <type>a.k.a.</type>
+        <category>strong</category>
+        <lastName>EL CHAPO</lastName>
+      </aka>
+    </akaList>
+  </sdnEntry>
+</sdnList>
+`
+
+	testGenericCSV = `id,name,type,aliases
+UK-1,Example Trading Limited,entity,Example Traders;ETL Holdings
+`
+)
+
+func writeTestLists(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "sanctions")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+
+	files := map[string]string{
+		"SDN.CSV":   testSDNCSV,
+		"ALT.CSV":   testAltCSV,
+		"sdn.xml":   testSDNXML,
+		"local.csv": testGenericCSV,
+		"README":    "not a list",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s : %s", name, err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoadPath(t *testing.T) {
+	dir := writeTestLists(t)
+	defer os.RemoveAll(dir)
+
+	entries, err := LoadPath(dir)
+	if err != nil {
+		t.Fatalf("Failed to load lists : %s", err)
+	}
+
+	byID := make(map[string]*Entry)
+	for _, entry := range entries {
+		t.Logf("%s %s %s %v %v", entry.List, entry.ID, entry.Name, entry.Programs, entry.Aliases)
+		byID[entry.ID] = entry
+	}
+
+	if len(entries) != 5 {
+		t.Fatalf("Wrong entry count : got %d, want %d", len(entries), 5)
+	}
+
+	marzook := byID["2674"]
+	if marzook == nil {
+		t.Fatalf("Missing CSV entry")
+	}
+	if len(marzook.Aliases) != 2 {
+		t.Errorf("Wrong alias count : got %d, want %d", len(marzook.Aliases), 2)
+	}
+	if len(marzook.Programs) != 2 || marzook.Programs[1] != "SDT" {
+		t.Errorf("Wrong programs : %v", marzook.Programs)
+	}
+
+	guzman := byID["7157"]
+	if guzman == nil {
+		t.Fatalf("Missing XML entry")
+	}
+	if guzman.Name != "GUZMÁN LOERA, Joaquín" {
+		t.Errorf("Wrong XML name : %s", guzman.Name)
+	}
+	if len(guzman.Aliases) != 1 || guzman.Aliases[0] != "EL CHAPO" {
+		t.Errorf("Wrong XML aliases : %v", guzman.Aliases)
+	}
+
+	if local := byID["UK-1"]; local == nil || len(local.Aliases) != 2 {
+		t.Errorf("Wrong generic CSV entry : %+v", local)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	dir := writeTestLists(t)
+	defer os.RemoveAll(dir)
+
+	screener, err := NewScreener(dir, DefaultThreshold)
+	if err != nil {
+		t.Fatalf("Failed to create screener : %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		match string
+	}{
+		{"Mousa Mohammed Abu Marzook", "2674"},
+		{"Musa Abu Marzouk", "2674"},
+		{"Joaquin Guzman Loera", "7157"},
+		{"Joaquin Guzmann Loera", "7157"},
+		{"El Chapo", "7157"},
+		{"Anglo Caribbean Company", "173"},
+		{"Example Traders Ltd", "UK-1"},
+		{"John Smith", ""},
+		{"Caribbean Holidays", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := screener.Screen(tt.name)
+			for _, match := range matches {
+				t.Logf("%s matched %s %s (%s) : %.3f", tt.name, match.Entry.List,
+					match.Entry.ID, match.MatchedName, match.Score)
+			}
+
+			if len(tt.match) == 0 {
+				if len(matches) != 0 {
+					t.Fatalf("Should not match")
+				}
+				return
+			}
+
+			if len(matches) == 0 || matches[0].Entry.ID != tt.match {
+				t.Fatalf("Should match %s", tt.match)
+			}
+		})
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.961},
+		{"dixon", "dicksonx", 0.813},
+		{"abc", "abc", 1.0},
+		{"abc", "xyz", 0.0},
+	}
+
+	for _, tt := range tests {
+		got := JaroWinkler(tt.a, tt.b)
+		if got < tt.want-0.001 || got > tt.want+0.001 {
+			t.Errorf("JaroWinkler(%q, %q) : got %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
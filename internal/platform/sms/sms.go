@@ -0,0 +1,63 @@
+package sms
+
+import (
+	"context"
+	"sync"
+)
+
+// SMSSender sends text messages to phone numbers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phoneNumber, message string) error
+}
+
+// Message is a text message sent through the mock sender.
+type Message struct {
+	PhoneNumber string
+	Text        string
+}
+
+// MockSender keeps sent messages in memory. It is used for tests and local development.
+type MockSender struct {
+	messages []*Message
+	lock     sync.Mutex
+}
+
+func NewMockSender() *MockSender {
+	return &MockSender{}
+}
+
+func (m *MockSender) SendSMS(ctx context.Context, phoneNumber, message string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.messages = append(m.messages, &Message{
+		PhoneNumber: phoneNumber,
+		Text:        message,
+	})
+	return nil
+}
+
+// Messages returns all messages sent.
+func (m *MockSender) Messages() []*Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := make([]*Message, len(m.messages))
+	copy(result, m.messages)
+	return result
+}
+
+// LastMessage returns the most recent message sent to the phone number, or nil if there isn't
+// one.
+func (m *MockSender) LastMessage(phoneNumber string) *Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].PhoneNumber == phoneNumber {
+			return m.messages[i]
+		}
+	}
+
+	return nil
+}
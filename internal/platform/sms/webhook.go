@@ -0,0 +1,71 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookSender sends text messages by posting them to an HTTP endpoint, like an SMS gateway or a
+// small service in front of one. The body is a JSON object with phone_number and message fields.
+// Any 2xx status is treated as sent.
+type WebhookSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookSender creates a sender that posts messages to url. When token is provided it is sent
+// as a bearer token so the endpoint can authenticate the oracle.
+func NewWebhookSender(url, token string, timeout time.Duration) *WebhookSender {
+	return &WebhookSender{
+		url:   url,
+		token: token,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (s *WebhookSender) SendSMS(ctx context.Context, phoneNumber, message string) error {
+	body, err := json.Marshal(struct {
+		PhoneNumber string `json:"phone_number"`
+		Message     string `json:"message"`
+	}{
+		PhoneNumber: phoneNumber,
+		Message:     message,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	request, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(s.token) != 0 {
+		request.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	response, err := s.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "post")
+	}
+	defer response.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("status %d", response.StatusCode)
+	}
+
+	return nil
+}
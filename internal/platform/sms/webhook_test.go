@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookSender(t *testing.T) {
+	var received struct {
+		PhoneNumber string `json:"phone_number"`
+		Message     string `json:"message"`
+	}
+	var authorization string
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode webhook body : %s", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(server.URL, "secret", 5*time.Second)
+
+	if err := sender.SendSMS(context.Background(), "+61400000000", "Code 123456"); err != nil {
+		t.Fatalf("Failed to send sms : %s", err)
+	}
+
+	if received.PhoneNumber != "+61400000000" || received.Message != "Code 123456" {
+		t.Fatalf("Wrong webhook body : %+v", received)
+	}
+
+	if authorization != "Bearer secret" {
+		t.Fatalf("Wrong authorization header : %s", authorization)
+	}
+
+	status = http.StatusBadGateway
+	if err := sender.SendSMS(context.Background(), "+61400000000", "Code 123456"); err == nil {
+		t.Fatalf("Failed webhook should return an error")
+	}
+}
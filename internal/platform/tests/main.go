@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/tokenized/config"
 	"github.com/tokenized/identity-oracle/internal/platform/db"
 	"github.com/tokenized/identity-oracle/internal/platform/web"
 	"github.com/tokenized/pkg/bitcoin"
@@ -17,7 +16,8 @@ const (
 	Failed  = "\u2717"
 )
 
-// Test owns state for running/shutting down tests.
+// Test owns state for running/shutting down tests. MasterDB has no database connection, only mock
+// storage, so tests that need users and related data use an in-memory oracle store.
 type Test struct {
 	MasterDB  *db.DB
 	WebConfig *web.Config
@@ -26,24 +26,12 @@ type Test struct {
 // New is the entry point for tests.
 func New() *Test {
 
-	// ============================================================
-	// Configuration
-
 	ctx := logger.ContextWithLogger(context.Background(), false, false, "")
 
-	cfg := &Config{}
-	// load config using sane fallbacks
-	if err := config.LoadConfig(ctx, cfg); err != nil {
-		logger.Fatal(ctx, "main : LoadConfig : %v", err)
-	}
-
 	// ============================================================
-	// Start Database
+	// Start Storage
 
-	masterDB, err := db.New(&db.DBConfig{
-		Driver: cfg.Db.Driver,
-		URL:    cfg.Db.URL,
-	}, nil)
+	masterDB, err := db.New(nil, nil)
 	if err != nil {
 		logger.Fatal(ctx, "main : Register DB : %v", err)
 	}
@@ -79,10 +67,3 @@ func Context() context.Context {
 
 	return web.ContextWithValues(ctx, bitcoin.MainNet, true)
 }
-
-type Config struct {
-	Db struct {
-		Driver string `default:"postgres" envconfig:"DB_DRIVER" json:"DB_DRIVER"`
-		URL    string `default:"user=foo dbname=bar sslmode=disable" envconfig:"DB_URL" json:"DB_URL"`
-	}
-}
@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.opencensus.io/trace"
+)
+
+// zipkinSpan is a span in the Zipkin v2 JSON format.
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"` // microseconds
+	Duration      int64              `json:"duration"`  // microseconds
+	LocalEndpoint zipkinEndpoint     `json:"localEndpoint"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"` // microseconds
+	Value     string `json:"value"`
+}
+
+func zipkinSpans(serviceName string, spans []*trace.SpanData) []*zipkinSpan {
+	result := make([]*zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		s := &zipkinSpan{
+			TraceID:   span.TraceID.String(),
+			ID:        span.SpanID.String(),
+			Name:      span.Name,
+			Timestamp: span.StartTime.UnixNano() / 1e3,
+			Duration:  span.EndTime.Sub(span.StartTime).Nanoseconds() / 1e3,
+			LocalEndpoint: zipkinEndpoint{
+				ServiceName: serviceName,
+			},
+		}
+
+		if span.ParentSpanID != (trace.SpanID{}) {
+			s.ParentID = span.ParentSpanID.String()
+		}
+
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			s.Kind = "SERVER"
+		case trace.SpanKindClient:
+			s.Kind = "CLIENT"
+		}
+
+		if len(span.Attributes) != 0 || span.Code != trace.StatusCodeOK {
+			s.Tags = make(map[string]string)
+		}
+		for key, value := range span.Attributes {
+			s.Tags[key] = fmt.Sprint(value)
+		}
+		if span.Code != trace.StatusCodeOK {
+			s.Tags["error"] = span.Message
+			s.Tags["opencensus.status_code"] = strconv.Itoa(int(span.Code))
+		}
+
+		for _, annotation := range span.Annotations {
+			s.Annotations = append(s.Annotations, zipkinAnnotation{
+				Timestamp: annotation.Time.UnixNano() / 1e3,
+				Value:     annotation.Message,
+			})
+		}
+
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// OTLP JSON encoding of an ExportTraceServiceRequest. 64 bit integers are encoded as strings.
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+func otlpRequest(serviceName string, spans []*trace.SpanData) *otlpExportRequest {
+	scope := otlpScopeSpans{
+		Scope: otlpScope{
+			Name: serviceName,
+		},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              otlpKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Status: otlpStatus{
+				Code: otlpStatusOK,
+			},
+		}
+
+		if span.ParentSpanID != (trace.SpanID{}) {
+			s.ParentSpanID = span.ParentSpanID.String()
+		}
+
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			s.Kind = otlpKindServer
+		case trace.SpanKindClient:
+			s.Kind = otlpKindClient
+		}
+
+		for key, value := range span.Attributes {
+			s.Attributes = append(s.Attributes, otlpAttributeValue(key, value))
+		}
+
+		for _, annotation := range span.Annotations {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(annotation.Time.UnixNano(), 10),
+				Name:         annotation.Message,
+			})
+		}
+
+		if span.Code != trace.StatusCodeOK {
+			s.Status = otlpStatus{
+				Code:    otlpStatusError,
+				Message: span.Message,
+			}
+		}
+
+		scope.Spans = append(scope.Spans, s)
+	}
+
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpAttribute{
+						otlpAttributeValue("service.name", serviceName),
+					},
+				},
+				ScopeSpans: []otlpScopeSpans{scope},
+			},
+		},
+	}
+}
+
+func otlpAttributeValue(key string, value interface{}) otlpAttribute {
+	result := otlpAttribute{
+		Key: key,
+	}
+
+	switch v := value.(type) {
+	case bool:
+		result.Value.BoolValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		result.Value.IntValue = &s
+	case float64:
+		result.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		result.Value.StringValue = &s
+	}
+
+	return result
+}
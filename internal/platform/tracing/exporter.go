@@ -0,0 +1,182 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// sendFunction sends a batch of spans.
+type sendFunction func(ctx context.Context, spans []*trace.SpanData) error
+
+// batchExporter collects spans and sends them in batches so that requests aren't slowed by
+// exporting. Spans are dropped when they can't be sent, or when maxQueuedSpans are already waiting
+// to be sent so that a slow or unavailable collector can't use up memory.
+type batchExporter struct {
+	ctx  context.Context
+	send sendFunction
+
+	spans    []*trace.SpanData
+	dropped  int
+	lock     sync.Mutex
+	full     chan struct{}
+	stop     chan struct{}
+	finished chan struct{}
+}
+
+func newBatchExporter(ctx context.Context, send sendFunction) *batchExporter {
+	result := &batchExporter{
+		ctx:      ctx,
+		send:     send,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	go result.run()
+	return result
+}
+
+// ExportSpan implements trace.Exporter.
+func (e *batchExporter) ExportSpan(span *trace.SpanData) {
+	e.lock.Lock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		e.lock.Unlock()
+		return
+	}
+	e.spans = append(e.spans, span)
+	count := len(e.spans)
+	e.lock.Unlock()
+
+	if count >= batchSize {
+		select {
+		case e.full <- struct{}{}:
+		default: // already signaled
+		}
+	}
+}
+
+// Close sends the remaining spans and stops sending.
+func (e *batchExporter) Close() error {
+	close(e.stop)
+	<-e.finished
+	return nil
+}
+
+func (e *batchExporter) run() {
+	defer close(e.finished)
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.full:
+			e.flush()
+		case <-e.stop:
+			e.flush()
+			return
+		}
+	}
+}
+
+func (e *batchExporter) flush() {
+	e.lock.Lock()
+	spans := e.spans
+	e.spans = nil
+	dropped := e.dropped
+	e.dropped = 0
+	e.lock.Unlock()
+
+	if dropped != 0 {
+		logger.Warn(e.ctx, "Dropped %d spans while the export queue was full", dropped)
+	}
+
+	for len(spans) > 0 {
+		count := len(spans)
+		if count > batchSize {
+			count = batchSize
+		}
+
+		if err := e.send(e.ctx, spans[:count]); err != nil {
+			logger.Warn(e.ctx, "Failed to export %d spans : %s", count, err)
+		}
+
+		spans = spans[count:]
+	}
+}
+
+// writerExporter writes a JSON line, in the Zipkin v2 format, for each span.
+type writerExporter struct {
+	ctx         context.Context
+	serviceName string
+	w           io.Writer
+	lock        sync.Mutex
+}
+
+func newWriterExporter(ctx context.Context, serviceName string, w io.Writer) *writerExporter {
+	return &writerExporter{
+		ctx:         ctx,
+		serviceName: serviceName,
+		w:           w,
+	}
+}
+
+// ExportSpan implements trace.Exporter.
+func (e *writerExporter) ExportSpan(span *trace.SpanData) {
+	b, err := json.Marshal(zipkinSpans(e.serviceName, []*trace.SpanData{span})[0])
+	if err != nil {
+		logger.Warn(e.ctx, "Failed to serialize span : %s", err)
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, err := e.w.Write(append(b, '\n')); err != nil {
+		logger.Warn(e.ctx, "Failed to write span : %s", err)
+	}
+}
+
+// postJSON posts value as JSON to url.
+func postJSON(ctx context.Context, client *http.Client, url string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "post")
+	}
+	defer response.Body.Close()
+
+	// Read the body so the connection can be reused.
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.Errorf("status %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// Exporters
+	ExporterZipkin = "zipkin" // Zipkin v2 JSON, also accepted by Jaeger and OpenTelemetry collectors
+	ExporterOTLP   = "otlp"   // OTLP JSON over HTTP
+	ExporterStdout = "stdout" // a JSON line per span
+	ExporterFile   = "file"   // a JSON line per span appended to a file
+
+	// batchInterval is the longest spans wait before being sent.
+	batchInterval = 5 * time.Second
+
+	// batchSize is how many spans are sent at once.
+	batchSize = 512
+
+	// maxQueuedSpans is how many spans can wait to be sent before new spans are dropped.
+	maxQueuedSpans = 8 * batchSize
+)
+
+var (
+	ErrUnsupportedExporter = errors.New("Unsupported trace exporter")
+)
+
+// Config configures where spans are exported and how many requests are traced. TrustSampled
+// samples every trace that a caller sampled, as indicated by the flags of its W3C traceparent
+// header. It should only be set when callers are trusted, since otherwise any caller can have all
+// of its requests traced.
+type Config struct {
+	Exporter     string
+	Endpoint     string
+	File         string
+	SampleRate   float64
+	TrustSampled bool
+	ServiceName  string
+	Timeout      time.Duration
+}
+
+// Tracer exports the service's spans.
+type Tracer struct {
+	exporter trace.Exporter
+	close    func() error
+}
+
+// Setup registers an exporter for the spans created with trace.StartSpan and sets the fraction of
+// traces that are sampled. Traces continued from a caller that sampled them are always sampled
+// when cfg.TrustSampled is set. Returns nil when cfg.Exporter is empty.
+func Setup(ctx context.Context, cfg Config) (*Tracer, error) {
+	if len(cfg.Exporter) == 0 {
+		return nil, nil
+	}
+
+	if cfg.SampleRate < 0.0 || cfg.SampleRate > 1.0 {
+		return nil, errors.Errorf("sample rate %f not between 0 and 1", cfg.SampleRate)
+	}
+
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+	}
+
+	result := &Tracer{}
+	switch cfg.Exporter {
+	case ExporterZipkin:
+		if len(cfg.Endpoint) == 0 {
+			return nil, errors.New("zipkin exporter requires an endpoint")
+		}
+
+		batcher := newBatchExporter(ctx, func(ctx context.Context, spans []*trace.SpanData) error {
+			return postJSON(ctx, client, cfg.Endpoint, zipkinSpans(cfg.ServiceName, spans))
+		})
+		result.exporter = batcher
+		result.close = batcher.Close
+
+	case ExporterOTLP:
+		if len(cfg.Endpoint) == 0 {
+			return nil, errors.New("otlp exporter requires an endpoint")
+		}
+
+		batcher := newBatchExporter(ctx, func(ctx context.Context, spans []*trace.SpanData) error {
+			return postJSON(ctx, client, cfg.Endpoint, otlpRequest(cfg.ServiceName, spans))
+		})
+		result.exporter = batcher
+		result.close = batcher.Close
+
+	case ExporterStdout:
+		result.exporter = newWriterExporter(ctx, cfg.ServiceName, os.Stdout)
+		result.close = func() error { return nil }
+
+	case ExporterFile:
+		if len(cfg.File) == 0 {
+			return nil, errors.New("file exporter requires a file")
+		}
+
+		file, err := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, errors.Wrap(err, "open file")
+		}
+
+		result.exporter = newWriterExporter(ctx, cfg.ServiceName, file)
+		result.close = file.Close
+
+	default:
+		return nil, errors.Wrap(ErrUnsupportedExporter, cfg.Exporter)
+	}
+
+	trace.RegisterExporter(result.exporter)
+	trace.ApplyConfig(trace.Config{
+		DefaultSampler: newSampler(cfg.SampleRate, cfg.TrustSampled),
+	})
+
+	logger.Info(ctx, "Exporting traces to %s with sample rate %f", cfg.Exporter, cfg.SampleRate)
+
+	return result, nil
+}
+
+// newSampler returns a sampler that samples the fraction rate of traces. Spans with a local parent
+// are sampled when their parent is. Spans with a remote parent, from a caller's traceparent header,
+// are only sampled because their parent is when trustSampled is set.
+func newSampler(rate float64, trustSampled bool) trace.Sampler {
+	sampler := trace.ProbabilitySampler(rate)
+	if trustSampled {
+		return sampler
+	}
+
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		if p.HasRemoteParent {
+			p.ParentContext.TraceOptions = 0
+		}
+		return sampler(p)
+	}
+}
+
+// Close stops exporting spans and sends those that haven't been sent yet.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+
+	trace.UnregisterExporter(t.exporter)
+	return t.close()
+}
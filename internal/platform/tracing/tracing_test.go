@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+func testSpan() *trace.SpanData {
+	start := time.Unix(1600000000, 0)
+
+	return &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		ParentSpanID: trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "POST /transfer/approve",
+		StartTime:    start,
+		EndTime:      start.Add(1500 * time.Microsecond),
+		Attributes: map[string]interface{}{
+			"http.status_code": int64(500),
+		},
+		Status: trace.Status{
+			Code:    trace.StatusCodeInternal,
+			Message: "Internal Server Error",
+		},
+	}
+}
+
+func TestZipkinSpans(t *testing.T) {
+	spans := zipkinSpans("identity-oracle", []*trace.SpanData{testSpan()})
+
+	b, err := json.Marshal(spans)
+	if err != nil {
+		t.Fatalf("Failed to marshal spans : %s", err)
+	}
+
+	want := `[{"traceId":"0102030405060708090a0b0c0d0e0f10","id":"0102030405060708",` +
+		`"parentId":"0807060504030201","name":"POST /transfer/approve","kind":"SERVER",` +
+		`"timestamp":1600000000000000,"duration":1500,` +
+		`"localEndpoint":{"serviceName":"identity-oracle"},` +
+		`"tags":{"error":"Internal Server Error","http.status_code":"500",` +
+		`"opencensus.status_code":"13"}}]`
+
+	if string(b) != want {
+		t.Fatalf("Wrong zipkin spans :\ngot  %s\nwant %s", b, want)
+	}
+}
+
+func TestOTLPRequest(t *testing.T) {
+	b, err := json.Marshal(otlpRequest("identity-oracle", []*trace.SpanData{testSpan()}))
+	if err != nil {
+		t.Fatalf("Failed to marshal request : %s", err)
+	}
+
+	for _, s := range []string{
+		`"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"identity-oracle"}}]}`,
+		`"traceId":"0102030405060708090a0b0c0d0e0f10"`,
+		`"parentSpanId":"0807060504030201"`,
+		`"kind":2`,
+		`"startTimeUnixNano":"1600000000000000000"`,
+		`"endTimeUnixNano":"1600000000001500000"`,
+		`{"key":"http.status_code","value":{"intValue":"500"}}`,
+		`"status":{"code":2,"message":"Internal Server Error"}`,
+	} {
+		if !strings.Contains(string(b), s) {
+			t.Fatalf("OTLP request missing %s :\n%s", s, b)
+		}
+	}
+}
+
+func TestBatchExporter(t *testing.T) {
+	var lock sync.Mutex
+	var sent []int
+	exporter := newBatchExporter(context.Background(),
+		func(ctx context.Context, spans []*trace.SpanData) error {
+			lock.Lock()
+			defer lock.Unlock()
+			sent = append(sent, len(spans))
+			return errors.New("test failure") // failures are dropped
+		})
+
+	for i := 0; i < batchSize+10; i++ {
+		exporter.ExportSpan(testSpan())
+	}
+
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Failed to close exporter : %s", err)
+	}
+
+	total := 0
+	for _, count := range sent {
+		if count > batchSize {
+			t.Fatalf("Batch too large : %d", count)
+		}
+		total += count
+	}
+
+	if total != batchSize+10 {
+		t.Fatalf("Wrong span count : got %d, want %d", total, batchSize+10)
+	}
+}
+
+func TestBatchExporterFull(t *testing.T) {
+	// Not started, so nothing is sent while the queue fills.
+	exporter := &batchExporter{
+		ctx:  context.Background(),
+		full: make(chan struct{}, 1),
+	}
+
+	for i := 0; i < maxQueuedSpans+10; i++ {
+		exporter.ExportSpan(testSpan())
+	}
+
+	if len(exporter.spans) != maxQueuedSpans {
+		t.Fatalf("Wrong queued span count : got %d, want %d", len(exporter.spans),
+			maxQueuedSpans)
+	}
+
+	if exporter.dropped != 10 {
+		t.Fatalf("Wrong dropped span count : got %d, want 10", exporter.dropped)
+	}
+}
+
+func TestSamplerRemoteParent(t *testing.T) {
+	params := trace.SamplingParameters{
+		ParentContext: trace.SpanContext{
+			TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:       trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+			TraceOptions: 1, // sampled
+		},
+		HasRemoteParent: true,
+	}
+
+	if newSampler(0.0, false)(params).Sample {
+		t.Fatalf("Untrusted sampled parent should not be sampled")
+	}
+
+	if !newSampler(0.0, true)(params).Sample {
+		t.Fatalf("Trusted sampled parent should be sampled")
+	}
+
+	params.HasRemoteParent = false
+	if !newSampler(0.0, false)(params).Sample {
+		t.Fatalf("Local sampled parent should be sampled")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := newWriterExporter(context.Background(), "identity-oracle", &buf)
+
+	exporter.ExportSpan(testSpan())
+	exporter.ExportSpan(testSpan())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Wrong line count : got %d, want 2", len(lines))
+	}
+
+	span := &zipkinSpan{}
+	if err := json.Unmarshal([]byte(lines[0]), span); err != nil {
+		t.Fatalf("Failed to unmarshal span : %s", err)
+	}
+
+	if span.Name != "POST /transfer/approve" {
+		t.Fatalf("Wrong span name : %s", span.Name)
+	}
+}
+
+func TestSetupUnsupported(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "unknown"}); errors.Cause(err) !=
+		ErrUnsupportedExporter {
+		t.Fatalf("Unsupported exporter should fail : %v", err)
+	}
+
+	tracer, err := Setup(context.Background(), Config{})
+	if err != nil || tracer != nil {
+		t.Fatalf("Empty exporter should disable tracing : %v", err)
+	}
+}
@@ -30,6 +30,13 @@ var (
 	// ErrForbidden occurs when we know who the user is but they attempt a
 	// forbidden action.
 	ErrForbidden = errors.New("Forbidden")
+
+	// ErrRequestTooLarge occurs when the request body is larger than the handler accepts.
+	ErrRequestTooLarge = errors.New("Request too large")
+
+	// ErrTooManyRequests occurs when a client has made too many requests and must wait before
+	// trying again.
+	ErrTooManyRequests = errors.New("Too many requests")
 )
 
 // JSONError is the response for errors that occur within the API.
@@ -65,6 +72,14 @@ func Error(ctx context.Context, w http.ResponseWriter, err error) {
 	case ErrForbidden:
 		RespondError(ctx, w, err, http.StatusForbidden)
 		return
+
+	case ErrRequestTooLarge:
+		RespondError(ctx, w, err, http.StatusRequestEntityTooLarge)
+		return
+
+	case ErrTooManyRequests:
+		RespondError(ctx, w, err, http.StatusTooManyRequests)
+		return
 	}
 
 	switch e := errors.Cause(err).(type) {